@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
@@ -10,8 +11,15 @@ import (
 	"github.com/lostmyescape/news-tg-bot/internal/bot/middleware"
 	"github.com/lostmyescape/news-tg-bot/internal/botkit"
 	"github.com/lostmyescape/news-tg-bot/internal/config"
+	"github.com/lostmyescape/news-tg-bot/internal/content"
+	"github.com/lostmyescape/news-tg-bot/internal/email"
+	"github.com/lostmyescape/news-tg-bot/internal/enricher"
 	"github.com/lostmyescape/news-tg-bot/internal/fetcher"
+	"github.com/lostmyescape/news-tg-bot/internal/leader"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
 	"github.com/lostmyescape/news-tg-bot/internal/notifier"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+	"github.com/lostmyescape/news-tg-bot/internal/schedule"
 	"github.com/lostmyescape/news-tg-bot/internal/storage"
 	"github.com/lostmyescape/news-tg-bot/internal/summary"
 	"github.com/lostmyescape/news-tg-bot/logger"
@@ -38,6 +46,9 @@ func main() {
 		return
 	}
 
+	// every outgoing message goes through the sender to respect telegram rate limits
+	sender := botkit.NewSender(botAPI)
+
 	db, err := sqlx.Connect("postgres", config.Get().DatabaseDSN)
 	if err != nil {
 		logger.Log.Errorw("failed to connect to database", "err", err)
@@ -45,68 +56,209 @@ func main() {
 	}
 	defer db.Close()
 
+	if fallback := config.Get().SummaryFallback; fallback != model.SummaryFallbackPost && fallback != model.SummaryFallbackSkip {
+		logger.Log.Errorw("invalid summary_fallback, expected post or skip", "summary_fallback", fallback)
+		return
+	}
+
 	var (
-		articleSaver  = storage.NewArticleStorage(db)
-		sourceStorage = storage.NewSourceStorage(db)
-		f             = fetcher.New(
+		articleSaver    = storage.NewArticleStorage(db)
+		sourceStorage   = storage.NewSourceStorage(db)
+		fetchRunStorage = storage.NewFetchRunStorage(db)
+		channelStorage  = storage.NewChannelStorage(db)
+		deliveryStorage = storage.NewDeliveryStorage(db)
+		voteStorage     = storage.NewVoteStorage(db)
+		postStorage     = storage.NewScheduledPostStorage(db)
+		subscriberStore = storage.NewSubscriberStorage(db)
+		instanceStorage = storage.NewInstanceStorage(db)
+		ruleStorage     = storage.NewExtractionRuleStorage(db)
+		extractor       = content.NewExtractor(content.NewFetcher(), ruleStorage)
+		f               = fetcher.New(
 			articleSaver,
 			sourceStorage,
+			fetchRunStorage,
 			config.Get().FetchInterval,
 			config.Get().FilterKeywords,
 		)
+		e = enricher.New(
+			articleSaver,
+			storage.NewArticleListener(config.Get().DatabaseDSN, storage.ArticlesInserted),
+			extractor,
+			summary.NewOpenAiSummarizer(
+				config.Get().OpenAIKey,
+				config.Get().OpenAIBaseURL,
+				config.Get().OpenAIOrganization,
+				config.Get().OpenAIModel,
+				config.Get().OpenAIPrompt,
+				config.Get().OpenAIMaxTokens,
+				config.Get().OpenAITemperature,
+				config.Get().OpenAITimeout,
+			),
+			config.Get().EnrichmentConcurrency,
+			config.Get().EnrichmentMaxAttempts,
+			config.Get().EnrichmentRetryDelay,
+			config.Get().SummaryFallback,
+		)
 		n = notifier.New(
 			articleSaver,
-			summary.NewOpenAiSummarizer(config.Get().OpenAIKey, config.Get().OpenAIPrompt),
-			botAPI,
-			config.Get().NotificationInterval,
-			2*config.Get().FetchInterval,
-			config.Get().TelegramChannelID,
+			storage.NewArticleListener(config.Get().DatabaseDSN, storage.ArticlesEnriched),
+			deliveryStorage,
+			channelStorage,
+			postStorage,
+			sender.With(botkit.PriorityNormal),
+			config.Get().FreshnessWindow,
 		)
 	)
 
+	// the channel from config is registered as the default destination, it keeps the queue of a single channel
+	// setup. Webhooks from config are registered as channels posting the same article stream. Settings
+	// from config are applied on every start
+	defaultChannel := model.Channel{
+		Name:            "default",
+		ChatID:          config.Get().TelegramChannelID,
+		PostingInterval: config.Get().NotificationInterval,
+
+		Strategy:                 config.Get().NotificationStrategy,
+		MaxPostsPerSourcePerHour: config.Get().MaxPostsPerSource,
+		Schedule:                 config.Get().NotificationSchedule,
+		Timezone:                 config.Get().NotificationTimezone,
+		Mode:                     config.Get().NotificationMode,
+		DigestTimes:              config.Get().DigestTimes,
+		DigestGroupBy:            config.Get().DigestGroupBy,
+		Format:                   render.FormatMarkdownV2,
+
+		ModerationChatID:        config.Get().ModerationChatID,
+		ModerationTimeout:       config.Get().ModerationTimeout,
+		ModerationTimeoutAction: config.Get().ModerationTimeoutAction,
+		Publisher:               model.PublisherTelegram,
+		Backlog:                 true,
+	}
+
+	var defaultChannels []model.Channel
+
+	if defaultChannel.ChatID != 0 {
+		defaultChannels = append(defaultChannels, defaultChannel)
+	}
+
+	for publisher, webhookURL := range map[string]string{
+		model.PublisherDiscord: config.Get().DiscordWebhookURL,
+		model.PublisherSlack:   config.Get().SlackWebhookURL,
+		model.PublisherWebhook: config.Get().WebhookURL,
+	} {
+		if webhookURL == "" {
+			continue
+		}
+
+		channel := defaultChannel
+		channel.Name, channel.ChatID, channel.Publisher, channel.WebhookURL = publisher, 0, publisher, webhookURL
+		// webhooks have no digests, moderation stays with the telegram channel
+		channel.Mode, channel.ModerationChatID, channel.Backlog = model.ChannelModeStream, 0, false
+		defaultChannels = append(defaultChannels, channel)
+	}
+
+	for _, channel := range defaultChannels {
+		if err := channelStorage.Register(context.Background(), channel); err != nil {
+			logger.Log.Errorw("failed to register default channel", "channel", channel.Name, "err", err)
+			return
+		}
+	}
+
 	// application shutdown
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	// views registration
-	newsBot := botkit.New(botAPI)
+	newsBot := botkit.New(botAPI, sender.With(botkit.PriorityHigh))
 	newsBot.RegisterCmdView("start", bot.ViewCmdStart())
 	newsBot.RegisterCmdView("addsource", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdAddSource(sourceStorage)))
 	newsBot.RegisterCmdView("listsources", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdListSources(sourceStorage)))
 	newsBot.RegisterCmdView("editsource", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdEditSource(sourceStorage)))
 	newsBot.RegisterCmdView("deletesource", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdDeleteSource(sourceStorage)))
+	newsBot.RegisterCmdView("addchannel", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdAddChannel(channelStorage)))
+	newsBot.RegisterCmdView("editchannel", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdEditChannel(channelStorage)))
+	newsBot.RegisterCmdView("listchannels", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdListChannels(channelStorage)))
+	newsBot.RegisterCmdView("deletechannel", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdDeleteChannel(channelStorage)))
+	newsBot.RegisterCmdView("addroute", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdAddRoute(channelStorage)))
+	newsBot.RegisterCmdView("deleteroute", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdDeleteRoute(channelStorage)))
+	newsBot.RegisterCmdView("deadletters", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdDeadLetters(deliveryStorage)))
+	newsBot.RegisterCmdView("requeue", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdRequeue(deliveryStorage)))
+	newsBot.RegisterCmdView("previewtemplate", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdPreviewTemplate(articleSaver, channelStorage)))
+	newsBot.RegisterCmdView("sourcestats", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdSourceStats(sourceStorage, fetchRunStorage)))
+	newsBot.RegisterCmdView("editsummary", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdEditSummary(deliveryStorage, articleSaver, channelStorage)))
+	newsBot.RegisterCmdView("post", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdPost(postStorage, channelStorage)))
+	newsBot.RegisterCmdView("scheduled", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdScheduled(postStorage)))
+	newsBot.RegisterCmdView("addsubscriber", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdAddSubscriber(subscriberStore)))
+	newsBot.RegisterCmdView("listsubscribers", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdListSubscribers(subscriberStore)))
+	newsBot.RegisterCmdView("deletesubscriber", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdDeleteSubscriber(subscriberStore)))
+	newsBot.RegisterCmdView("enrichment", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdEnrichment(articleSaver)))
+	newsBot.RegisterCmdView("addrule", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdAddRule(ruleStorage)))
+	newsBot.RegisterCmdView("listrules", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdListRules(ruleStorage)))
+	newsBot.RegisterCmdView("deleterule", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdDeleteRule(ruleStorage)))
+	newsBot.RegisterCmdView("testextract", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdTestExtract(extractor, ruleStorage)))
+	newsBot.RegisterCmdView("status", middleware.AdminOnly(config.Get().Admins, bot.ViewCmdStatus(instanceStorage, 3*config.Get().LeaderHeartbeat)))
+	newsBot.RegisterCallbackView(render.VoteCallback, bot.ViewCallbackVote(voteStorage, articleSaver))
+	newsBot.RegisterCallbackView(render.ModerationCallback, middleware.AdminOnly(config.Get().Admins, bot.ViewCallbackModeration(deliveryStorage, n)))
+	newsBot.RegisterCallbackView(render.PostCallback, middleware.AdminOnly(config.Get().Admins, bot.ViewCallbackPost(postStorage)))
 
-	// start fetcher
-	go func(ctx context.Context) {
-		if err := f.Start(ctx); err != nil {
-			if !errors.Is(err, context.Canceled) {
-				logger.Log.Errorw("failed to start fetcher", "err", err)
-				return
-			}
+	// every replica fetches and posts sharing the work through leases, polling and email digests
+	// must run exactly once and run on the leader
+	instanceID := config.Get().InstanceID
+	if instanceID == "" {
+		hostname, _ := os.Hostname()
+		instanceID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
 
-			logger.Log.Info("fetcher stopped")
-		}
-	}(ctx)
+	elector := leader.New(
+		storage.NewLeaderLock(db, leader.LockKey),
+		instanceStorage,
+		instanceID,
+		config.Get().LeaderHeartbeat,
+	)
 
-	// start notifier
-	go func(ctx context.Context) {
-		if err := n.Start(ctx); err != nil {
-			if !errors.Is(err, context.Canceled) {
-				logger.Log.Errorw("failed to start notifier", "err", err)
-				return
-			}
+	elector.Register("sender", leader.EveryReplica, sender.Run)
+	elector.Register("fetcher", leader.EveryReplica, f.Start)
+	elector.Register("enricher", leader.EveryReplica, e.Start)
+	elector.Register("notifier", leader.EveryReplica, n.Start)
+	elector.Register("digests", leader.LeaderOnly, n.StartDigests)
+	elector.Register("bot", leader.LeaderOnly, newsBot.Run)
 
-			logger.Log.Info("n stopped")
+	// email digests are sent only if an smtp server is configured
+	if config.Get().SMTPHost != "" {
+		daily, err := schedule.ParseTimes(config.Get().EmailDailyAt, config.Get().NotificationTimezone)
+		if err != nil {
+			logger.Log.Errorw("invalid email_daily_at", "err", err)
+			return
 		}
-	}(ctx)
 
-	// start bot
-	if err := newsBot.Run(ctx); err != nil {
-		if !errors.Is(err, context.Canceled) {
-			logger.Log.Errorw("failed to run bot:", "err", err)
+		weekly, err := schedule.ParseTimes(config.Get().EmailWeeklyAt, config.Get().NotificationTimezone)
+		if err != nil {
+			logger.Log.Errorw("invalid email_weekly_at", "err", err)
 			return
 		}
-		logger.Log.Info("bot stopped")
+
+		digester := email.NewDigester(
+			articleSaver,
+			subscriberStore,
+			email.NewSMTPSender(email.SMTPConfig{
+				Host:     config.Get().SMTPHost,
+				Port:     config.Get().SMTPPort,
+				Username: config.Get().SMTPUsername,
+				Password: config.Get().SMTPPassword,
+				From:     config.Get().SMTPFrom,
+			}),
+			daily,
+			weekly,
+		)
+
+		elector.Register("email", leader.LeaderOnly, digester.Start)
+	}
+
+	logger.Log.Infow("starting instance", "instance", instanceID)
+
+	if err := elector.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		logger.Log.Errorw("failed to run instance", "err", err)
+		return
 	}
 
+	logger.Log.Info("instance stopped")
 }
@@ -4,6 +4,7 @@ go 1.23.4
 
 require (
 	github.com/SlyMarbo/rss v1.0.5
+	github.com/andybalholm/cascadia v1.3.3
 	github.com/cristalhq/aconfig v0.18.6
 	github.com/cristalhq/aconfig/aconfighcl v0.17.1
 	github.com/go-shiori/go-readability v0.0.0-20250217085726-9f5bf5ca7612
@@ -13,10 +14,10 @@ require (
 	github.com/samber/lo v1.49.1
 	github.com/sashabaranov/go-openai v1.38.1
 	go.uber.org/zap v1.27.0
+	golang.org/x/net v0.38.0
 )
 
 require (
-	github.com/andybalholm/cascadia v1.3.3 // indirect
 	github.com/araddon/dateparse v0.0.0-20210429162001-6b43995a97de // indirect
 	github.com/axgle/mahonia v0.0.0-20180208002826-3358181d7394 // indirect
 	github.com/go-shiori/dom v0.0.0-20230515143342-73569d674e1c // indirect
@@ -24,6 +25,5 @@ require (
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
 	go.uber.org/multierr v1.10.0 // indirect
-	golang.org/x/net v0.38.0 // indirect
 	golang.org/x/text v0.23.0 // indirect
 )
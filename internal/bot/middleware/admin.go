@@ -8,15 +8,27 @@ import (
 
 // AdminOnly middleware provides to commands for admins only
 func AdminOnly(adminsID []int64, next botkit.ViewFunc) botkit.ViewFunc {
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update tgbotapi.Update) error {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
 
-		// check if the person who sent the command is in the list of admins
-		for _, admin := range adminsID {
-			if admin == update.Message.From.ID {
-				return next(ctx, bot, update)
+		// check if the person who sent the command or pressed the button is in the list of admins
+		if from := update.SentFrom(); from != nil {
+			for _, admin := range adminsID {
+				if admin == from.ID {
+					return next(ctx, bot, update)
+				}
 			}
 		}
 
+		if update.CallbackQuery != nil {
+			if _, err := bot.Request(tgbotapi.NewCallback(
+				update.CallbackQuery.ID,
+				"У вас нет прав на выполнение данных действий",
+			)); err != nil {
+				return err
+			}
+			return nil
+		}
+
 		if _, err := bot.Send(tgbotapi.NewMessage(
 			update.Message.Chat.ID,
 			"У вас нет прав на выполнение данных действий",
@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+	"strconv"
+	"strings"
+)
+
+type ReviewStorage interface {
+	Review(ctx context.Context, articleID int64, channelID int64, status string) (bool, error)
+}
+
+type Poster interface {
+	PostNow(ctx context.Context, articleID int64, channelID int64) (bool, error)
+}
+
+// ViewCallbackModeration handles the buttons of a post waiting for review: approves or rejects the post,
+// posts it right away or explains how to edit its summary. The buttons are removed once the post is reviewed
+func ViewCallbackModeration(reviews ReviewStorage, poster Poster) botkit.ViewFunc {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		query := update.CallbackQuery
+
+		action, articleID, channelID, err := parseModerationData(query.Data)
+		if err != nil {
+			return err
+		}
+
+		var (
+			reviewed bool
+			answer   string
+		)
+
+		switch action {
+		case render.ModerationApprove:
+			reviewed, err = reviews.Review(ctx, articleID, channelID, model.DeliveryStatusApproved)
+			answer = "Одобрено, пост выйдет по расписанию канала"
+		case render.ModerationReject:
+			reviewed, err = reviews.Review(ctx, articleID, channelID, model.DeliveryStatusRejected)
+			answer = "Отклонено"
+		case render.ModerationPostNow:
+			reviewed, err = poster.PostNow(ctx, articleID, channelID)
+			answer = "Опубликовано"
+		case render.ModerationEdit:
+			if query.Message != nil {
+				if err := replyEditSummaryHelp(bot, query.Message.Chat.ID, articleID, channelID); err != nil {
+					return err
+				}
+			}
+
+			_, err := bot.Request(tgbotapi.NewCallback(query.ID, ""))
+			return err
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !reviewed {
+			answer = "Пост уже не на модерации"
+		}
+
+		if query.Message != nil {
+			edit := tgbotapi.NewEditMessageReplyMarkup(
+				query.Message.Chat.ID,
+				query.Message.MessageID,
+				tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}},
+			)
+
+			if _, err := bot.Request(edit); err != nil {
+				return err
+			}
+		}
+
+		if _, err := bot.Request(tgbotapi.NewCallback(query.ID, answer)); err != nil {
+			return err
+		}
+
+		return nil
+	}
+}
+
+func replyEditSummaryHelp(bot botkit.API, chatID int64, articleID int64, channelID int64) error {
+	reply := markup.NewBuilder().
+		Text("Чтобы изменить саммари, отправьте:\n").
+		Codef(`/editsummary {"article_id": %d, "channel_id": %d, "summary": "новый текст"}`, articleID, channelID).
+		Text("\n\nСаммари пишется в markdown, как его пишет модель.")
+
+	return botkit.Reply(bot, chatID, reply.Message())
+}
+
+// parseModerationData parses "mod:<action>:<article id>:<channel id>"
+func parseModerationData(data string) (string, int64, int64, error) {
+	parts := strings.Split(data, ":")
+	if len(parts) != 4 || parts[0] != render.ModerationCallback {
+		return "", 0, 0, fmt.Errorf("invalid moderation data %q", data)
+	}
+
+	switch parts[1] {
+	case render.ModerationApprove, render.ModerationReject, render.ModerationEdit, render.ModerationPostNow:
+	default:
+		return "", 0, 0, fmt.Errorf("unknown moderation action %q", parts[1])
+	}
+
+	articleID, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	channelID, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	return parts[1], articleID, channelID, nil
+}
@@ -0,0 +1,126 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ScheduledPostScheduler interface {
+	ScheduledPost(ctx context.Context, id int64) (*model.ScheduledPost, error)
+	Schedule(ctx context.Context, id int64, publishAt time.Time) (bool, error)
+	Cancel(ctx context.Context, id int64) (bool, error)
+}
+
+// ViewCallbackPost handles the buttons of admin composed posts: queues a draft to be published now
+// or at its time, or cancels a post that is not published yet
+func ViewCallbackPost(scheduler ScheduledPostScheduler) botkit.ViewFunc {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		query := update.CallbackQuery
+
+		action, postID, err := parsePostData(query.Data)
+		if err != nil {
+			return err
+		}
+
+		post, err := scheduler.ScheduledPost(ctx, postID)
+		if err != nil {
+			return err
+		}
+
+		var (
+			done   bool
+			answer string
+		)
+
+		switch action {
+		case render.PostPublishNow:
+			done, err = scheduler.Schedule(ctx, postID, time.Now().UTC())
+			answer = "Пост будет опубликован в течение минуты"
+		case render.PostSchedule:
+			done, err = scheduler.Schedule(ctx, postID, post.PublishAt)
+			answer = fmt.Sprintf("Пост будет опубликован %s", render.FormatPublishAt(*post))
+		case render.PostCancel:
+			done, err = scheduler.Cancel(ctx, postID)
+			answer = "Пост отменен"
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !done {
+			answer = "Пост уже опубликован или отменен"
+		}
+
+		if query.Message != nil && query.Message.ReplyMarkup != nil {
+			edit := tgbotapi.NewEditMessageReplyMarkup(
+				query.Message.Chat.ID,
+				query.Message.MessageID,
+				withoutPostButtons(*query.Message.ReplyMarkup, postID),
+			)
+
+			if _, err := bot.Request(edit); err != nil {
+				return err
+			}
+		}
+
+		if _, err := bot.Request(tgbotapi.NewCallback(query.ID, answer)); err != nil {
+			return err
+		}
+
+		return nil
+	}
+}
+
+// withoutPostButtons removes buttons of the post from the keyboard, the preview of a draft loses all its buttons
+// and the list of posts loses the row of the post
+func withoutPostButtons(keyboard tgbotapi.InlineKeyboardMarkup, postID int64) tgbotapi.InlineKeyboardMarkup {
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+
+	for _, row := range keyboard.InlineKeyboard {
+		var kept []tgbotapi.InlineKeyboardButton
+
+		for _, button := range row {
+			if button.CallbackData != nil {
+				if _, id, err := parsePostData(*button.CallbackData); err == nil && id == postID {
+					continue
+				}
+			}
+			kept = append(kept, button)
+		}
+
+		if len(kept) > 0 {
+			rows = append(rows, kept)
+		}
+	}
+
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+// parsePostData parses "post:<action>:<post id>"
+func parsePostData(data string) (string, int64, error) {
+	parts := strings.Split(data, ":")
+	if len(parts) != 3 || parts[0] != render.PostCallback {
+		return "", 0, fmt.Errorf("invalid post data %q", data)
+	}
+
+	switch parts[1] {
+	case render.PostPublishNow, render.PostSchedule, render.PostCancel:
+	default:
+		return "", 0, fmt.Errorf("unknown post action %q", parts[1])
+	}
+
+	postID, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return parts[1], postID, nil
+}
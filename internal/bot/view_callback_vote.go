@@ -0,0 +1,87 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+	"strconv"
+	"strings"
+)
+
+type VoteStorage interface {
+	Vote(ctx context.Context, articleID int64, userID int64, value int) (bool, error)
+	Votes(ctx context.Context, articleID int64) (model.Votes, error)
+}
+
+// ViewCallbackVote records a 👍/👎 vote of a reader and updates the counters on the post buttons in place
+func ViewCallbackVote(votes VoteStorage, articles ArticleProvider) botkit.ViewFunc {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		query := update.CallbackQuery
+
+		articleID, value, err := parseVoteData(query.Data)
+		if err != nil {
+			return err
+		}
+
+		voted, err := votes.Vote(ctx, articleID, query.From.ID, value)
+		if err != nil {
+			return err
+		}
+
+		counts, err := votes.Votes(ctx, articleID)
+		if err != nil {
+			return err
+		}
+
+		article, err := articles.ArticleById(ctx, articleID)
+		if err != nil {
+			return err
+		}
+
+		if query.Message != nil {
+			if keyboard := render.Keyboard(*article, true, counts); keyboard != nil {
+				edit := tgbotapi.NewEditMessageReplyMarkup(query.Message.Chat.ID, query.Message.MessageID, *keyboard)
+
+				if _, err := bot.Request(edit); err != nil {
+					return err
+				}
+			}
+		}
+
+		answer := "Спасибо за оценку!"
+		if !voted {
+			answer = "Оценка отменена"
+		}
+
+		if _, err := bot.Request(tgbotapi.NewCallback(query.ID, answer)); err != nil {
+			return err
+		}
+
+		return nil
+	}
+}
+
+// parseVoteData parses "vote:<article id>:<up|down>" into the article id and the vote value
+func parseVoteData(data string) (int64, int, error) {
+	parts := strings.Split(data, ":")
+	if len(parts) != 3 || parts[0] != render.VoteCallback {
+		return 0, 0, fmt.Errorf("invalid vote data %q", data)
+	}
+
+	articleID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch parts[2] {
+	case render.VoteUp:
+		return articleID, 1, nil
+	case render.VoteDown:
+		return articleID, -1, nil
+	default:
+		return 0, 0, fmt.Errorf("unknown vote %q", parts[2])
+	}
+}
@@ -0,0 +1,195 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+	"github.com/lostmyescape/news-tg-bot/internal/schedule"
+	"github.com/samber/lo"
+	"strings"
+	"time"
+)
+
+type ChannelStorage interface {
+	Add(ctx context.Context, channel model.Channel) (int64, error)
+}
+
+// ViewCmdAddChannel adds a destination channel with its own posting interval
+func ViewCmdAddChannel(storage ChannelStorage) botkit.ViewFunc {
+	type addChannelArgs struct {
+		Name                     string `json:"name"`
+		ChatID                   int64  `json:"chat_id"`
+		Interval                 string `json:"interval"`
+		Strategy                 string `json:"strategy"`
+		MaxPostsPerSourcePerHour int    `json:"max_posts_per_source_per_hour"`
+		Schedule                 string `json:"schedule"`
+		Timezone                 string `json:"timezone"`
+		Mode                     string `json:"mode"`
+		DigestTimes              string `json:"digest_times"`
+		DigestGroupBy            string `json:"digest_group_by"`
+		Template                 string `json:"template"`
+		Format                   string `json:"format"`
+		FeedbackButtons          bool   `json:"feedback_buttons"`
+		ModerationChatID         int64  `json:"moderation_chat_id"`
+		ModerationTimeout        string `json:"moderation_timeout"`
+		ModerationTimeoutAction  string `json:"moderation_timeout_action"`
+		Publisher                string `json:"publisher"`
+		WebhookURL               string `json:"webhook_url"`
+	}
+
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		args, err := botkit.ParseJSON[addChannelArgs](update.Message.CommandArguments())
+		if err != nil {
+			return err
+		}
+
+		interval, err := parsePostingInterval(args.Interval)
+		if err != nil {
+			return err
+		}
+
+		if _, err := schedule.Parse(args.Schedule, args.Timezone); err != nil {
+			return err
+		}
+
+		if args.Mode == model.ChannelModeDigest {
+			if _, err := schedule.ParseTimes(args.DigestTimes, args.Timezone); err != nil {
+				return err
+			}
+		}
+
+		digestGroupBy := lo.Ternary(args.DigestGroupBy != "", args.DigestGroupBy, model.DigestGroupBySource)
+		if err := validateDigestGroupBy(digestGroupBy); err != nil {
+			return err
+		}
+
+		format := lo.Ternary(args.Format != "", args.Format, render.FormatMarkdownV2)
+
+		if err := render.Validate(args.Template, format); err != nil {
+			return err
+		}
+
+		moderationTimeout := time.Hour
+		if args.ModerationTimeout != "" {
+			if moderationTimeout, err = parseModerationTimeout(args.ModerationTimeout); err != nil {
+				return err
+			}
+		}
+
+		moderationTimeoutAction := lo.Ternary(args.ModerationTimeoutAction != "", args.ModerationTimeoutAction, model.ModerationExpire)
+		if err := validateModerationTimeoutAction(moderationTimeoutAction); err != nil {
+			return err
+		}
+
+		channel := model.Channel{
+			Name:            args.Name,
+			ChatID:          args.ChatID,
+			PostingInterval: interval,
+
+			Strategy:                 lo.Ternary(args.Strategy != "", args.Strategy, "newest"),
+			MaxPostsPerSourcePerHour: args.MaxPostsPerSourcePerHour,
+			Schedule:                 args.Schedule,
+			Timezone:                 lo.Ternary(args.Timezone != "", args.Timezone, "UTC"),
+			Mode:                     lo.Ternary(args.Mode != "", args.Mode, model.ChannelModeStream),
+			DigestTimes:              args.DigestTimes,
+			DigestGroupBy:            digestGroupBy,
+			Template:                 args.Template,
+			Format:                   format,
+			FeedbackButtons:          args.FeedbackButtons,
+
+			ModerationChatID:        args.ModerationChatID,
+			ModerationTimeout:       moderationTimeout,
+			ModerationTimeoutAction: moderationTimeoutAction,
+
+			Publisher:  lo.Ternary(args.Publisher != "", args.Publisher, model.PublisherTelegram),
+			WebhookURL: args.WebhookURL,
+		}
+
+		if err := validatePublisher(channel); err != nil {
+			return err
+		}
+
+		channelID, err := storage.Add(ctx, channel)
+		if err != nil {
+			return err
+		}
+
+		reply := markup.NewBuilder().
+			Text("канал добавлен с ID: ").Codef("%d", channelID).
+			Text(". Используйте этот ID для настройки маршрутов.")
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
+
+func parsePostingInterval(src string) (time.Duration, error) {
+	interval, err := time.ParseDuration(src)
+	if err != nil {
+		return 0, err
+	}
+
+	if interval < time.Second {
+		return 0, errors.New("posting interval must be at least 1s")
+	}
+
+	return interval, nil
+}
+
+func parseModerationTimeout(src string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(src)
+	if err != nil {
+		return 0, err
+	}
+
+	if timeout < time.Minute {
+		return 0, errors.New("moderation timeout must be at least 1m")
+	}
+
+	return timeout, nil
+}
+
+// validatePublisher checks the destination of the channel: telegram channels need a chat,
+// webhook ones need an url and post articles one by one as they have no digests
+func validatePublisher(channel model.Channel) error {
+	switch channel.Publisher {
+	case model.PublisherTelegram:
+		if channel.ChatID == 0 {
+			return errors.New("chat_id is required for telegram channels")
+		}
+
+		return nil
+	case model.PublisherDiscord, model.PublisherSlack, model.PublisherWebhook:
+		if !strings.HasPrefix(channel.WebhookURL, "https://") && !strings.HasPrefix(channel.WebhookURL, "http://") {
+			return fmt.Errorf("webhook_url must be an http url for %s channels", channel.Publisher)
+		}
+
+		if channel.Mode == model.ChannelModeDigest {
+			return fmt.Errorf("%s channels don't support digests", channel.Publisher)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unknown publisher %q", channel.Publisher)
+	}
+}
+
+func validateDigestGroupBy(groupBy string) error {
+	if groupBy != model.DigestGroupBySource && groupBy != model.DigestGroupByTag {
+		return fmt.Errorf("unknown digest grouping %q, expected %q or %q", groupBy, model.DigestGroupBySource, model.DigestGroupByTag)
+	}
+
+	return nil
+}
+
+func validateModerationTimeoutAction(action string) error {
+	if action != model.ModerationApprove && action != model.ModerationExpire {
+		return fmt.Errorf("unknown moderation timeout action %q, expected %q or %q", action, model.ModerationApprove, model.ModerationExpire)
+	}
+
+	return nil
+}
@@ -2,10 +2,12 @@ package bot
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
 	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"time"
 )
 
 type SourceStorage interface {
@@ -14,18 +16,30 @@ type SourceStorage interface {
 
 func ViewCmdAddSource(storage SourceStorage) botkit.ViewFunc {
 	type addSourceArgs struct {
-		Name string `json:"name"`
-		URL  string `json:"url"`
+		Name     string `json:"name"`
+		URL      string `json:"url"`
+		Priority *int   `json:"priority"`
+		// FreshnessWindow is a duration like "72h", empty means the global window
+		FreshnessWindow string `json:"freshness_window"`
 	}
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update tgbotapi.Update) error {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
 		args, err := botkit.ParseJSON[addSourceArgs](update.Message.CommandArguments())
 		if err != nil {
 			return err
 		}
 
 		source := model.Source{
-			Name:    args.Name,
-			FeedURL: args.URL,
+			Name:     args.Name,
+			FeedURL:  args.URL,
+			Priority: 1,
+		}
+
+		if args.Priority != nil {
+			source.Priority = *args.Priority
+		}
+
+		if source.FreshnessWindow, err = parseFreshnessWindow(args.FreshnessWindow); err != nil {
+			return err
 		}
 
 		sourceID, err := storage.Add(ctx, source)
@@ -33,21 +47,28 @@ func ViewCmdAddSource(storage SourceStorage) botkit.ViewFunc {
 			return err
 		}
 
-		var (
-			msgText = fmt.Sprintf(
-				"источник добавлен с ID: `%d`\\. Используйте этот ID для управления источником\\.",
-				sourceID,
-			)
+		reply := markup.NewBuilder().
+			Text("источник добавлен с ID: ").Codef("%d", sourceID).
+			Text(". Используйте этот ID для управления источником.")
 
-			reply = tgbotapi.NewMessage(update.Message.Chat.ID, msgText)
-		)
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
 
-		reply.ParseMode = "MarkdownV2"
+// parseFreshnessWindow parses the freshness window of a source, an empty string means the global window
+func parseFreshnessWindow(src string) (time.Duration, error) {
+	if src == "" {
+		return 0, nil
+	}
 
-		if _, err := bot.Send(reply); err != nil {
-			return err
-		}
+	window, err := time.ParseDuration(src)
+	if err != nil {
+		return 0, err
+	}
 
-		return nil
+	if window <= 0 {
+		return 0, errors.New("freshness window must be positive")
 	}
+
+	return window, nil
 }
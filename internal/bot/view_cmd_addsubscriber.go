@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/samber/lo"
+	"net/mail"
+)
+
+type SubscriberStorage interface {
+	Add(ctx context.Context, subscriber model.Subscriber) (int64, error)
+}
+
+// ViewCmdAddSubscriber subscribes an email to daily or weekly digests, a subscribed email gets the new frequency
+func ViewCmdAddSubscriber(storage SubscriberStorage) botkit.ViewFunc {
+	type addSubscriberArgs struct {
+		Email     string `json:"email"`
+		Frequency string `json:"frequency"`
+	}
+
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		args, err := botkit.ParseJSON[addSubscriberArgs](update.Message.CommandArguments())
+		if err != nil {
+			return err
+		}
+
+		address, err := mail.ParseAddress(args.Email)
+		if err != nil {
+			return err
+		}
+
+		frequency := lo.Ternary(args.Frequency != "", args.Frequency, model.DigestDaily)
+		if frequency != model.DigestDaily && frequency != model.DigestWeekly {
+			return fmt.Errorf("unknown frequency %q, expected %q or %q", frequency, model.DigestDaily, model.DigestWeekly)
+		}
+
+		if _, err := storage.Add(ctx, model.Subscriber{Email: address.Address, Frequency: frequency}); err != nil {
+			return err
+		}
+
+		reply := markup.NewBuilder().
+			Text("Подписчик ").Code(address.Address).
+			Textf(" будет получать дайджест %s.", lo.Ternary(frequency == model.DigestWeekly, "раз в неделю", "каждый день"))
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+)
+
+type DeadLetterStorage interface {
+	Dead(ctx context.Context) ([]model.Delivery, error)
+	Requeue(ctx context.Context, id int64) (bool, error)
+}
+
+// ViewCmdDeadLetters lists deliveries that failed permanently or ran out of attempts
+func ViewCmdDeadLetters(storage DeadLetterStorage) botkit.ViewFunc {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		deliveries, err := storage.Dead(ctx)
+		if err != nil {
+			return err
+		}
+
+		reply := markup.NewBuilder().Textf("Недоставленные статьи (всего %d):", len(deliveries))
+
+		for _, delivery := range deliveries {
+			reply.Text("\n\n")
+			formatDeadDelivery(reply, delivery)
+		}
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
+
+// ViewCmdRequeue moves a dead delivery back to the queue
+func ViewCmdRequeue(storage DeadLetterStorage) botkit.ViewFunc {
+	type requeueArgs struct {
+		ID int64 `json:"id"`
+	}
+
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		args, err := botkit.ParseJSON[requeueArgs](update.Message.CommandArguments())
+		if err != nil {
+			return err
+		}
+
+		found, err := storage.Requeue(ctx, args.ID)
+		if err != nil {
+			return err
+		}
+
+		reply := markup.NewBuilder().Text("Доставка ").Codef("%d", args.ID).Text(" возвращена в очередь.")
+		if !found {
+			reply = markup.NewBuilder().Text("Недоставленная статья ").Codef("%d", args.ID).Text(" не найдена.")
+		}
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
+
+func formatDeadDelivery(b *markup.Builder, delivery model.Delivery) {
+	b.Bold(delivery.ArticleTitle).
+		Text("\nID: ").Codef("%d", delivery.ID).
+		Text("\nКанал: ").Codef("%d", delivery.ChannelID).
+		Textf("\nПопыток: %d\nОшибка: %s", delivery.Attempts, delivery.LastError)
+}
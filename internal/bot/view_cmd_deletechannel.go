@@ -0,0 +1,35 @@
+package bot
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+)
+
+type ChannelDeleter interface {
+	Delete(ctx context.Context, id int64) (int64, error)
+}
+
+// ViewCmdDeleteChannel deletes channel and its routes
+func ViewCmdDeleteChannel(storage ChannelDeleter) botkit.ViewFunc {
+	type deleteChannelArgs struct {
+		ID int64 `json:"id"`
+	}
+
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		args, err := botkit.ParseJSON[deleteChannelArgs](update.Message.CommandArguments())
+		if err != nil {
+			return err
+		}
+
+		channelID, err := storage.Delete(ctx, args.ID)
+		if err != nil {
+			return err
+		}
+
+		reply := markup.NewBuilder().Text("Канал ").Codef("%d", channelID).Text(" был удален.")
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
@@ -2,9 +2,9 @@ package bot
 
 import (
 	"context"
-	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
 	"github.com/lostmyescape/news-tg-bot/internal/model"
 )
 
@@ -18,7 +18,7 @@ func ViewCmdDeleteSource(storage SourceDeleter) botkit.ViewFunc {
 		ID int64 `json:"id"`
 	}
 
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update tgbotapi.Update) error {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
 		args, err := botkit.ParseJSON[deleteSourceStorage](update.Message.CommandArguments())
 		if err != nil {
 			return err
@@ -33,18 +33,8 @@ func ViewCmdDeleteSource(storage SourceDeleter) botkit.ViewFunc {
 			return err
 		}
 
-		var (
-			msgText = fmt.Sprintf("Источник `%d` был удален\\.", sourceID)
+		reply := markup.NewBuilder().Text("Источник ").Codef("%d", sourceID).Text(" был удален.")
 
-			reply = tgbotapi.NewMessage(update.Message.Chat.ID, msgText)
-		)
-
-		reply.ParseMode = "Markdownv2"
-
-		if _, err := bot.Send(reply); err != nil {
-			return err
-		}
-
-		return nil
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
 	}
 }
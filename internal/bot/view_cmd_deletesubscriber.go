@@ -0,0 +1,40 @@
+package bot
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+)
+
+type SubscriberDeleter interface {
+	Delete(ctx context.Context, email string) (bool, error)
+}
+
+// ViewCmdDeleteSubscriber unsubscribes an email from digests
+func ViewCmdDeleteSubscriber(storage SubscriberDeleter) botkit.ViewFunc {
+	type deleteSubscriberArgs struct {
+		Email string `json:"email"`
+	}
+
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		args, err := botkit.ParseJSON[deleteSubscriberArgs](update.Message.CommandArguments())
+		if err != nil {
+			return err
+		}
+
+		deleted, err := storage.Delete(ctx, args.Email)
+		if err != nil {
+			return err
+		}
+
+		reply := markup.NewBuilder().Text("Подписчик ").Code(args.Email)
+		if deleted {
+			reply.Text(" был удален.")
+		} else {
+			reply.Text(" не найден.")
+		}
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
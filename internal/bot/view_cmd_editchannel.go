@@ -0,0 +1,152 @@
+package bot
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+	"github.com/lostmyescape/news-tg-bot/internal/schedule"
+)
+
+type ChannelEditor interface {
+	Edit(ctx context.Context, channel model.Channel) (int64, error)
+	ChannelById(ctx context.Context, id int64) (*model.Channel, error)
+}
+
+// ViewCmdEditChannel changes channel settings, omitted settings are kept
+func ViewCmdEditChannel(storage ChannelEditor) botkit.ViewFunc {
+	type editChannelArgs struct {
+		ID                       int64   `json:"id"`
+		Name                     string  `json:"name"`
+		Interval                 string  `json:"interval"`
+		Strategy                 string  `json:"strategy"`
+		MaxPostsPerSourcePerHour *int    `json:"max_posts_per_source_per_hour"`
+		Schedule                 *string `json:"schedule"`
+		Timezone                 string  `json:"timezone"`
+		Mode                     string  `json:"mode"`
+		DigestTimes              string  `json:"digest_times"`
+		DigestGroupBy            string  `json:"digest_group_by"`
+		Template                 *string `json:"template"`
+		Format                   string  `json:"format"`
+		FeedbackButtons          *bool   `json:"feedback_buttons"`
+		ModerationChatID         *int64  `json:"moderation_chat_id"`
+		ModerationTimeout        string  `json:"moderation_timeout"`
+		ModerationTimeoutAction  string  `json:"moderation_timeout_action"`
+		WebhookURL               string  `json:"webhook_url"`
+	}
+
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		args, err := botkit.ParseJSON[editChannelArgs](update.Message.CommandArguments())
+		if err != nil {
+			return err
+		}
+
+		channel, err := storage.ChannelById(ctx, args.ID)
+		if err != nil {
+			return err
+		}
+
+		if args.Name != "" {
+			channel.Name = args.Name
+		}
+
+		if args.Interval != "" {
+			if channel.PostingInterval, err = parsePostingInterval(args.Interval); err != nil {
+				return err
+			}
+		}
+
+		if args.Strategy != "" {
+			channel.Strategy = args.Strategy
+		}
+
+		if args.MaxPostsPerSourcePerHour != nil {
+			channel.MaxPostsPerSourcePerHour = *args.MaxPostsPerSourcePerHour
+		}
+
+		if args.Schedule != nil {
+			channel.Schedule = *args.Schedule
+		}
+
+		if args.Timezone != "" {
+			channel.Timezone = args.Timezone
+		}
+
+		if args.Mode != "" {
+			channel.Mode = args.Mode
+		}
+
+		if args.DigestTimes != "" {
+			channel.DigestTimes = args.DigestTimes
+		}
+
+		if args.DigestGroupBy != "" {
+			if err := validateDigestGroupBy(args.DigestGroupBy); err != nil {
+				return err
+			}
+			channel.DigestGroupBy = args.DigestGroupBy
+		}
+
+		if args.Template != nil {
+			channel.Template = *args.Template
+		}
+
+		if args.Format != "" {
+			channel.Format = args.Format
+		}
+
+		if args.FeedbackButtons != nil {
+			channel.FeedbackButtons = *args.FeedbackButtons
+		}
+
+		if args.ModerationChatID != nil {
+			channel.ModerationChatID = *args.ModerationChatID
+		}
+
+		if args.ModerationTimeout != "" {
+			if channel.ModerationTimeout, err = parseModerationTimeout(args.ModerationTimeout); err != nil {
+				return err
+			}
+		}
+
+		if args.ModerationTimeoutAction != "" {
+			if err := validateModerationTimeoutAction(args.ModerationTimeoutAction); err != nil {
+				return err
+			}
+			channel.ModerationTimeoutAction = args.ModerationTimeoutAction
+		}
+
+		if args.WebhookURL != "" {
+			channel.WebhookURL = args.WebhookURL
+		}
+
+		if err := validatePublisher(*channel); err != nil {
+			return err
+		}
+
+		if err := render.Validate(channel.Template, channel.Format); err != nil {
+			return err
+		}
+
+		if _, err := schedule.Parse(channel.Schedule, channel.Timezone); err != nil {
+			return err
+		}
+
+		if channel.Mode == model.ChannelModeDigest {
+			if _, err := schedule.ParseTimes(channel.DigestTimes, channel.Timezone); err != nil {
+				return err
+			}
+		}
+
+		channelID, err := storage.Edit(ctx, *channel)
+		if err != nil {
+			return err
+		}
+
+		reply := markup.NewBuilder().Text("канал с ID: ").Codef("%d", channelID).Text(" был изменен.")
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
@@ -2,53 +2,76 @@ package bot
 
 import (
 	"context"
-	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
 	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
 )
 
 type EditStorage interface {
 	Edit(ctx context.Context, source model.Source) (int64, error)
+	SourceById(ctx context.Context, id int64) (*model.Source, error)
 }
 
-// ViewCmdEditSource change name and url from list
+// ViewCmdEditSource change name, url, priority and template from list, the priority and template are kept if omitted
 func ViewCmdEditSource(storage EditStorage) botkit.ViewFunc {
 	type editSourceArgs struct {
-		ID   int64  `json:"id"`
-		Name string `json:"name"`
-		URL  string `json:"url"`
+		ID       int64   `json:"id"`
+		Name     string  `json:"name"`
+		URL      string  `json:"url"`
+		Priority *int    `json:"priority"`
+		Template *string `json:"template"`
+		// FreshnessWindow is a duration like "72h", an empty string resets the source to the global window
+		FreshnessWindow *string `json:"freshness_window"`
 	}
 
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update tgbotapi.Update) error {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
 		args, err := botkit.ParseJSON[editSourceArgs](update.Message.CommandArguments())
 		if err != nil {
 			return err
 		}
 
+		current, err := storage.SourceById(ctx, args.ID)
+		if err != nil {
+			return err
+		}
+
 		source := model.Source{
-			ID:      args.ID,
-			Name:    args.Name,
-			FeedURL: args.URL,
+			ID:       args.ID,
+			Name:     args.Name,
+			FeedURL:  args.URL,
+			Priority: current.Priority,
+			Template: current.Template,
+
+			FreshnessWindow: current.FreshnessWindow,
 		}
 
-		sourceID, err := storage.Edit(ctx, source)
-		if err != nil {
-			return err
+		if args.Priority != nil {
+			source.Priority = *args.Priority
 		}
 
-		var (
-			msgText = fmt.Sprintf("источник с ID: `%d` был изменен\\.", sourceID)
+		if args.Template != nil {
+			source.Template = *args.Template
+		}
 
-			reply = tgbotapi.NewMessage(update.Message.Chat.ID, msgText)
-		)
+		if args.FreshnessWindow != nil {
+			if source.FreshnessWindow, err = parseFreshnessWindow(*args.FreshnessWindow); err != nil {
+				return err
+			}
+		}
 
-		reply.ParseMode = "Markdownv2"
+		if err := render.Validate(source.Template, render.FormatMarkdownV2); err != nil {
+			return err
+		}
 
-		if _, err := bot.Send(reply); err != nil {
+		sourceID, err := storage.Edit(ctx, source)
+		if err != nil {
 			return err
 		}
 
-		return nil
+		reply := markup.NewBuilder().Text("источник с ID: ").Codef("%d", sourceID).Text(" был изменен.")
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
 	}
 }
@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+	"strings"
+)
+
+type SummaryEditor interface {
+	EditSummary(ctx context.Context, articleID int64, channelID int64, summary string) (bool, error)
+	Delivery(ctx context.Context, articleID int64, channelID int64) (*model.Delivery, error)
+}
+
+// ViewCmdEditSummary replaces the summary of a post waiting for review and updates the post in the moderation chat
+func ViewCmdEditSummary(editor SummaryEditor, articles ArticleProvider, channels ChannelEditor) botkit.ViewFunc {
+	type editSummaryArgs struct {
+		ArticleID int64  `json:"article_id"`
+		ChannelID int64  `json:"channel_id"`
+		Summary   string `json:"summary"`
+	}
+
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		args, err := botkit.ParseJSON[editSummaryArgs](update.Message.CommandArguments())
+		if err != nil {
+			return err
+		}
+
+		if strings.TrimSpace(args.Summary) == "" {
+			return errors.New("summary must not be empty")
+		}
+
+		edited, err := editor.EditSummary(ctx, args.ArticleID, args.ChannelID, args.Summary)
+		if err != nil {
+			return err
+		}
+
+		if !edited {
+			reply := markup.NewBuilder().Text("Пост ").Codef("%d", args.ArticleID).Text(" уже не на модерации.")
+			return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+		}
+
+		delivery, err := editor.Delivery(ctx, args.ArticleID, args.ChannelID)
+		if err != nil {
+			return err
+		}
+
+		article, err := articles.ArticleById(ctx, args.ArticleID)
+		if err != nil {
+			return err
+		}
+
+		channel, err := channels.ChannelById(ctx, args.ChannelID)
+		if err != nil {
+			return err
+		}
+
+		message, err := render.ReviewPost(*channel, *article, delivery.Summary)
+		if err != nil {
+			return err
+		}
+
+		edit := tgbotapi.NewEditMessageText(channel.ModerationChatID, delivery.ReviewMessageID, message.Text)
+		edit.Entities = message.Entities
+		keyboard := render.ModerationKeyboard(article.ID, channel.ID)
+		edit.ReplyMarkup = &keyboard
+
+		if _, err := bot.Request(edit); err != nil {
+			return err
+		}
+
+		reply := markup.NewBuilder().Text("Саммари поста ").Codef("%d", args.ArticleID).Text(" изменено.")
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
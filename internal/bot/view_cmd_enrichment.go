@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type EnrichmentProgress interface {
+	ArticleById(ctx context.Context, id int64) (*model.Article, error)
+	EnrichmentCounts(ctx context.Context, since time.Time) (map[string]int, error)
+	NotEnriched(ctx context.Context, limit int) ([]model.Article, error)
+}
+
+// notEnrichedShown is the number of articles with failed enrichment listed by /enrichment
+const notEnrichedShown = 10
+
+// enrichmentStatuses are listed in the order articles go through them
+var enrichmentStatuses = []string{
+	model.EnrichmentStatusPending,
+	model.EnrichmentStatusEnriching,
+	model.EnrichmentStatusFailed,
+	model.EnrichmentStatusEnriched,
+	model.EnrichmentStatusDead,
+}
+
+// ViewCmdEnrichment shows the enrichment of a single article if article_id is given, otherwise
+// articles of the last day by enrichment status and the latest articles that failed enrichment
+func ViewCmdEnrichment(progress EnrichmentProgress) botkit.ViewFunc {
+	type enrichmentArgs struct {
+		ArticleID int64 `json:"article_id"`
+	}
+
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		if arg := strings.TrimSpace(update.Message.CommandArguments()); arg != "" {
+			args, err := botkit.ParseJSON[enrichmentArgs](arg)
+			if err != nil {
+				return err
+			}
+
+			article, err := progress.ArticleById(ctx, args.ArticleID)
+			if err != nil {
+				return err
+			}
+
+			reply := markup.NewBuilder()
+			formatEnrichment(reply, *article)
+
+			return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+		}
+
+		counts, err := progress.EnrichmentCounts(ctx, time.Now().UTC().Add(-24*time.Hour))
+		if err != nil {
+			return err
+		}
+
+		failed, err := progress.NotEnriched(ctx, notEnrichedShown)
+		if err != nil {
+			return err
+		}
+
+		reply := markup.NewBuilder().Bold("Статьи за сутки:")
+		for _, status := range enrichmentStatuses {
+			reply.Textf("\n%s: %d", status, counts[status])
+		}
+
+		if len(failed) > 0 {
+			reply.Text("\n\n").Bold("Последние ошибки:")
+		}
+
+		for _, article := range failed {
+			reply.Text("\n\n")
+			formatEnrichment(reply, article)
+		}
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
+
+func formatEnrichment(b *markup.Builder, article model.Article) {
+	b.Bold(article.Title).
+		Text("\nID: ").Codef("%d", article.ID).
+		Text("\nСтатус: ").Code(article.EnrichmentStatus).
+		Textf("\nПопыток: %d", article.EnrichmentAttempts)
+
+	if article.EnrichmentError != "" {
+		b.Textf("\nОшибка: %s", article.EnrichmentError)
+	}
+
+	if article.EnrichmentStatus != model.EnrichmentStatusEnriched {
+		return
+	}
+
+	b.Textf("\nОбогащена: %s", article.EnrichedAt.Format(time.DateTime)).
+		Textf("\nТекст: %d символов", utf8.RuneCountInString(article.Content))
+
+	if article.Language != "" {
+		b.Text("\nЯзык: ").Code(article.Language)
+	}
+
+	if article.ImageURL != "" {
+		b.Textf("\nКартинка: %s", article.ImageURL)
+	}
+
+	if article.GeneratedSummary != "" {
+		b.Textf("\nСаммари: %s", article.GeneratedSummary)
+	}
+}
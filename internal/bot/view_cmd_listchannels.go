@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/samber/lo"
+	"net/url"
+)
+
+type ChannelLister interface {
+	Channels(ctx context.Context) ([]model.Channel, error)
+	Routes(ctx context.Context) ([]model.Route, error)
+}
+
+// ViewCmdListChannels lists channels with the sources and tags routed to them
+func ViewCmdListChannels(lister ChannelLister) botkit.ViewFunc {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		channels, err := lister.Channels(ctx)
+		if err != nil {
+			return err
+		}
+
+		routes, err := lister.Routes(ctx)
+		if err != nil {
+			return err
+		}
+
+		routesByChannel := lo.GroupBy(routes, func(route model.Route) int64 { return route.ChannelID })
+
+		reply := markup.NewBuilder().Textf("Список каналов (всего %d):", len(channels))
+
+		for _, channel := range channels {
+			reply.Text("\n\n")
+			formatChannel(reply, channel, routesByChannel[channel.ID])
+		}
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
+
+func formatSchedule(channel model.Channel) string {
+	if channel.Mode == model.ChannelModeDigest {
+		groupBy := lo.Ternary(channel.DigestGroupBy == model.DigestGroupByTag, "тегам", "источникам")
+		return fmt.Sprintf("дайджест в %s (%s) по %s", channel.DigestTimes, channel.Timezone, groupBy)
+	}
+
+	if channel.Schedule == "" {
+		return "круглосуточно"
+	}
+
+	return fmt.Sprintf("%s (%s)", channel.Schedule, channel.Timezone)
+}
+
+func formatModeration(channel model.Channel) string {
+	if channel.ModerationChatID == 0 {
+		return "нет"
+	}
+
+	action := lo.Ternary(channel.ModerationTimeoutAction == model.ModerationApprove, "одобрение", "просрочка")
+
+	return fmt.Sprintf("в чате %d, через %s %s", channel.ModerationChatID, channel.ModerationTimeout, action)
+}
+
+// formatDestination shows the chat of a telegram channel or the host of a webhook, webhook urls carry secrets
+func formatDestination(channel model.Channel) markup.Message {
+	b := markup.NewBuilder()
+
+	if channel.Publisher == model.PublisherTelegram || channel.Publisher == "" {
+		return b.Text("Chat ID: ").Codef("%d", channel.ChatID).Message()
+	}
+
+	host := "?"
+	if u, err := url.Parse(channel.WebhookURL); err == nil {
+		host = u.Host
+	}
+
+	return b.Textf("Публикация: %s (", channel.Publisher).Code(host).Text(")").Message()
+}
+
+func formatChannel(b *markup.Builder, channel model.Channel, routes []model.Route) {
+	b.Bold(channel.Name).
+		Text("\nID: ").Codef("%d", channel.ID).
+		Text("\n").Append(formatDestination(channel)).
+		Textf(
+			"\nИнтервал: %s\nСтратегия: %s\nЛимит постов источника в час: %s\nРасписание: %s\nФормат: %s\nШаблон: %s\nКнопки оценки: %s\nМодерация: %s\nМаршруты: ",
+			channel.PostingInterval,
+			channel.Strategy,
+			lo.Ternary(channel.MaxPostsPerSourcePerHour > 0, fmt.Sprint(channel.MaxPostsPerSourcePerHour), "нет"),
+			formatSchedule(channel),
+			channel.Format,
+			lo.Ternary(channel.Template != "", "свой", "по умолчанию"),
+			lo.Ternary(channel.FeedbackButtons, "да", "нет"),
+			formatModeration(channel),
+		)
+
+	if len(routes) == 0 {
+		b.Text("все")
+		return
+	}
+
+	for i, route := range routes {
+		if i > 0 {
+			b.Text(", ")
+		}
+		if route.SourceID != 0 {
+			b.Text("источник ").Codef("%d", route.SourceID)
+		} else {
+			b.Text("тег ").Code(route.Tag)
+		}
+	}
+}
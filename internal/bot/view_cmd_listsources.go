@@ -2,13 +2,11 @@ package bot
 
 import (
 	"context"
-	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/lostmyescape/news-tg-bot/internal/botkit"
 	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
 	"github.com/lostmyescape/news-tg-bot/internal/model"
 	"github.com/samber/lo"
-	"strings"
 )
 
 type SourceLister interface {
@@ -16,39 +14,30 @@ type SourceLister interface {
 }
 
 func ViewCmdListSources(lister SourceLister) botkit.ViewFunc {
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update tgbotapi.Update) error {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
 		sources, err := lister.Sources(ctx)
 		if err != nil {
 			return err
 		}
 
-		var (
-			sourceInfos = lo.Map(sources, func(source model.Source, _ int) string {
-				return formatSource(source)
-			})
-			msgText = fmt.Sprintf(
-				"Список источников \\(всего %d\\):\n\n%s",
-				len(sources),
-				strings.Join(sourceInfos, "\n\n"),
-			)
-		)
-
-		reply := tgbotapi.NewMessage(update.Message.Chat.ID, msgText)
-		reply.ParseMode = "Markdownv2"
+		reply := markup.NewBuilder().Textf("Список источников (всего %d):", len(sources))
 
-		if _, err := bot.Send(reply); err != nil {
-			return err
+		for _, source := range sources {
+			reply.Text("\n\n")
+			formatSource(reply, source)
 		}
 
-		return nil
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
 	}
 }
 
-func formatSource(source model.Source) string {
-	return fmt.Sprintf(
-		"*%s*\nID: `%d`\nURL фида: %s",
-		markup.EscapeForMarkdown(source.Name),
-		source.ID,
-		markup.EscapeForMarkdown(source.FeedURL),
-	)
+func formatSource(b *markup.Builder, source model.Source) {
+	b.Bold(source.Name).
+		Text("\nID: ").Codef("%d", source.ID).
+		Textf(
+			"\nURL фида: %s\nПриоритет: %d\nОкно свежести: %s",
+			source.FeedURL,
+			source.Priority,
+			lo.Ternary(source.FreshnessWindow > 0, source.FreshnessWindow.String(), "общее"),
+		)
 }
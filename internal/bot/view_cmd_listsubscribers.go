@@ -0,0 +1,37 @@
+package bot
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/samber/lo"
+)
+
+type SubscriberLister interface {
+	Subscribers(ctx context.Context) ([]model.Subscriber, error)
+}
+
+// ViewCmdListSubscribers lists email digest subscribers
+func ViewCmdListSubscribers(lister SubscriberLister) botkit.ViewFunc {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		subscribers, err := lister.Subscribers(ctx)
+		if err != nil {
+			return err
+		}
+
+		reply := markup.NewBuilder().Textf("Подписчики email-дайджеста (всего %d):", len(subscribers))
+
+		for _, subscriber := range subscribers {
+			reply.Text("\n• ").Code(subscriber.Email).
+				Textf(", %s", lo.Ternary(subscriber.Frequency == model.DigestWeekly, "еженедельно", "ежедневно"))
+
+			if !subscriber.LastSentAt.IsZero() {
+				reply.Textf(", последний: %s", subscriber.LastSentAt.Format("02.01.2006 15:04"))
+			}
+		}
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
@@ -0,0 +1,134 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+	"strings"
+	"time"
+)
+
+type ScheduledPostStorage interface {
+	Add(ctx context.Context, post model.ScheduledPost) (int64, error)
+}
+
+// publishAtLayout is the layout of the publishing time in the time zone of the channel
+const publishAtLayout = "2006-01-02 15:04"
+
+// ViewCmdPost makes a draft of an admin composed post and sends its preview with buttons to publish it
+// now or at the given time. The post is the message the command replies to, text or media with a caption,
+// or the markdown text from the arguments
+func ViewCmdPost(storage ScheduledPostStorage, channels ChannelEditor) botkit.ViewFunc {
+	type postArgs struct {
+		ChannelID int64  `json:"channel_id"`
+		At        string `json:"at"`
+		Text      string `json:"text"`
+	}
+
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		args, err := botkit.ParseJSON[postArgs](update.Message.CommandArguments())
+		if err != nil {
+			return err
+		}
+
+		channel, err := channels.ChannelById(ctx, args.ChannelID)
+		if err != nil {
+			return err
+		}
+
+		if channel.Publisher != model.PublisherTelegram {
+			return fmt.Errorf("posts can be published to telegram channels only, %s is a %s channel", channel.Name, channel.Publisher)
+		}
+
+		post := model.ScheduledPost{
+			ChannelID:   channel.ID,
+			AuthorID:    update.Message.From.ID,
+			ChannelName: channel.Name,
+			ChatID:      channel.ChatID,
+			Timezone:    channel.Timezone,
+		}
+
+		switch {
+		case update.Message.ReplyToMessage != nil:
+			post.MediaType, post.MediaFileID, post.Content = postContent(update.Message.ReplyToMessage)
+		case strings.TrimSpace(args.Text) != "":
+			post.Content = markup.ParseCommonMark(args.Text)
+		}
+
+		if post.Content.Text == "" && post.MediaType == "" {
+			return errors.New("reply to a message with the post or pass its text")
+		}
+
+		if args.At != "" {
+			if post.PublishAt, err = parsePublishAt(args.At, channel.Timezone); err != nil {
+				return err
+			}
+		}
+
+		if post.ID, err = storage.Add(ctx, post); err != nil {
+			return err
+		}
+
+		header := markup.NewBuilder().
+			Text("Пост ").Codef("#%d", post.ID).
+			Textf(" для канала %s, публикация: %s. Предпросмотр:", channel.Name, render.FormatPublishAt(post))
+
+		if err := botkit.Reply(bot, update.Message.Chat.ID, header.Message()); err != nil {
+			return err
+		}
+
+		keyboard := render.ManualPostKeyboard(post)
+
+		if _, err := bot.Send(render.ManualPost(post, update.Message.Chat.ID, &keyboard)); err != nil {
+			return err
+		}
+
+		return nil
+	}
+}
+
+// postContent takes the text or the media with its caption from the message
+func postContent(msg *tgbotapi.Message) (string, string, markup.Message) {
+	caption := markup.Message{Text: msg.Caption, Entities: msg.CaptionEntities}
+
+	switch {
+	case len(msg.Photo) > 0:
+		// sizes go from the smallest to the largest
+		return model.MediaPhoto, msg.Photo[len(msg.Photo)-1].FileID, caption
+	case msg.Video != nil:
+		return model.MediaVideo, msg.Video.FileID, caption
+	case msg.Animation != nil:
+		// animations come with a document too, so they go first
+		return model.MediaAnimation, msg.Animation.FileID, caption
+	case msg.Document != nil:
+		return model.MediaDocument, msg.Document.FileID, caption
+	default:
+		return "", "", markup.Message{Text: msg.Text, Entities: msg.Entities}
+	}
+}
+
+// parsePublishAt parses the time in the time zone of the channel, the time must be in the future
+func parsePublishAt(src string, timezone string) (time.Time, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	at, err := time.ParseInLocation(publishAtLayout, src, loc)
+	if err != nil {
+		if at, err = time.Parse(time.RFC3339, src); err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q, expected %q or RFC 3339", src, publishAtLayout)
+		}
+	}
+
+	if !at.After(time.Now()) {
+		return time.Time{}, fmt.Errorf("time %q is in the past", src)
+	}
+
+	return at.UTC(), nil
+}
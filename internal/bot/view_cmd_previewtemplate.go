@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+)
+
+type ArticleProvider interface {
+	ArticleById(ctx context.Context, id int64) (*model.Article, error)
+}
+
+// ViewCmdPreviewTemplate renders a real article with a template and sends the result back,
+// the template and format default to the ones of the channel if channel_id is given
+func ViewCmdPreviewTemplate(articles ArticleProvider, channels ChannelEditor) botkit.ViewFunc {
+	type previewTemplateArgs struct {
+		ArticleID int64   `json:"article_id"`
+		ChannelID int64   `json:"channel_id"`
+		Template  *string `json:"template"`
+		Format    string  `json:"format"`
+	}
+
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		args, err := botkit.ParseJSON[previewTemplateArgs](update.Message.CommandArguments())
+		if err != nil {
+			return err
+		}
+
+		article, err := articles.ArticleById(ctx, args.ArticleID)
+		if err != nil {
+			return err
+		}
+
+		channel := model.Channel{Format: render.FormatMarkdownV2}
+
+		if args.ChannelID != 0 {
+			c, err := channels.ChannelById(ctx, args.ChannelID)
+			if err != nil {
+				return err
+			}
+			channel = *c
+		}
+
+		if args.Template != nil {
+			channel.Template = *args.Template
+			article.SourceTemplate = ""
+		}
+
+		if args.Format != "" {
+			channel.Format = args.Format
+		}
+
+		tmpl, err := render.TemplateFor(channel, *article)
+		if err != nil {
+			return replyPreviewError(bot, update, err)
+		}
+
+		message, err := tmpl.Render(render.NewPost(*article, render.PlainText(article.Summary)))
+		if err != nil {
+			return replyPreviewError(bot, update, err)
+		}
+
+		if _, err := bot.Send(message.Truncate(markup.MessageLimit).Config(update.Message.Chat.ID)); err != nil {
+			return replyPreviewError(bot, update, err)
+		}
+
+		return nil
+	}
+}
+
+func replyPreviewError(bot botkit.API, update tgbotapi.Update, err error) error {
+	reply := markup.NewBuilder().Textf("Шаблон не применим: %s", err)
+
+	return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+}
@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"strings"
+)
+
+type RouteStorage interface {
+	AddRoute(ctx context.Context, route model.Route) error
+	DeleteRoute(ctx context.Context, route model.Route) error
+}
+
+type routeArgs struct {
+	SourceID  int64  `json:"source_id"`
+	Tag       string `json:"tag"`
+	ChannelID int64  `json:"channel_id"`
+}
+
+// ViewCmdAddRoute routes articles of a source or with a tag to a channel
+func ViewCmdAddRoute(storage RouteStorage) botkit.ViewFunc {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		args, err := parseRouteArgs(update.Message.CommandArguments())
+		if err != nil {
+			return err
+		}
+
+		if err := storage.AddRoute(ctx, model.Route(args)); err != nil {
+			return err
+		}
+
+		return replyRoute(bot, update, "теперь публикуются в канал", args)
+	}
+}
+
+// ViewCmdDeleteRoute stops routing articles of a source or with a tag to a channel
+func ViewCmdDeleteRoute(storage RouteStorage) botkit.ViewFunc {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		args, err := parseRouteArgs(update.Message.CommandArguments())
+		if err != nil {
+			return err
+		}
+
+		if err := storage.DeleteRoute(ctx, model.Route(args)); err != nil {
+			return err
+		}
+
+		return replyRoute(bot, update, "больше не публикуются в канал", args)
+	}
+}
+
+// parseRouteArgs parses a route of either a source or a tag, tags are matched case-insensitively
+func parseRouteArgs(src string) (routeArgs, error) {
+	args, err := botkit.ParseJSON[routeArgs](src)
+	if err != nil {
+		return routeArgs{}, err
+	}
+
+	args.Tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(args.Tag), "#"))
+
+	if (args.SourceID == 0) == (args.Tag == "") {
+		return routeArgs{}, errors.New("either source_id or tag is required")
+	}
+
+	return args, nil
+}
+
+func replyRoute(bot botkit.API, update tgbotapi.Update, action string, args routeArgs) error {
+	reply := markup.NewBuilder()
+
+	if args.SourceID != 0 {
+		reply.Text("Статьи источника ").Codef("%d", args.SourceID)
+	} else {
+		reply.Text("Статьи с тегом ").Code(args.Tag)
+	}
+
+	reply.Textf(" %s ", action).Codef("%d", args.ChannelID).Text(".")
+
+	return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+}
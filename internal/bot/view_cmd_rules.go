@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/content"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"strings"
+)
+
+type ExtractionRuleStorage interface {
+	Add(ctx context.Context, rule model.ExtractionRule) (int64, error)
+	Delete(ctx context.Context, domain string) (bool, error)
+	Rules(ctx context.Context) ([]model.ExtractionRule, error)
+}
+
+// ViewCmdAddRule saves the extraction rule of a domain, an existing rule of the domain is replaced
+func ViewCmdAddRule(storage ExtractionRuleStorage) botkit.ViewFunc {
+	type addRuleArgs struct {
+		Domain  string `json:"domain"`
+		Content string `json:"content"`
+		Remove  string `json:"remove"`
+		Author  string `json:"author"`
+		Date    string `json:"date"`
+	}
+
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		args, err := botkit.ParseJSON[addRuleArgs](update.Message.CommandArguments())
+		if err != nil {
+			return err
+		}
+
+		rule := model.ExtractionRule{
+			Domain:  ruleDomain(args.Domain),
+			Content: args.Content,
+			Remove:  args.Remove,
+			Author:  args.Author,
+			Date:    args.Date,
+		}
+
+		if rule.Domain == "" {
+			return errors.New("domain is required")
+		}
+
+		if err := content.ValidateRule(rule); err != nil {
+			return err
+		}
+
+		if _, err := storage.Add(ctx, rule); err != nil {
+			return err
+		}
+
+		reply := markup.NewBuilder().Text("Правило для ").Code(rule.Domain).Text(" сохранено.")
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
+
+// ViewCmdListRules lists extraction rules
+func ViewCmdListRules(storage ExtractionRuleStorage) botkit.ViewFunc {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		rules, err := storage.Rules(ctx)
+		if err != nil {
+			return err
+		}
+
+		reply := markup.NewBuilder().Textf("Правила извлечения (всего %d):", len(rules))
+
+		for _, rule := range rules {
+			reply.Text("\n\n")
+			formatRule(reply, rule)
+		}
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
+
+// ViewCmdDeleteRule deletes the extraction rule of a domain
+func ViewCmdDeleteRule(storage ExtractionRuleStorage) botkit.ViewFunc {
+	type deleteRuleArgs struct {
+		Domain string `json:"domain"`
+	}
+
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		args, err := botkit.ParseJSON[deleteRuleArgs](update.Message.CommandArguments())
+		if err != nil {
+			return err
+		}
+
+		domain := ruleDomain(args.Domain)
+
+		deleted, err := storage.Delete(ctx, domain)
+		if err != nil {
+			return err
+		}
+
+		reply := markup.NewBuilder().Text("Правило для ").Code(domain)
+		if deleted {
+			reply.Text(" было удалено.")
+		} else {
+			reply.Text(" не найдено.")
+		}
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
+
+// ruleDomain normalizes the domain of a rule, a rule of example.com applies to www.example.com too
+func ruleDomain(domain string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+}
+
+func formatRule(b *markup.Builder, rule model.ExtractionRule) {
+	b.Bold(rule.Domain)
+
+	for _, selector := range []struct{ name, value string }{
+		{"Текст", rule.Content},
+		{"Удалить", rule.Remove},
+		{"Автор", rule.Author},
+		{"Дата", rule.Date},
+	} {
+		if selector.value != "" {
+			b.Textf("\n%s: ", selector.name).Code(selector.value)
+		}
+	}
+}
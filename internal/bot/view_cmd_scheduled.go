@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+	"unicode/utf8"
+)
+
+type ScheduledPostLister interface {
+	Pending(ctx context.Context, limit int) ([]model.ScheduledPost, error)
+}
+
+// scheduledListLimit keeps the list and its cancel buttons within one message
+const scheduledListLimit = 30
+
+var scheduledPostStatuses = map[string]string{
+	model.ScheduledPostStatusDraft:     "черновик",
+	model.ScheduledPostStatusScheduled: "запланирован",
+	model.ScheduledPostStatusSending:   "отправляется",
+	model.ScheduledPostStatusFailed:    "ошибка, будет повтор",
+}
+
+// ViewCmdScheduled lists admin composed posts that are not published yet with buttons to cancel them
+func ViewCmdScheduled(lister ScheduledPostLister) botkit.ViewFunc {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		posts, err := lister.Pending(ctx, scheduledListLimit)
+		if err != nil {
+			return err
+		}
+
+		if len(posts) == 0 {
+			return botkit.Reply(bot, update.Message.Chat.ID, markup.NewBuilder().Text("Запланированных постов нет.").Message())
+		}
+
+		var (
+			reply = markup.NewBuilder().Textf("Запланированные посты (%d):", len(posts))
+			rows  [][]tgbotapi.InlineKeyboardButton
+		)
+
+		for _, post := range posts {
+			reply.Text("\n\n").
+				Codef("#%d", post.ID).
+				Textf(" → %s, %s, %s", post.ChannelName, render.FormatPublishAt(post), scheduledPostStatuses[post.Status])
+
+			if post.MediaType != "" {
+				reply.Textf(" [%s]", post.MediaType)
+			}
+
+			if post.Content.Text != "" {
+				reply.Text("\n").Italic(preview(post.Content.Text))
+			}
+
+			if post.Status != model.ScheduledPostStatusSending {
+				rows = append(rows, tgbotapi.NewInlineKeyboardRow(render.CancelPostButton(post.ID)))
+			}
+		}
+
+		msg := reply.Message().Truncate(markup.MessageLimit).Config(update.Message.Chat.ID)
+		if len(rows) > 0 {
+			msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+		}
+
+		if _, err := bot.Send(msg); err != nil {
+			return err
+		}
+
+		return nil
+	}
+}
+
+// previewLength is the number of runes of the post text shown in the list
+const previewLength = 80
+
+func preview(text string) string {
+	if utf8.RuneCountInString(text) <= previewLength {
+		return text
+	}
+
+	return string([]rune(text)[:previewLength]) + "…"
+}
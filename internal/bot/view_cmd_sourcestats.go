@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SourceStatsProvider interface {
+	Stats(ctx context.Context, sourceID int64, since time.Time) (model.SourceStats, error)
+}
+
+// ViewCmdSourceStats shows fetch and posting statistics for the last day and week,
+// for a single source if an id is given or for every source otherwise
+func ViewCmdSourceStats(lister SourceLister, stats SourceStatsProvider) botkit.ViewFunc {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		sources, err := lister.Sources(ctx)
+		if err != nil {
+			return err
+		}
+
+		if arg := strings.TrimSpace(update.Message.CommandArguments()); arg != "" {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return err
+			}
+
+			sources = filterSources(sources, id)
+		}
+
+		if len(sources) == 0 {
+			return botkit.Reply(bot, update.Message.Chat.ID, markup.NewBuilder().Text("Источник не найден").Message())
+		}
+
+		var (
+			now   = time.Now().UTC()
+			reply = markup.NewBuilder()
+		)
+
+		for i, source := range sources {
+			day, err := stats.Stats(ctx, source.ID, now.Add(-24*time.Hour))
+			if err != nil {
+				return err
+			}
+
+			week, err := stats.Stats(ctx, source.ID, now.Add(-7*24*time.Hour))
+			if err != nil {
+				return err
+			}
+
+			if i > 0 {
+				reply.Text("\n\n")
+			}
+			formatSourceStats(reply, source, day, week)
+		}
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
+
+func filterSources(sources []model.Source, id int64) []model.Source {
+	for _, source := range sources {
+		if source.ID == id {
+			return []model.Source{source}
+		}
+	}
+
+	return nil
+}
+
+func formatSourceStats(b *markup.Builder, source model.Source, day, week model.SourceStats) {
+	b.Bold(source.Name).
+		Text("\nID: ").Codef("%d", source.ID).
+		Text("\n\n").Italic("За сутки:").Text("\n" + formatStatsPeriod(day, 1)).
+		Text("\n\n").Italic("За неделю:").Text("\n" + formatStatsPeriod(week, 7))
+}
+
+func formatStatsPeriod(stats model.SourceStats, days int) string {
+	return fmt.Sprintf(
+		"успешных загрузок: %s (%d из %d)\nновых статей в день: %.1f\nопубликовано: %s (%d из %d)\nпросрочено: %d\nмедианная задержка публикации: %s",
+		percent(stats.FetchesOK, stats.Fetches),
+		stats.FetchesOK,
+		stats.Fetches,
+		float64(stats.ItemsNew)/float64(days),
+		percent(stats.ArticlesPosted, stats.Articles),
+		stats.ArticlesPosted,
+		stats.Articles,
+		stats.ArticlesExpired,
+		stats.MedianDelay.Round(time.Second),
+	)
+}
+
+func percent(part, total int) string {
+	if total == 0 {
+		return "—"
+	}
+
+	return fmt.Sprintf("%.0f%%", float64(part)*100/float64(total))
+}
@@ -4,14 +4,12 @@ import (
 	"context"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
 )
 
 func ViewCmdStart() botkit.ViewFunc {
 
-	return func(ctx context.Context, bot *tgbotapi.BotAPI, update tgbotapi.Update) error {
-		if _, err := bot.Send(tgbotapi.NewMessage(update.FromChat().ID, "Hello, world!")); err != nil {
-			return err
-		}
-		return nil
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		return botkit.Reply(bot, update.FromChat().ID, markup.NewBuilder().Text("Hello, world!").Message())
 	}
 }
@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"strings"
+	"time"
+)
+
+type InstanceLister interface {
+	Instances(ctx context.Context, seenSince time.Time) ([]model.Instance, error)
+}
+
+// ViewCmdStatus shows running instances, the current leader and the components each of them runs.
+// Instances without a heartbeat for staleAfter are considered gone
+func ViewCmdStatus(lister InstanceLister, staleAfter time.Duration) botkit.ViewFunc {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		now := time.Now().UTC()
+
+		instances, err := lister.Instances(ctx, now.Add(-staleAfter))
+		if err != nil {
+			return err
+		}
+
+		reply := markup.NewBuilder().Textf("Экземпляры бота (всего %d):", len(instances))
+
+		leader := false
+
+		for _, instance := range instances {
+			reply.Text("\n• ").Code(instance.ID)
+
+			if instance.Leader {
+				leader = true
+				reply.Textf(" — лидер с %s", instance.LeaderSince.Format("02.01.2006 15:04:05"))
+			}
+
+			reply.Textf(", запущен %s, пульс %s назад", instance.StartedAt.Format("02.01.2006 15:04:05"),
+				now.Sub(instance.HeartbeatAt).Truncate(time.Second))
+
+			if len(instance.Components) > 0 {
+				reply.Textf("\n  компоненты: %s", strings.Join(instance.Components, ", "))
+			}
+		}
+
+		if !leader {
+			reply.Text("\n\nЛидер не выбран, задачи лидера не выполняются.")
+		}
+
+		return botkit.Reply(bot, update.Message.Chat.ID, reply.Message())
+	}
+}
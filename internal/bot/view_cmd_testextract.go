@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/logger"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type PageExtractor interface {
+	Page(ctx context.Context, link string) (model.ArticleContent, error)
+}
+
+type ExtractionRuleFinder interface {
+	RuleForHost(ctx context.Context, host string) (*model.ExtractionRule, error)
+}
+
+const (
+	// testExtractTextLimit keeps the extracted text within a single message
+	testExtractTextLimit = 3000
+	// testExtractTimeout covers every attempt to download the page, far more than an update is handled in
+	testExtractTimeout = 90 * time.Second
+)
+
+// ViewCmdTestExtract downloads the page and shows what is extracted from it with the current rules,
+// so admins can tune the rule of the site. The page is downloaded in the background with retries,
+// the result is sent when it's ready
+func ViewCmdTestExtract(extractor PageExtractor, rules ExtractionRuleFinder) botkit.ViewFunc {
+	return func(ctx context.Context, bot botkit.API, update tgbotapi.Update) error {
+		link := strings.TrimSpace(update.Message.CommandArguments())
+
+		pageURL, err := url.Parse(link)
+		if err != nil {
+			return err
+		}
+
+		if pageURL.Host == "" {
+			return errors.New("usage: /testextract <url>")
+		}
+
+		rule, err := rules.RuleForHost(ctx, pageURL.Hostname())
+		if err != nil {
+			return err
+		}
+
+		chatID := update.Message.Chat.ID
+
+		go func() {
+			extractCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), testExtractTimeout)
+			defer cancel()
+
+			reply := markup.NewBuilder()
+
+			extracted, err := extractor.Page(extractCtx, link)
+			if err != nil {
+				reply.Text("Не удалось извлечь страницу: ").Code(err.Error())
+			} else {
+				testExtractResult(reply, rule, extracted)
+			}
+
+			if err := botkit.Reply(bot, chatID, reply.Message()); err != nil {
+				logger.Log.Errorw("failed to reply with extracted page", "url", link, "err", err)
+			}
+		}()
+
+		return botkit.Reply(bot, chatID, markup.NewBuilder().Text("Загружаю страницу…").Message())
+	}
+}
+
+// testExtractResult describes what is extracted from the page with the rule
+func testExtractResult(reply *markup.Builder, rule *model.ExtractionRule, extracted model.ArticleContent) {
+	reply.Text("Правило: ")
+	if rule != nil {
+		reply.Code(rule.Domain)
+	} else {
+		reply.Text("нет, используется readability")
+	}
+
+	if extracted.Author != "" {
+		reply.Textf("\nАвтор: %s", extracted.Author)
+	}
+
+	if !extracted.PublishedAt.IsZero() {
+		reply.Textf("\nДата: %s", extracted.PublishedAt.Format(time.DateTime))
+	}
+
+	if extracted.Language != "" {
+		reply.Text("\nЯзык: ").Code(extracted.Language)
+	}
+
+	if extracted.ImageURL != "" {
+		reply.Textf("\nКартинка: %s", extracted.ImageURL)
+	}
+
+	text := extracted.Text
+	if runes := []rune(text); len(runes) > testExtractTextLimit {
+		text = string(runes[:testExtractTextLimit]) + "…"
+	}
+
+	reply.Textf("\n\nТекст (%d символов):\n", utf8.RuneCountInString(extracted.Text)).Text(text)
+}
@@ -5,19 +5,24 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/lostmyescape/news-tg-bot/logger"
 	"runtime/debug"
+	"strings"
 	"time"
 )
 
 type Bot struct {
-	api      *tgbotapi.BotAPI
-	cmdViews map[string]ViewFunc
+	api           *tgbotapi.BotAPI
+	replies       API
+	cmdViews      map[string]ViewFunc
+	callbackViews map[string]ViewFunc
 }
 
-type ViewFunc func(ctx context.Context, bot *tgbotapi.BotAPI, update tgbotapi.Update) error
+type ViewFunc func(ctx context.Context, bot API, update tgbotapi.Update) error
 
-func New(api *tgbotapi.BotAPI) *Bot {
+// New creates a bot that receives updates through api and sends replies through replies
+func New(api *tgbotapi.BotAPI, replies API) *Bot {
 	return &Bot{
-		api: api,
+		api:     api,
+		replies: replies,
 	}
 }
 
@@ -29,7 +34,25 @@ func (b *Bot) RegisterCmdView(cmd string, view ViewFunc) {
 	b.cmdViews[cmd] = view
 }
 
-// Run runs bot, check an updates from channel
+// RegisterCallbackView registers a view for callback queries of inline buttons whose data is "<prefix>:..."
+func (b *Bot) RegisterCallbackView(prefix string, view ViewFunc) {
+	if b.callbackViews == nil {
+		b.callbackViews = make(map[string]ViewFunc)
+	}
+
+	b.callbackViews[prefix] = view
+}
+
+const (
+	// pollTimeout is the long polling timeout, a stopped bot may finish a poll that long
+	// before another instance can poll without a conflict
+	pollTimeout = 10
+	// pollRetryDelay is the delay before polling again after an error
+	pollRetryDelay = 3 * time.Second
+)
+
+// Run runs bot, check an updates from channel. Polling stops with the context,
+// so the bot can be run again, e.g. by the next leader
 func (b *Bot) Run(ctx context.Context) error {
 
 	// webhook deleted to perform updates via api
@@ -39,14 +62,15 @@ func (b *Bot) Run(ctx context.Context) error {
 		return err
 	}
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-
-	updates := b.api.GetUpdatesChan(u)
+	updates := b.poll(ctx)
 
 	for {
 		select {
-		case update := <-updates:
+		case update, ok := <-updates:
+			if !ok {
+				return ctx.Err()
+			}
+
 			updateCtx, updateCancel := context.WithTimeout(ctx, 5*time.Second)
 			b.handleUpdate(updateCtx, update)
 			updateCancel()
@@ -56,6 +80,56 @@ func (b *Bot) Run(ctx context.Context) error {
 	}
 }
 
+// poll receives updates by long polling until the context is done, unlike api.GetUpdatesChan
+// it can be stopped and started again
+func (b *Bot) poll(ctx context.Context) <-chan tgbotapi.Update {
+	updates := make(chan tgbotapi.Update)
+
+	go func() {
+		defer close(updates)
+
+		u := tgbotapi.NewUpdate(0)
+		u.Timeout = pollTimeout
+
+		// telegram confirms updates on the next poll with a greater offset, a poll without waiting
+		// confirms the handled ones so the next instance doesn't get them again
+		defer func() {
+			if u.Offset > 0 {
+				u.Timeout = 0
+				_, _ = b.api.GetUpdates(u)
+			}
+		}()
+
+		for ctx.Err() == nil {
+			received, err := b.api.GetUpdates(u)
+			if err != nil {
+				logger.Log.Errorw("failed to get updates", "err", err)
+
+				select {
+				case <-time.After(pollRetryDelay):
+				case <-ctx.Done():
+				}
+
+				continue
+			}
+
+			for _, update := range received {
+				if update.UpdateID >= u.Offset {
+					u.Offset = update.UpdateID + 1
+				}
+
+				select {
+				case updates <- update:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return updates
+}
+
 // handleUpdate processes a message from the user
 func (b *Bot) handleUpdate(ctx context.Context, update tgbotapi.Update) {
 	defer func() {
@@ -64,6 +138,11 @@ func (b *Bot) handleUpdate(ctx context.Context, update tgbotapi.Update) {
 		}
 	}()
 
+	if update.CallbackQuery != nil {
+		b.handleCallbackQuery(ctx, update)
+		return
+	}
+
 	if update.Message == nil || !update.Message.IsCommand() {
 		return
 	}
@@ -83,13 +162,33 @@ func (b *Bot) handleUpdate(ctx context.Context, update tgbotapi.Update) {
 
 	view = cmdView
 
-	if err := view(ctx, b.api, update); err != nil {
+	if err := view(ctx, b.replies, update); err != nil {
 		logger.Log.Errorw("failed to handle update:", "err", err)
 
-		if _, err := b.api.Send(
+		if _, err := b.replies.Send(
 			tgbotapi.NewMessage(update.Message.Chat.ID, "internal error"),
 		); err != nil {
 			logger.Log.Errorw("failed to handle update:", "err", err)
 		}
 	}
 }
+
+// handleCallbackQuery processes a press of an inline button, the view is expected to answer the query
+func (b *Bot) handleCallbackQuery(ctx context.Context, update tgbotapi.Update) {
+	prefix, _, _ := strings.Cut(update.CallbackQuery.Data, ":")
+
+	view, ok := b.callbackViews[prefix]
+	if !ok {
+		return
+	}
+
+	if err := view(ctx, b.replies, update); err != nil {
+		logger.Log.Errorw("failed to handle callback query:", "err", err)
+
+		if _, err := b.replies.Request(
+			tgbotapi.NewCallback(update.CallbackQuery.ID, "internal error"),
+		); err != nil {
+			logger.Log.Errorw("failed to handle callback query:", "err", err)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package markup
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Telegram limits of a message text and of a media caption in UTF-16 code units
+const (
+	MessageLimit = 4096
+	CaptionLimit = 1024
+)
+
+// Entity types, see https://core.telegram.org/bots/api#messageentity
+const (
+	EntityBold          = "bold"
+	EntityItalic        = "italic"
+	EntityUnderline     = "underline"
+	EntityStrikethrough = "strikethrough"
+	EntitySpoiler       = "spoiler"
+	EntityCode          = "code"
+	EntityPre           = "pre"
+	EntityTextLink      = "text_link"
+	EntityBlockquote    = "blockquote"
+)
+
+const ellipsis = "…"
+
+// Message is a text with formatting entities, offsets and lengths of entities are in UTF-16 code units
+// as telegram counts them, so the message is sent as is without a parse mode
+type Message struct {
+	Text     string
+	Entities []tgbotapi.MessageEntity
+}
+
+// Builder builds a message piece by piece keeping entity offsets in sync with the text
+type Builder struct {
+	sb       strings.Builder
+	length   int
+	entities []tgbotapi.MessageEntity
+}
+
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Text appends plain text
+func (b *Builder) Text(s string) *Builder {
+	b.sb.WriteString(s)
+	b.length += utf16Len(s)
+
+	return b
+}
+
+// Textf appends formatted plain text
+func (b *Builder) Textf(format string, args ...any) *Builder {
+	return b.Text(fmt.Sprintf(format, args...))
+}
+
+func (b *Builder) Bold(s string) *Builder {
+	return b.styled(EntityBold, s)
+}
+
+func (b *Builder) Italic(s string) *Builder {
+	return b.styled(EntityItalic, s)
+}
+
+func (b *Builder) Code(s string) *Builder {
+	return b.styled(EntityCode, s)
+}
+
+// Codef appends formatted monospace text, e.g. ids
+func (b *Builder) Codef(format string, args ...any) *Builder {
+	return b.styled(EntityCode, fmt.Sprintf(format, args...))
+}
+
+// Pre appends a code block in the language, the language may be empty
+func (b *Builder) Pre(s string, language string) *Builder {
+	return b.Wrap(tgbotapi.MessageEntity{Type: EntityPre, Language: language}, func(b *Builder) { b.Text(s) })
+}
+
+// Link appends text linking to the url
+func (b *Builder) Link(s string, url string) *Builder {
+	return b.Wrap(tgbotapi.MessageEntity{Type: EntityTextLink, URL: url}, func(b *Builder) { b.Text(s) })
+}
+
+func (b *Builder) styled(entityType string, s string) *Builder {
+	return b.Wrap(tgbotapi.MessageEntity{Type: entityType}, func(b *Builder) { b.Text(s) })
+}
+
+// Wrap applies the entity to everything fn appends, wraps may be nested
+func (b *Builder) Wrap(entity tgbotapi.MessageEntity, fn func(b *Builder)) *Builder {
+	start := b.length
+	fn(b)
+
+	if b.length > start {
+		entity.Offset = start
+		entity.Length = b.length - start
+		b.entities = append(b.entities, entity)
+	}
+
+	return b
+}
+
+// Append appends a built message
+func (b *Builder) Append(m Message) *Builder {
+	start := b.length
+	b.Text(m.Text)
+
+	for _, entity := range m.Entities {
+		entity.Offset += start
+		b.entities = append(b.entities, entity)
+	}
+
+	return b
+}
+
+// Len returns the length of the text built so far in UTF-16 code units
+func (b *Builder) Len() int {
+	return b.length
+}
+
+// Message returns the built message, entities are ordered by offset with outer entities first
+func (b *Builder) Message() Message {
+	entities := append([]tgbotapi.MessageEntity(nil), b.entities...)
+
+	sort.SliceStable(entities, func(i, j int) bool {
+		if entities[i].Offset != entities[j].Offset {
+			return entities[i].Offset < entities[j].Offset
+		}
+		return entities[i].Length > entities[j].Length
+	})
+
+	return Message{Text: b.sb.String(), Entities: entities}
+}
+
+// Len returns the length of the text in UTF-16 code units
+func (m Message) Len() int {
+	return utf16Len(m.Text)
+}
+
+// Config returns a config to send the message to the chat
+func (m Message) Config(chatID int64) tgbotapi.MessageConfig {
+	msg := tgbotapi.NewMessage(chatID, m.Text)
+	msg.Entities = m.Entities
+
+	return msg
+}
+
+// Truncate cuts the message to the limit adding an ellipsis, the cut goes at a word boundary if there is one
+// in the second half of the message and never goes through a link
+func (m Message) Truncate(limit int) Message {
+	units := utf16.Encode([]rune(m.Text))
+	if len(units) <= limit {
+		return m
+	}
+
+	if limit <= utf16Len(ellipsis) {
+		return Message{Text: ellipsis}
+	}
+
+	cut := limit - utf16Len(ellipsis)
+
+	if space := lastIndexOf(units, 0, cut+1, ' ', '\n'); space > cut/2 {
+		cut = space
+	}
+
+	cut = m.safeCut(units, 0, cut)
+	truncated := m.slice(units, 0, trimRight(units, 0, cut))
+
+	return NewBuilder().Append(truncated).Text(ellipsis).Message()
+}
+
+// Split splits the message into messages within the limit, cuts go at paragraph boundaries
+// if possible, then at line breaks, then at spaces. Entities crossing a cut are split into both parts
+func (m Message) Split(limit int) []Message {
+	var (
+		units = utf16.Encode([]rune(m.Text))
+		parts []Message
+		from  = trimLeft(units, 0)
+	)
+
+	for len(units)-from > limit {
+		end := from + limit
+		cut := lastParagraphBreak(units, from, end)
+
+		if cut <= from {
+			cut = lastIndexOf(units, from, end+1, '\n')
+		}
+
+		if cut <= from {
+			cut = lastIndexOf(units, from, end+1, ' ')
+		}
+
+		if cut <= from {
+			cut = end
+		}
+
+		cut = m.safeCut(units, from, cut)
+
+		if part := m.slice(units, from, trimRight(units, from, cut)); part.Text != "" {
+			parts = append(parts, part)
+		}
+
+		from = trimLeft(units, cut)
+	}
+
+	if from < len(units) {
+		parts = append(parts, m.slice(units, from, trimRight(units, from, len(units))))
+	}
+
+	return parts
+}
+
+// safeCut moves the cut before a link it goes through unless the link starts the part,
+// and off the middle of a surrogate pair
+func (m Message) safeCut(units []uint16, from, cut int) int {
+	for _, entity := range m.Entities {
+		if entity.Type != EntityTextLink && entity.Type != "url" {
+			continue
+		}
+
+		if entity.Offset > from && entity.Offset < cut && cut < entity.Offset+entity.Length {
+			cut = entity.Offset
+		}
+	}
+
+	if cut > from && cut < len(units) && units[cut-1] >= 0xd800 && units[cut-1] < 0xdc00 {
+		cut--
+	}
+
+	return cut
+}
+
+// slice returns the part of the message between from and to with entities clipped to it
+func (m Message) slice(units []uint16, from, to int) Message {
+	part := Message{Text: string(utf16.Decode(units[from:to]))}
+
+	for _, entity := range m.Entities {
+		start := max(entity.Offset, from)
+		end := min(entity.Offset+entity.Length, to)
+
+		if end <= start {
+			continue
+		}
+
+		entity.Offset = start - from
+		entity.Length = end - start
+		part.Entities = append(part.Entities, entity)
+	}
+
+	return part
+}
+
+func lastParagraphBreak(units []uint16, from, to int) int {
+	for i := min(to, len(units)-1) - 1; i > from; i-- {
+		if units[i] == '\n' && units[i+1] == '\n' {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// lastIndexOf returns the last index in [from, to) of any of the characters or -1
+func lastIndexOf(units []uint16, from, to int, chars ...uint16) int {
+	for i := min(to, len(units)) - 1; i >= from; i-- {
+		for _, c := range chars {
+			if units[i] == c {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+func trimLeft(units []uint16, from int) int {
+	for from < len(units) && isSpace(units[from]) {
+		from++
+	}
+
+	return from
+}
+
+func trimRight(units []uint16, from, to int) int {
+	for to > from && isSpace(units[to-1]) {
+		to--
+	}
+
+	return to
+}
+
+func isSpace(u uint16) bool {
+	return u == ' ' || u == '\n' || u == '\t'
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+
+	return n
+}
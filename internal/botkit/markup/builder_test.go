@@ -0,0 +1,271 @@
+package markup
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// entityText returns the text the entity covers
+func entityText(m Message, entity tgbotapi.MessageEntity) string {
+	units := utf16.Encode([]rune(m.Text))
+	return string(utf16.Decode(units[entity.Offset : entity.Offset+entity.Length]))
+}
+
+func TestBuilderUTF16Offsets(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(b *Builder)
+		want  []tgbotapi.MessageEntity
+	}{
+		{
+			name:  "ascii",
+			build: func(b *Builder) { b.Text("Hi ").Bold("there") },
+			want:  []tgbotapi.MessageEntity{{Type: EntityBold, Offset: 3, Length: 5}},
+		},
+		{
+			name:  "cyrillic is one unit per letter",
+			build: func(b *Builder) { b.Text("Привет ").Italic("мир") },
+			want:  []tgbotapi.MessageEntity{{Type: EntityItalic, Offset: 7, Length: 3}},
+		},
+		{
+			name:  "emoji are surrogate pairs",
+			build: func(b *Builder) { b.Text("🔥🔥 ").Code("go") },
+			want:  []tgbotapi.MessageEntity{{Type: EntityCode, Offset: 5, Length: 2}},
+		},
+		{
+			name: "nested entities, outer first",
+			build: func(b *Builder) {
+				b.Text("👉").Wrap(tgbotapi.MessageEntity{Type: EntityBlockquote}, func(b *Builder) {
+					b.Bold("a").Text(" ").Link("😀b", "https://example.com")
+				})
+			},
+			want: []tgbotapi.MessageEntity{
+				{Type: EntityBlockquote, Offset: 2, Length: 5},
+				{Type: EntityBold, Offset: 2, Length: 1},
+				{Type: EntityTextLink, Offset: 4, Length: 3, URL: "https://example.com"},
+			},
+		},
+		{
+			name:  "empty wraps are dropped",
+			build: func(b *Builder) { b.Text("x").Bold("") },
+			want:  nil,
+		},
+		{
+			name: "appended messages are shifted",
+			build: func(b *Builder) {
+				b.Text("🙂 ").Append(NewBuilder().Text("a ").Bold("b").Message())
+			},
+			want: []tgbotapi.MessageEntity{{Type: EntityBold, Offset: 5, Length: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuilder()
+			tt.build(b)
+			m := b.Message()
+
+			if !reflect.DeepEqual(m.Entities, tt.want) {
+				t.Errorf("entities = %+v, want %+v", m.Entities, tt.want)
+			}
+
+			if b.Len() != len(utf16.Encode([]rune(m.Text))) {
+				t.Errorf("Len() = %d, want %d", b.Len(), len(utf16.Encode([]rune(m.Text))))
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  Message
+		limit    int
+		want     string
+		wantEnts []string
+	}{
+		{
+			name:    "short message is kept",
+			message: NewBuilder().Text("hello").Message(),
+			limit:   10,
+			want:    "hello",
+		},
+		{
+			name:    "cut at a word boundary",
+			message: NewBuilder().Text("hello wonderful world").Message(),
+			limit:   18,
+			want:    "hello wonderful…",
+		},
+		{
+			name:    "no surrogate pair is split",
+			message: NewBuilder().Text("ab😀😀😀").Message(),
+			limit:   6,
+			want:    "ab😀…",
+		},
+		{
+			name:     "entities are clipped",
+			message:  NewBuilder().Bold("bold text here").Message(),
+			limit:    11,
+			want:     "bold text…",
+			wantEnts: []string{"bold text"},
+		},
+		{
+			name:     "a link is not cut through",
+			message:  NewBuilder().Text("read ").Link("the long article", "https://example.com").Message(),
+			limit:    15,
+			want:     "read…",
+			wantEnts: nil,
+		},
+		{
+			name:    "limit below the ellipsis",
+			message: NewBuilder().Text("hello").Message(),
+			limit:   1,
+			want:    "…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.message.Truncate(tt.limit)
+
+			if got.Text != tt.want {
+				t.Errorf("Truncate(%d) = %q, want %q", tt.limit, got.Text, tt.want)
+			}
+
+			if got.Len() > tt.limit {
+				t.Errorf("Truncate(%d) is %d long", tt.limit, got.Len())
+			}
+
+			var ents []string
+			for _, entity := range got.Entities {
+				ents = append(ents, entityText(got, entity))
+			}
+
+			if !reflect.DeepEqual(ents, tt.wantEnts) {
+				t.Errorf("entities cover %q, want %q", ents, tt.wantEnts)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		message Message
+		limit   int
+		want    []string
+	}{
+		{
+			name:    "fits",
+			message: NewBuilder().Text("one two").Message(),
+			limit:   10,
+			want:    []string{"one two"},
+		},
+		{
+			name:    "paragraphs first",
+			message: NewBuilder().Text("first line\nsecond\n\nthird").Message(),
+			limit:   20,
+			want:    []string{"first line\nsecond", "third"},
+		},
+		{
+			name:    "then lines",
+			message: NewBuilder().Text("first line\nsecond line").Message(),
+			limit:   15,
+			want:    []string{"first line", "second line"},
+		},
+		{
+			name:    "then spaces",
+			message: NewBuilder().Text("one two three four").Message(),
+			limit:   9,
+			want:    []string{"one two", "three", "four"},
+		},
+		{
+			name:    "hard cut off surrogate pairs",
+			message: NewBuilder().Text("😀😀😀").Message(),
+			limit:   3,
+			want:    []string{"😀", "😀", "😀"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := tt.message.Split(tt.limit)
+
+			var got []string
+			for _, part := range parts {
+				got = append(got, part.Text)
+
+				if part.Len() > tt.limit {
+					t.Errorf("part %q is %d long, over %d", part.Text, part.Len(), tt.limit)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split(%d) = %q, want %q", tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitKeepsEntitiesInBothParts(t *testing.T) {
+	m := NewBuilder().Text("🔥 ").Bold("bold words span the cut").Message()
+
+	parts := m.Split(12)
+	if len(parts) < 2 {
+		t.Fatalf("Split() = %d parts, want at least 2", len(parts))
+	}
+
+	var covered []string
+	for _, part := range parts {
+		for _, entity := range part.Entities {
+			if entity.Type != EntityBold {
+				t.Errorf("entity %s, want bold", entity.Type)
+			}
+			covered = append(covered, entityText(part, entity))
+		}
+	}
+
+	if strings.Join(covered, " ") != "bold words span the cut" {
+		t.Errorf("bold covers %q, want the whole bold text", covered)
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	m := NewBuilder().
+		Text("🔥 ").Bold("жирный").Text(" и ").Italic("курсив").Text(" ").
+		Link("ссылка (1)", "https://example.com/a_(b)").Text(" ").Code("x := `1`").
+		Message()
+
+	parsedV2, err := ParseMarkdownV2(m.MarkdownV2())
+	if err != nil {
+		t.Fatalf("ParseMarkdownV2() error = %v", err)
+	}
+
+	parsedHTML, err := ParseHTML(m.HTML())
+	if err != nil {
+		t.Fatalf("ParseHTML() error = %v", err)
+	}
+
+	for name, got := range map[string]Message{"MarkdownV2": parsedV2, "HTML": parsedHTML} {
+		if !reflect.DeepEqual(got, m) {
+			t.Errorf("%s round trip = %+v, want %+v", name, got, m)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{"*bold", "`code", "```pre", "[link](https://example.com"} {
+		if _, err := ParseMarkdownV2(src); err == nil {
+			t.Errorf("ParseMarkdownV2(%q) = nil error, want error", src)
+		}
+	}
+
+	for _, src := range []string{"<b>bold", "<marquee>x</marquee>", "<br/>"} {
+		if _, err := ParseHTML(src); err == nil {
+			t.Errorf("ParseHTML(%q) = nil error, want error", src)
+		}
+	}
+}
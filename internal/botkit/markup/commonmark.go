@@ -0,0 +1,287 @@
+package markup
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	headingLine    = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*$`)
+	bulletLine     = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	orderedLine    = regexp.MustCompile(`^(\s*)(\d{1,9})[.)]\s+(.*)$`)
+	thematicBreak  = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	fenceLine      = regexp.MustCompile("^\\s*(```+|~~~+)\\s*(\\S*)")
+	allowedSchemes = []string{"http://", "https://", "tg://", "mailto:"}
+	inlineEmphasis = []struct {
+		delimiter  string
+		entityType string
+	}{
+		// longer delimiters go first so "**" is not taken for two italics
+		{"**", EntityBold},
+		{"__", EntityBold},
+		{"~~", EntityStrikethrough},
+		{"*", EntityItalic},
+		{"_", EntityItalic},
+	}
+)
+
+// ParseCommonMark converts a subset of CommonMark, as language models usually write it, into a message:
+// bold, italic, strikethrough, inline code, code blocks, links, headings, lists and quotes.
+// Anything else, including unclosed emphasis and links with unsafe urls, is kept as plain text
+func ParseCommonMark(src string) Message {
+	var (
+		b          = NewBuilder()
+		lines      = strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+		paragraph  []string
+		quote      []string
+		blockCount int
+	)
+
+	separate := func() {
+		if blockCount > 0 {
+			b.Text("\n\n")
+		}
+		blockCount++
+	}
+
+	flushParagraph := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+
+		separate()
+		parseInline(b, strings.Join(paragraph, "\n"))
+		paragraph = nil
+	}
+
+	flushQuote := func() {
+		if len(quote) == 0 {
+			return
+		}
+
+		separate()
+		b.Wrap(tgbotapi.MessageEntity{Type: EntityBlockquote}, func(b *Builder) {
+			parseInline(b, strings.Join(quote, "\n"))
+		})
+		quote = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+
+		if rest, ok := strings.CutPrefix(strings.TrimLeft(line, " "), ">"); ok {
+			flushParagraph()
+			quote = append(quote, strings.TrimPrefix(rest, " "))
+			continue
+		}
+		flushQuote()
+
+		if fence := fenceLine.FindStringSubmatch(line); fence != nil {
+			flushParagraph()
+
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence[1]); i++ {
+				code = append(code, lines[i])
+			}
+
+			separate()
+			b.Pre(strings.Join(code, "\n"), fence[2])
+			continue
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			flushParagraph()
+		case thematicBreak.MatchString(line):
+			flushParagraph()
+		case headingLine.MatchString(line):
+			flushParagraph()
+			separate()
+			b.Wrap(tgbotapi.MessageEntity{Type: EntityBold}, func(b *Builder) {
+				parseInline(b, headingLine.FindStringSubmatch(line)[1])
+			})
+		case bulletLine.MatchString(line):
+			m := bulletLine.FindStringSubmatch(line)
+			paragraph = append(paragraph, listIndent(m[1])+"• "+m[2])
+		case orderedLine.MatchString(line):
+			m := orderedLine.FindStringSubmatch(line)
+			paragraph = append(paragraph, listIndent(m[1])+m[2]+". "+m[3])
+		default:
+			paragraph = append(paragraph, strings.TrimSpace(line))
+		}
+	}
+
+	flushParagraph()
+	flushQuote()
+
+	return b.Message()
+}
+
+// listIndent keeps nesting of list items visible, two spaces of markdown indentation become one level
+func listIndent(indent string) string {
+	return strings.Repeat("  ", len(strings.ReplaceAll(indent, "\t", "    "))/2)
+}
+
+// parseInline appends text with inline markup, it never fails and keeps what it doesn't recognize as is
+func parseInline(b *Builder, src string) {
+	var text strings.Builder
+
+	flush := func() {
+		b.Text(text.String())
+		text.Reset()
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+
+		switch {
+		case c == '\\' && i+1 < len(src) && isASCIIPunct(src[i+1]):
+			text.WriteByte(src[i+1])
+			i += 2
+			continue
+		case c == '`':
+			run := len(src[i:]) - len(strings.TrimLeft(src[i:], "`"))
+			delimiter := src[i : i+run]
+
+			if end := strings.Index(src[i+run:], delimiter); end > 0 {
+				flush()
+				b.Code(strings.TrimSpace(src[i+run : i+run+end]))
+				i += run + end + run
+				continue
+			}
+
+			text.WriteString(delimiter)
+			i += run
+			continue
+		case c == '[':
+			if label, url, size, ok := parseLink(src[i:]); ok {
+				flush()
+				b.Wrap(tgbotapi.MessageEntity{Type: EntityTextLink, URL: url}, func(b *Builder) { parseInline(b, label) })
+				i += size
+				continue
+			}
+		case c == '<':
+			if end := strings.IndexByte(src[i:], '>'); end > 0 && isSafeURL(src[i+1:i+end]) {
+				flush()
+				b.Text(src[i+1 : i+end])
+				i += end + 1
+				continue
+			}
+		case c == '*' || c == '_' || c == '~':
+			if inner, entityType, size, ok := parseEmphasis(src, i); ok {
+				flush()
+				b.Wrap(tgbotapi.MessageEntity{Type: entityType}, func(b *Builder) { parseInline(b, inner) })
+				i += size
+				continue
+			}
+		}
+
+		_, size := utf8.DecodeRuneInString(src[i:])
+		text.WriteString(src[i : i+size])
+		i += size
+	}
+
+	flush()
+}
+
+// parseEmphasis matches emphasis starting at i, an opening delimiter must be followed by a non-space,
+// a closing one preceded by a non-space, and underscores don't work inside words
+func parseEmphasis(src string, i int) (string, string, int, bool) {
+	for _, e := range inlineEmphasis {
+		d := e.delimiter
+		if !strings.HasPrefix(src[i:], d) {
+			continue
+		}
+
+		start := i + len(d)
+		if start >= len(src) || src[start] == ' ' || src[start] == '\n' || (d[0] == '_' && i > 0 && isWordByte(src[i-1])) {
+			return "", "", 0, false
+		}
+
+		for from := start + 1; from <= len(src)-len(d); {
+			end := strings.Index(src[from:], d)
+			if end < 0 {
+				break
+			}
+			end += from
+
+			after := end + len(d)
+			closes := src[end-1] != ' ' && src[end-1] != '\n' &&
+				!(d[0] == '_' && after < len(src) && isWordByte(src[after])) &&
+				// "*" must not be a half of "**"
+				!(len(d) == 1 && after < len(src) && src[after] == d[0])
+
+			if closes {
+				return src[start:end], e.entityType, after - i, true
+			}
+
+			from = end + len(d)
+		}
+
+		return "", "", 0, false
+	}
+
+	return "", "", 0, false
+}
+
+// parseLink matches [label](url) at the start of src, urls of unsafe schemes are not links
+func parseLink(src string) (string, string, int, bool) {
+	closeLabel := strings.Index(src, "](")
+	if closeLabel < 1 || strings.Contains(src[1:closeLabel], "\n") {
+		return "", "", 0, false
+	}
+
+	depth := 0
+	for i := closeLabel + 2; i < len(src); i++ {
+		switch src[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+				continue
+			}
+
+			url := strings.TrimSpace(src[closeLabel+2 : i])
+			// an optional title goes after the url
+			if space := strings.IndexAny(url, " \t"); space >= 0 {
+				url = url[:space]
+			}
+
+			if !isSafeURL(url) {
+				return "", "", 0, false
+			}
+
+			return src[1:closeLabel], url, i + 1, true
+		case '\n':
+			return "", "", 0, false
+		}
+	}
+
+	return "", "", 0, false
+}
+
+func isSafeURL(url string) bool {
+	if strings.ContainsAny(url, " \n<>") {
+		return false
+	}
+
+	for _, scheme := range allowedSchemes {
+		if strings.HasPrefix(strings.ToLower(url), scheme) && len(url) > len(scheme) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isWordByte(c byte) bool {
+	return c >= utf8.RuneSelf || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
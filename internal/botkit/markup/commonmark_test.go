@@ -0,0 +1,122 @@
+package markup
+
+import (
+	"reflect"
+	"testing"
+)
+
+type span struct {
+	entityType string
+	text       string
+	url        string
+}
+
+func spans(m Message) []span {
+	var got []span
+	for _, entity := range m.Entities {
+		got = append(got, span{entityType: entity.Type, text: entityText(m, entity), url: entity.URL})
+	}
+
+	return got
+}
+
+func TestParseCommonMark(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		wantText  string
+		wantSpans []span
+	}{
+		{
+			name:      "emphasis",
+			src:       "**Жирный** и *курсив*, __тоже__ и ~~нет~~",
+			wantText:  "Жирный и курсив, тоже и нет",
+			wantSpans: []span{{EntityBold, "Жирный", ""}, {EntityItalic, "курсив", ""}, {EntityBold, "тоже", ""}, {EntityStrikethrough, "нет", ""}},
+		},
+		{
+			name:      "offsets after emoji",
+			src:       "🚀🚀 **go**",
+			wantText:  "🚀🚀 go",
+			wantSpans: []span{{EntityBold, "go", ""}},
+		},
+		{
+			name:      "underscores inside words",
+			src:       "snake_case_name and _it_",
+			wantText:  "snake_case_name and it",
+			wantSpans: []span{{EntityItalic, "it", ""}},
+		},
+		{
+			name:      "unclosed emphasis is text",
+			src:       "2 * 3 and **open",
+			wantText:  "2 * 3 and **open",
+			wantSpans: nil,
+		},
+		{
+			name:      "links",
+			src:       "[docs](https://go.dev/doc \"title\") and [bad](javascript:alert(1))",
+			wantText:  "docs and [bad](javascript:alert(1))",
+			wantSpans: []span{{EntityTextLink, "docs", "https://go.dev/doc"}},
+		},
+		{
+			name:      "inline code keeps markers",
+			src:       "run `a*b*c` now",
+			wantText:  "run a*b*c now",
+			wantSpans: []span{{EntityCode, "a*b*c", ""}},
+		},
+		{
+			name:      "heading, list and quote",
+			src:       "# Title\n\n- one\n  - two\n1. first\n\n> quoted **text**",
+			wantText:  "Title\n\n• one\n  • two\n1. first\n\nquoted text",
+			wantSpans: []span{{EntityBold, "Title", ""}, {EntityBlockquote, "quoted text", ""}, {EntityBold, "text", ""}},
+		},
+		{
+			name:      "code block",
+			src:       "text\n```go\nfmt.Println(\"*\")\n```",
+			wantText:  "text\n\nfmt.Println(\"*\")",
+			wantSpans: []span{{EntityPre, "fmt.Println(\"*\")", ""}},
+		},
+		{
+			name:      "escapes",
+			src:       `\*not italic\*`,
+			wantText:  "*not italic*",
+			wantSpans: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseCommonMark(tt.src)
+
+			if got.Text != tt.wantText {
+				t.Errorf("text = %q, want %q", got.Text, tt.wantText)
+			}
+
+			if gotSpans := spans(got); !reflect.DeepEqual(gotSpans, tt.wantSpans) {
+				t.Errorf("entities = %+v, want %+v", gotSpans, tt.wantSpans)
+			}
+		})
+	}
+}
+
+func TestSerializers(t *testing.T) {
+	m := ParseCommonMark("**a_b** [x](https://e.com) `c` <d>")
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "MarkdownV2", got: m.MarkdownV2(), want: "*a\\_b* [x](https://e.com) `c` <d\\>"},
+		{name: "HTML", got: m.HTML(), want: "<b>a_b</b> <a href=\"https://e.com\">x</a> <code>c</code> &lt;d&gt;"},
+		{name: "Mrkdwn", got: m.Mrkdwn(), want: "*a_b* <https://e.com|x> `c` &lt;d&gt;"},
+		{name: "DiscordMarkdown", got: m.DiscordMarkdown(), want: "**a\\_b** [x](<https://e.com>) `c` \\<d\\>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("%s() = %q, want %q", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -46,3 +46,15 @@ var (
 func EscapeForMarkdown(src string) string {
 	return replacer.Replace(src)
 }
+
+var urlReplacer = strings.NewReplacer(
+	"\\",
+	"\\\\",
+	")",
+	"\\)",
+)
+
+// EscapeForMarkdownURL escapes the url part of an inline link
+func EscapeForMarkdownURL(src string) string {
+	return urlReplacer.Replace(src)
+}
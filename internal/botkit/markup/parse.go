@@ -0,0 +1,336 @@
+package markup
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golang.org/x/net/html"
+	"io"
+	"strings"
+)
+
+// openEntity is an entity whose end is not reached yet
+type openEntity struct {
+	entity tgbotapi.MessageEntity
+	marker string
+	start  int
+}
+
+type entityStack struct {
+	b     *Builder
+	stack []openEntity
+}
+
+func (s *entityStack) open(marker string, entity tgbotapi.MessageEntity) {
+	s.stack = append(s.stack, openEntity{entity: entity, marker: marker, start: s.b.length})
+}
+
+func (s *entityStack) isOpen(marker string) bool {
+	for _, open := range s.stack {
+		if open.marker == marker {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *entityStack) top() *openEntity {
+	if len(s.stack) == 0 {
+		return nil
+	}
+
+	return &s.stack[len(s.stack)-1]
+}
+
+// close closes the innermost entity, it must have the marker
+func (s *entityStack) close(marker string) error {
+	top := s.top()
+	if top == nil || top.marker != marker {
+		return fmt.Errorf("unexpected end of %q", marker)
+	}
+
+	s.stack = s.stack[:len(s.stack)-1]
+
+	if s.b.length > top.start {
+		top.entity.Offset = top.start
+		top.entity.Length = s.b.length - top.start
+		s.b.entities = append(s.b.entities, top.entity)
+	}
+
+	return nil
+}
+
+func (s *entityStack) done() error {
+	if top := s.top(); top != nil {
+		return fmt.Errorf("%q is not closed", top.marker)
+	}
+
+	return nil
+}
+
+var markdownMarkers = []struct {
+	marker     string
+	entityType string
+}{
+	// longer markers go first, "__" is always underline as in telegram
+	{"||", EntitySpoiler},
+	{"__", EntityUnderline},
+	{"*", EntityBold},
+	{"_", EntityItalic},
+	{"~", EntityStrikethrough},
+}
+
+// ParseMarkdownV2 parses text in telegram MarkdownV2 into a message. Reserved characters that are not escaped
+// are kept as text instead of failing as telegram does, entities that are not closed are an error
+func ParseMarkdownV2(src string) (Message, error) {
+	var (
+		b         = NewBuilder()
+		s         = &entityStack{b: b}
+		lineStart = true
+	)
+
+	for i := 0; i < len(src); {
+		if lineStart && src[i] == '>' {
+			if !s.isOpen(">") {
+				s.open(">", tgbotapi.MessageEntity{Type: EntityBlockquote})
+			}
+			i++
+			lineStart = false
+			continue
+		}
+		lineStart = false
+
+		switch {
+		case src[i] == '\r':
+			// telegram ignores "\r", it separates markers like "_\r__"
+			i++
+			continue
+		case src[i] == '\\' && i+1 < len(src):
+			r, size := decodeRune(src[i+1:])
+			b.Text(r)
+			i += 1 + size
+			continue
+		case src[i] == '\n':
+			if s.isOpen(">") && !strings.HasPrefix(src[i+1:], ">") {
+				if err := s.close(">"); err != nil {
+					return Message{}, err
+				}
+			}
+			b.Text("\n")
+			i++
+			lineStart = true
+			continue
+		case strings.HasPrefix(src[i:], "```"):
+			end := strings.Index(src[i+3:], "```")
+			if end < 0 {
+				return Message{}, fmt.Errorf("\"```\" is not closed")
+			}
+
+			code := src[i+3 : i+3+end]
+			language := ""
+			if first, rest, ok := strings.Cut(code, "\n"); ok && !strings.ContainsAny(first, " \t") {
+				language, code = first, rest
+			}
+			code = strings.TrimSuffix(code, "\n")
+
+			b.Pre(unescapeCode(code), language)
+			i += 3 + end + 3
+			continue
+		case src[i] == '`':
+			end := indexUnescaped(src[i+1:], '`')
+			if end < 0 {
+				return Message{}, fmt.Errorf("\"`\" is not closed")
+			}
+
+			b.Code(unescapeCode(src[i+1 : i+1+end]))
+			i += 1 + end + 1
+			continue
+		case src[i] == '[':
+			s.open("[", tgbotapi.MessageEntity{Type: EntityTextLink})
+			i++
+			continue
+		case src[i] == ']' && s.isOpen("[") && strings.HasPrefix(src[i+1:], "("):
+			end := indexUnescaped(src[i+2:], ')')
+			if end < 0 {
+				return Message{}, fmt.Errorf("link url is not closed")
+			}
+
+			s.top().entity.URL = unescapeCode(src[i+2 : i+2+end])
+			if err := s.close("["); err != nil {
+				return Message{}, err
+			}
+			i += 2 + end + 1
+			continue
+		}
+
+		if marker, entityType, ok := markdownMarker(src[i:]); ok {
+			var err error
+			if s.isOpen(marker) {
+				err = s.close(marker)
+			} else {
+				s.open(marker, tgbotapi.MessageEntity{Type: entityType})
+			}
+			if err != nil {
+				return Message{}, err
+			}
+			i += len(marker)
+			continue
+		}
+
+		r, size := decodeRune(src[i:])
+		b.Text(r)
+		i += size
+	}
+
+	if s.isOpen(">") {
+		if err := s.close(">"); err != nil {
+			return Message{}, err
+		}
+	}
+
+	if err := s.done(); err != nil {
+		return Message{}, err
+	}
+
+	return b.Message(), nil
+}
+
+func markdownMarker(src string) (string, string, bool) {
+	for _, m := range markdownMarkers {
+		if strings.HasPrefix(src, m.marker) {
+			return m.marker, m.entityType, true
+		}
+	}
+
+	return "", "", false
+}
+
+func decodeRune(src string) (string, int) {
+	for i := range src {
+		if i > 0 {
+			return src[:i], i
+		}
+	}
+
+	return src, len(src)
+}
+
+// indexUnescaped returns the index of the first c not escaped with a backslash
+func indexUnescaped(src string, c byte) int {
+	for i := 0; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case c:
+			return i
+		}
+	}
+
+	return -1
+}
+
+// unescapeCode removes backslashes in code and urls, where only "`", ")" and "\" are escaped
+func unescapeCode(src string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\\' && i+1 < len(src) {
+			i++
+		}
+		sb.WriteByte(src[i])
+	}
+
+	return sb.String()
+}
+
+var htmlTags = map[string]string{
+	"b":          EntityBold,
+	"strong":     EntityBold,
+	"i":          EntityItalic,
+	"em":         EntityItalic,
+	"u":          EntityUnderline,
+	"ins":        EntityUnderline,
+	"s":          EntityStrikethrough,
+	"strike":     EntityStrikethrough,
+	"del":        EntityStrikethrough,
+	"tg-spoiler": EntitySpoiler,
+	"code":       EntityCode,
+	"pre":        EntityPre,
+	"a":          EntityTextLink,
+	"blockquote": EntityBlockquote,
+}
+
+// ParseHTML parses text in telegram HTML into a message, tags telegram doesn't support are an error
+func ParseHTML(src string) (Message, error) {
+	var (
+		b         = NewBuilder()
+		s         = &entityStack{b: b}
+		tokenizer = html.NewTokenizer(strings.NewReader(src))
+	)
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return Message{}, err
+			}
+
+			if err := s.done(); err != nil {
+				return Message{}, err
+			}
+
+			return b.Message(), nil
+		case html.TextToken:
+			b.Text(string(tokenizer.Text()))
+		case html.StartTagToken:
+			token := tokenizer.Token()
+
+			entityType, ok := htmlTags[token.Data]
+			if token.Data == "span" && attr(token, "class") == "tg-spoiler" {
+				entityType, ok = EntitySpoiler, true
+			}
+			if !ok {
+				return Message{}, fmt.Errorf("unsupported tag <%s>", token.Data)
+			}
+
+			entity := tgbotapi.MessageEntity{Type: entityType}
+
+			switch token.Data {
+			case "a":
+				entity.URL = attr(token, "href")
+			case "code":
+				// code in pre sets the language of the block instead of being an entity itself
+				if top := s.top(); top != nil && top.marker == "pre" {
+					top.entity.Language = strings.TrimPrefix(attr(token, "class"), "language-")
+					entity.Type = ""
+				}
+			}
+
+			s.open(token.Data, entity)
+		case html.EndTagToken:
+			token := tokenizer.Token()
+
+			top := s.top()
+			if top != nil && top.marker == token.Data && top.entity.Type == "" {
+				s.stack = s.stack[:len(s.stack)-1]
+				continue
+			}
+
+			if err := s.close(token.Data); err != nil {
+				return Message{}, err
+			}
+		case html.SelfClosingTagToken:
+			return Message{}, fmt.Errorf("unsupported tag <%s/>", tokenizer.Token().Data)
+		}
+	}
+}
+
+func attr(token html.Token, key string) string {
+	for _, a := range token.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
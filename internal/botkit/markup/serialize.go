@@ -0,0 +1,241 @@
+package markup
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"html"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// MarkdownV2 returns the message as telegram MarkdownV2 text, entities are expected to be nested
+// as the builder and the parsers produce them
+func (m Message) MarkdownV2() string {
+	return m.serialize(markdownV2Tags, func(s string, open []tgbotapi.MessageEntity) string {
+		if hasEntity(open, EntityCode) || hasEntity(open, EntityPre) {
+			return codeReplacer.Replace(s)
+		}
+
+		s = EscapeForMarkdown(strings.ReplaceAll(s, "\\", "\\\\"))
+
+		// every line of a blockquote starts with ">"
+		if hasEntity(open, EntityBlockquote) {
+			s = strings.ReplaceAll(s, "\n", "\n>")
+		}
+
+		return s
+	})
+}
+
+// HTML returns the message as telegram HTML text
+func (m Message) HTML() string {
+	return m.serialize(htmlMarkupTags, func(s string, _ []tgbotapi.MessageEntity) string { return html.EscapeString(s) })
+}
+
+// Mrkdwn returns the message as Slack mrkdwn, underline and spoilers are dropped as Slack has none.
+// Slack has no escaping but for "&", "<" and ">", so markers in the text stay as they are
+func (m Message) Mrkdwn() string {
+	return m.serialize(mrkdwnTags, func(s string, open []tgbotapi.MessageEntity) string {
+		s = mrkdwnReplacer.Replace(s)
+
+		if hasEntity(open, EntityBlockquote) {
+			s = strings.ReplaceAll(s, "\n", "\n>")
+		}
+
+		return s
+	})
+}
+
+// DiscordMarkdown returns the message as markdown as Discord renders it
+func (m Message) DiscordMarkdown() string {
+	return m.serialize(discordTags, func(s string, open []tgbotapi.MessageEntity) string {
+		if hasEntity(open, EntityCode) || hasEntity(open, EntityPre) {
+			// backticks can't be escaped in discord code, a look-alike keeps the code intact
+			return strings.ReplaceAll(s, "`", "ˋ")
+		}
+
+		s = discordReplacer.Replace(s)
+
+		if hasEntity(open, EntityBlockquote) {
+			s = strings.ReplaceAll(s, "\n", "\n> ")
+		}
+
+		return s
+	})
+}
+
+var (
+	codeReplacer    = strings.NewReplacer("\\", "\\\\", "`", "\\`")
+	mrkdwnReplacer  = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	discordReplacer = strings.NewReplacer(
+		"\\", "\\\\", "*", "\\*", "_", "\\_", "~", "\\~", "`", "\\`", "|", "\\|",
+		"[", "\\[", "]", "\\]", "<", "\\<", ">", "\\>", "#", "\\#",
+	)
+)
+
+func markdownV2Tags(entity tgbotapi.MessageEntity) (string, string) {
+	switch entity.Type {
+	case EntityBold:
+		return "*", "*"
+	case EntityItalic:
+		// "\r" is ignored by telegram, it keeps italic apart from an adjacent underline marker
+		return "_\r", "_\r"
+	case EntityUnderline:
+		return "__", "__"
+	case EntityStrikethrough:
+		return "~", "~"
+	case EntitySpoiler:
+		return "||", "||"
+	case EntityCode:
+		return "`", "`"
+	case EntityPre:
+		return "```" + entity.Language + "\n", "\n```"
+	case EntityTextLink:
+		return "[", "](" + EscapeForMarkdownURL(entity.URL) + ")"
+	case EntityBlockquote:
+		return ">", ""
+	default:
+		return "", ""
+	}
+}
+
+func mrkdwnTags(entity tgbotapi.MessageEntity) (string, string) {
+	switch entity.Type {
+	case EntityBold:
+		return "*", "*"
+	case EntityItalic:
+		return "_", "_"
+	case EntityStrikethrough:
+		return "~", "~"
+	case EntityCode:
+		return "`", "`"
+	case EntityPre:
+		return "```\n", "\n```"
+	case EntityTextLink:
+		return "<" + mrkdwnReplacer.Replace(entity.URL) + "|", ">"
+	case EntityBlockquote:
+		return ">", ""
+	default:
+		return "", ""
+	}
+}
+
+func discordTags(entity tgbotapi.MessageEntity) (string, string) {
+	switch entity.Type {
+	case EntityBold:
+		return "**", "**"
+	case EntityItalic:
+		return "*", "*"
+	case EntityUnderline:
+		return "__", "__"
+	case EntityStrikethrough:
+		return "~~", "~~"
+	case EntitySpoiler:
+		return "||", "||"
+	case EntityCode:
+		return "`", "`"
+	case EntityPre:
+		return "```" + entity.Language + "\n", "\n```"
+	case EntityTextLink:
+		return "[", "](<" + entity.URL + ">)"
+	case EntityBlockquote:
+		return "> ", ""
+	default:
+		return "", ""
+	}
+}
+
+func htmlMarkupTags(entity tgbotapi.MessageEntity) (string, string) {
+	switch entity.Type {
+	case EntityBold:
+		return "<b>", "</b>"
+	case EntityItalic:
+		return "<i>", "</i>"
+	case EntityUnderline:
+		return "<u>", "</u>"
+	case EntityStrikethrough:
+		return "<s>", "</s>"
+	case EntitySpoiler:
+		return "<tg-spoiler>", "</tg-spoiler>"
+	case EntityCode:
+		return "<code>", "</code>"
+	case EntityPre:
+		if entity.Language != "" {
+			return `<pre><code class="language-` + html.EscapeString(entity.Language) + `">`, "</code></pre>"
+		}
+		return "<pre>", "</pre>"
+	case EntityTextLink:
+		return `<a href="` + html.EscapeString(entity.URL) + `">`, "</a>"
+	case EntityBlockquote:
+		return "<blockquote>", "</blockquote>"
+	default:
+		return "", ""
+	}
+}
+
+func (m Message) serialize(
+	tags func(tgbotapi.MessageEntity) (string, string),
+	escape func(s string, open []tgbotapi.MessageEntity) string,
+) string {
+	var (
+		sb       strings.Builder
+		units    = utf16.Encode([]rune(m.Text))
+		entities = append([]tgbotapi.MessageEntity(nil), m.Entities...)
+		open     []tgbotapi.MessageEntity
+		pos      int
+		next     int
+	)
+
+	sort.SliceStable(entities, func(i, j int) bool {
+		if entities[i].Offset != entities[j].Offset {
+			return entities[i].Offset < entities[j].Offset
+		}
+		return entities[i].Length > entities[j].Length
+	})
+
+	for {
+		// entities open and close at pos, the innermost ones close first
+		for len(open) > 0 && (end(open[len(open)-1]) <= pos || pos == len(units)) {
+			_, closing := tags(open[len(open)-1])
+			sb.WriteString(closing)
+			open = open[:len(open)-1]
+		}
+
+		if pos == len(units) {
+			return sb.String()
+		}
+
+		for next < len(entities) && entities[next].Offset <= pos {
+			opening, _ := tags(entities[next])
+			sb.WriteString(opening)
+			open = append(open, entities[next])
+			next++
+		}
+
+		// text goes up to the closest position where an entity opens or closes
+		to := len(units)
+		if next < len(entities) {
+			to = min(to, entities[next].Offset)
+		}
+		if len(open) > 0 {
+			to = min(to, end(open[len(open)-1]))
+		}
+
+		sb.WriteString(escape(string(utf16.Decode(units[pos:to])), open))
+		pos = to
+	}
+}
+
+func end(entity tgbotapi.MessageEntity) int {
+	return entity.Offset + entity.Length
+}
+
+func hasEntity(entities []tgbotapi.MessageEntity, entityType string) bool {
+	for _, entity := range entities {
+		if entity.Type == entityType {
+			return true
+		}
+	}
+
+	return false
+}
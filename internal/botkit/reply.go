@@ -0,0 +1,16 @@
+package botkit
+
+import (
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+)
+
+// Reply sends the message to the chat, a message over the telegram limit is split into several
+func Reply(api API, chatID int64, message markup.Message) error {
+	for _, part := range message.Split(markup.MessageLimit) {
+		if _, err := api.Send(part.Config(chatID)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,370 @@
+package botkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/logger"
+	"sync"
+	"time"
+)
+
+// API is the part of the telegram bot api used to send messages,
+// it is implemented by *tgbotapi.BotAPI and by the rate limited Sender
+type API interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+}
+
+type Priority int
+
+const (
+	// PriorityHigh is used for replies to admins
+	PriorityHigh Priority = iota
+	// PriorityNormal is used for channel posts
+	PriorityNormal
+)
+
+// Telegram limits, see https://core.telegram.org/bots/faq#my-bot-is-hitting-limits-how-do-i-avoid-this
+const (
+	globalRate       = 30.0        // messages per second over all chats
+	privateChatRate  = 1.0         // messages per second to a single private chat
+	groupChatRate    = 20.0 / 60.0 // messages per second to a single group or channel
+	maxRetryAfterHit = 3           // attempts to send a message after 429 responses
+)
+
+var ErrSenderStopped = errors.New("sender stopped")
+
+// Sender is the single way out for every outgoing message. It applies a global and a per chat
+// token bucket, honors retry_after of 429 responses and sends queued messages by priority
+type Sender struct {
+	api *tgbotapi.BotAPI
+
+	enqueue chan *outgoing
+
+	mu sync.Mutex
+	// done is closed when the current run stops, every run gets its own
+	done chan struct{}
+}
+
+type outgoing struct {
+	chatID    int64
+	chattable tgbotapi.Chattable
+	request   bool
+	priority  Priority
+	seq       uint64
+	attempts  int
+
+	result chan sendResult
+}
+
+type sendResult struct {
+	message  tgbotapi.Message
+	response *tgbotapi.APIResponse
+	err      error
+}
+
+func NewSender(api *tgbotapi.BotAPI) *Sender {
+	return &Sender{
+		api:     api,
+		enqueue: make(chan *outgoing),
+		// messages submitted before the first run wait for it
+		done: make(chan struct{}),
+	}
+}
+
+// With returns an API that sends through the sender with the priority
+func (s *Sender) With(priority Priority) API {
+	return prioritySender{sender: s, priority: priority}
+}
+
+type prioritySender struct {
+	sender   *Sender
+	priority Priority
+}
+
+func (p prioritySender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	res := p.sender.submit(&outgoing{chattable: c, priority: p.priority})
+	return res.message, res.err
+}
+
+func (p prioritySender) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	res := p.sender.submit(&outgoing{chattable: c, priority: p.priority, request: true})
+	return res.response, res.err
+}
+
+func (s *Sender) submit(out *outgoing) sendResult {
+	chatID, ok := chatIDOf(out.chattable)
+	if !ok {
+		logger.Log.Warnw("sender: unknown chat of the message, only the global limit applies", "type", fmt.Sprintf("%T", out.chattable))
+	}
+
+	out.chatID = chatID
+	out.result = make(chan sendResult, 1)
+
+	done := s.stopped()
+
+	select {
+	case s.enqueue <- out:
+	case <-done:
+		return sendResult{err: ErrSenderStopped}
+	}
+
+	// the message is taken by the current run, which may have started after done was read
+	done = s.stopped()
+
+	select {
+	case res := <-out.result:
+		return res
+	case <-done:
+		return sendResult{err: ErrSenderStopped}
+	}
+}
+
+func (s *Sender) stopped() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.done
+}
+
+// Run sends queued messages until the context is canceled, the sender may be run again after it stops.
+// Messages sent while the sender is stopped fail with ErrSenderStopped
+func (s *Sender) Run(ctx context.Context) error {
+	done := make(chan struct{})
+
+	s.mu.Lock()
+	s.done = done
+	s.mu.Unlock()
+
+	defer close(done)
+
+	var (
+		queue   []*outgoing
+		seq     uint64
+		global  = newTokenBucket(globalRate, globalRate)
+		chats   = make(map[int64]*tokenBucket)
+		blocked = make(map[int64]time.Time) // chats that got retry_after
+		timer   = time.NewTimer(0)
+	)
+	defer timer.Stop()
+
+	for {
+		now := time.Now()
+		next, wait := s.pick(queue, now, global, chats, blocked)
+
+		if next >= 0 && wait == 0 {
+			out := queue[next]
+			queue = append(queue[:next], queue[next+1:]...)
+
+			global.take(now)
+			chatBucket(chats, out.chatID).take(now)
+
+			if retryAfter, ok := s.deliver(out); !ok {
+				blocked[out.chatID] = time.Now().Add(retryAfter)
+				queue = append(queue, out)
+			}
+
+			continue
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+
+		waitC := (<-chan time.Time)(nil)
+		if next >= 0 {
+			timer.Reset(wait)
+			waitC = timer.C
+		}
+
+		select {
+		case out := <-s.enqueue:
+			seq++
+			out.seq = seq
+			queue = append(queue, out)
+		case <-waitC:
+		case <-ctx.Done():
+			for _, out := range queue {
+				out.result <- sendResult{err: ctx.Err()}
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// pick returns the index of the message to send next and how long to wait before it can be sent.
+// Messages go by priority, then by how soon their chat allows them, then by arrival, so an admin reply
+// goes ahead of queued channel posts. A message of a chat under flood control doesn't hold up other
+// messages whatever its priority. -1 is returned if the queue is empty
+func (s *Sender) pick(queue []*outgoing, now time.Time, global *tokenBucket, chats map[int64]*tokenBucket, blocked map[int64]time.Time) (int, time.Duration) {
+	var (
+		best        = -1
+		bestWait    time.Duration
+		bestBlocked bool
+	)
+
+	for i, out := range queue {
+		wait := chatBucket(chats, out.chatID).delay(now)
+		isBlocked := false
+
+		if until, ok := blocked[out.chatID]; ok {
+			if until.After(now) {
+				wait = max(wait, until.Sub(now))
+				isBlocked = true
+			} else {
+				delete(blocked, out.chatID)
+			}
+		}
+
+		if best < 0 || before(out, wait, isBlocked, queue[best], bestWait, bestBlocked) {
+			best, bestWait, bestBlocked = i, wait, isBlocked
+		}
+	}
+
+	if best < 0 {
+		return -1, 0
+	}
+
+	return best, max(bestWait, global.delay(now))
+}
+
+// before reports whether the message a waiting for aWait goes ahead of the message b
+func before(a *outgoing, aWait time.Duration, aBlocked bool, b *outgoing, bWait time.Duration, bBlocked bool) bool {
+	if aBlocked != bBlocked {
+		return !aBlocked
+	}
+
+	// of messages under flood control the one released first goes
+	if aBlocked && aWait != bWait {
+		return aWait < bWait
+	}
+
+	if a.priority != b.priority {
+		return a.priority < b.priority
+	}
+
+	if aWait != bWait {
+		return aWait < bWait
+	}
+
+	return a.seq < b.seq
+}
+
+// deliver sends the message and reports the result to the caller,
+// false is returned with the delay if the message has to be sent again because of flood control
+func (s *Sender) deliver(out *outgoing) (time.Duration, bool) {
+	var res sendResult
+
+	if out.request {
+		res.response, res.err = s.api.Request(out.chattable)
+	} else {
+		res.message, res.err = s.api.Send(out.chattable)
+	}
+
+	var tgErr *tgbotapi.Error
+	if errors.As(res.err, &tgErr) && tgErr.RetryAfter > 0 {
+		out.attempts++
+
+		if out.attempts < maxRetryAfterHit {
+			retryAfter := time.Duration(tgErr.RetryAfter) * time.Second
+			logger.Log.Warnw("sender: flood control hit", "chat", out.chatID, "retry_after", retryAfter)
+
+			return retryAfter, false
+		}
+	}
+
+	out.result <- res
+
+	return 0, true
+}
+
+func chatBucket(chats map[int64]*tokenBucket, chatID int64) *tokenBucket {
+	bucket, ok := chats[chatID]
+	if !ok {
+		// negative ids are groups and channels, they have a lower limit than private chats,
+		// requests without a chat are limited by the global bucket only
+		if chatID == 0 {
+			bucket = newTokenBucket(globalRate, globalRate)
+		} else if chatID < 0 {
+			bucket = newTokenBucket(groupChatRate, 1)
+		} else {
+			bucket = newTokenBucket(privateChatRate, 1)
+		}
+		chats[chatID] = bucket
+	}
+
+	return bucket
+}
+
+// chatIDOf returns the chat the message goes to, requests without a chat, such as callback answers,
+// share the zero id. false is returned for a message of an unknown type, its chat is unknown
+func chatIDOf(c tgbotapi.Chattable) (int64, bool) {
+	switch msg := c.(type) {
+	case tgbotapi.MessageConfig:
+		return msg.ChatID, true
+	case tgbotapi.PhotoConfig:
+		return msg.ChatID, true
+	case tgbotapi.VideoConfig:
+		return msg.ChatID, true
+	case tgbotapi.AnimationConfig:
+		return msg.ChatID, true
+	case tgbotapi.DocumentConfig:
+		return msg.ChatID, true
+	case tgbotapi.AudioConfig:
+		return msg.ChatID, true
+	case tgbotapi.MediaGroupConfig:
+		return msg.ChatID, true
+	case tgbotapi.EditMessageTextConfig:
+		return msg.ChatID, true
+	case tgbotapi.EditMessageCaptionConfig:
+		return msg.ChatID, true
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return msg.ChatID, true
+	case tgbotapi.DeleteMessageConfig:
+		return msg.ChatID, true
+	case tgbotapi.CallbackConfig, tgbotapi.DeleteWebhookConfig:
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// tokenBucket refills rate tokens per second up to burst
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// delay returns how long to wait until a token is available
+func (b *tokenBucket) delay(now time.Time) time.Duration {
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take(now time.Time) {
+	b.refill(now)
+	b.tokens--
+}
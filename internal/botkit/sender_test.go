@@ -0,0 +1,285 @@
+package botkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/logger"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop().Sugar()
+	os.Exit(m.Run())
+}
+
+func TestChatIDOf(t *testing.T) {
+	tests := []struct {
+		chattable tgbotapi.Chattable
+		want      int64
+		wantOK    bool
+	}{
+		{chattable: tgbotapi.NewMessage(-100, "text"), want: -100, wantOK: true},
+		{chattable: tgbotapi.NewPhoto(-101, tgbotapi.FileURL("https://example.com/a.jpg")), want: -101, wantOK: true},
+		{chattable: tgbotapi.NewVideo(-102, tgbotapi.FileURL("https://example.com/a.mp4")), want: -102, wantOK: true},
+		{chattable: tgbotapi.NewAnimation(-103, tgbotapi.FileURL("https://example.com/a.gif")), want: -103, wantOK: true},
+		{chattable: tgbotapi.NewDocument(-104, tgbotapi.FileURL("https://example.com/a.pdf")), want: -104, wantOK: true},
+		{chattable: tgbotapi.NewMediaGroup(-105, nil), want: -105, wantOK: true},
+		{chattable: tgbotapi.NewEditMessageText(42, 1, "text"), want: 42, wantOK: true},
+		{chattable: tgbotapi.NewDeleteMessage(43, 1), want: 43, wantOK: true},
+		{chattable: tgbotapi.NewCallback("id", "text"), want: 0, wantOK: true},
+		{chattable: tgbotapi.NewChatTitle(44, "title"), want: 0, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%T", tt.chattable), func(t *testing.T) {
+			got, ok := chatIDOf(tt.chattable)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("chatIDOf() = %d, %t, want %d, %t", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	var (
+		now    = time.Date(2025, 5, 5, 12, 0, 0, 0, time.UTC)
+		bucket = newTokenBucket(2, 1)
+	)
+
+	if wait := bucket.delay(now); wait != 0 {
+		t.Fatalf("delay() of a full bucket = %s, want 0", wait)
+	}
+
+	bucket.take(now)
+
+	tests := []struct {
+		after time.Duration
+		want  time.Duration
+	}{
+		{after: 0, want: 500 * time.Millisecond},
+		{after: 200 * time.Millisecond, want: 300 * time.Millisecond},
+		{after: 500 * time.Millisecond, want: 0},
+		// tokens don't pile up over the burst
+		{after: time.Hour, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := bucket.delay(now.Add(tt.after)); got != tt.want {
+			t.Errorf("delay() after %s = %s, want %s", tt.after, got, tt.want)
+		}
+	}
+
+	bucket.take(now.Add(time.Hour))
+	bucket.take(now.Add(time.Hour))
+
+	if got := bucket.delay(now.Add(time.Hour)); got != time.Second {
+		t.Errorf("delay() after the burst is spent twice = %s, want 1s", got)
+	}
+}
+
+func TestPick(t *testing.T) {
+	now := time.Date(2025, 5, 5, 12, 0, 0, 0, time.UTC)
+
+	post := func(seq uint64, chatID int64) *outgoing {
+		return &outgoing{chatID: chatID, priority: PriorityNormal, seq: seq}
+	}
+	reply := func(seq uint64, chatID int64) *outgoing {
+		return &outgoing{chatID: chatID, priority: PriorityHigh, seq: seq}
+	}
+
+	tests := []struct {
+		name     string
+		queue    []*outgoing
+		spent    []int64 // chats that have just sent a message
+		blocked  map[int64]time.Duration
+		want     int
+		wantWait time.Duration
+	}{
+		{name: "empty queue", want: -1},
+		{name: "arrival order", queue: []*outgoing{post(1, -100), post(2, -101)}, want: 0},
+		{name: "admin reply ahead of queued posts", queue: []*outgoing{post(1, -100), post(2, -101), reply(3, 42)}, want: 2},
+		{
+			name:     "admin reply waits for its chat rather than lets posts go",
+			queue:    []*outgoing{post(1, -100), reply(2, 42)},
+			spent:    []int64{42},
+			want:     1,
+			wantWait: time.Second,
+		},
+		{
+			name:  "a post of a chat out of tokens doesn't hold up other chats",
+			queue: []*outgoing{post(1, -100), post(2, -101)},
+			spent: []int64{-100},
+			want:  1,
+		},
+		{
+			name:    "admin reply under flood control doesn't hold up posts",
+			queue:   []*outgoing{reply(1, 42), post(2, -100)},
+			blocked: map[int64]time.Duration{42: 30 * time.Second},
+			want:    1,
+		},
+		{
+			name:     "everything under flood control",
+			queue:    []*outgoing{post(1, -100), reply(2, 42)},
+			blocked:  map[int64]time.Duration{42: 30 * time.Second, -100: 10 * time.Second},
+			want:     0,
+			wantWait: 10 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				s       = &Sender{}
+				global  = newTokenBucket(globalRate, globalRate)
+				chats   = make(map[int64]*tokenBucket)
+				blocked = make(map[int64]time.Time)
+			)
+
+			for _, chatID := range tt.spent {
+				chatBucket(chats, chatID).take(now)
+			}
+
+			for chatID, d := range tt.blocked {
+				blocked[chatID] = now.Add(d)
+			}
+
+			got, wait := s.pick(tt.queue, now, global, chats, blocked)
+			if got != tt.want || wait != tt.wantWait {
+				t.Errorf("pick() = %d, %s, want %d, %s", got, wait, tt.want, tt.wantWait)
+			}
+		})
+	}
+}
+
+// telegramStub answers sendMessage, the first message to floodChat gets a 429 with retry_after
+func telegramStub(t *testing.T, floodChat string) (*tgbotapi.BotAPI, func() []string) {
+	t.Helper()
+
+	var (
+		mu      sync.Mutex
+		chats   []string
+		flooded bool
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if strings.HasSuffix(r.URL.Path, "/getMe") {
+			_, _ = io.WriteString(w, `{"ok":true,"result":{"id":1,"is_bot":true,"username":"bot"}}`)
+			return
+		}
+
+		chat := r.FormValue("chat_id")
+
+		mu.Lock()
+		chats = append(chats, chat)
+		flood := chat == floodChat && !flooded
+		flooded = flooded || flood
+		mu.Unlock()
+
+		if flood {
+			_, _ = io.WriteString(w, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{"ok":true,"result":{"message_id":10,"chat":{"id":`+chat+`}}}`)
+	}))
+	t.Cleanup(server.Close)
+
+	api, err := tgbotapi.NewBotAPIWithAPIEndpoint("token", server.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return api, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]string(nil), chats...)
+	}
+}
+
+func TestSenderRetriesAfterFloodControl(t *testing.T) {
+	api, sentTo := telegramStub(t, "7")
+	sender := NewSender(api)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = sender.Run(ctx) }()
+
+	var (
+		wg      sync.WaitGroup
+		flooded error
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, flooded = sender.With(PriorityNormal).Send(tgbotapi.NewMessage(7, "flooded"))
+	}()
+
+	// the other chat is sent while the flooded one waits for retry_after
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sentTo()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := sender.With(PriorityNormal).Send(tgbotapi.NewMessage(42, "other")); err != nil {
+		t.Fatalf("Send() to another chat error = %v", err)
+	}
+
+	wg.Wait()
+
+	if flooded != nil {
+		t.Fatalf("Send() after retry_after error = %v", flooded)
+	}
+
+	if got := strings.Join(sentTo(), ","); got != "7,42,7" {
+		t.Errorf("messages went to %s, want 7,42,7", got)
+	}
+}
+
+func TestSenderRestarts(t *testing.T) {
+	api, sentTo := telegramStub(t, "")
+	sender := NewSender(api)
+
+	for run := range 2 {
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan struct{})
+
+		go func() {
+			_ = sender.Run(ctx)
+			close(stopped)
+		}()
+
+		// messages fail until the run starts
+		var err error
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if _, err = sender.With(PriorityHigh).Send(tgbotapi.NewMessage(42, "hi")); !errors.Is(err, ErrSenderStopped) {
+				break
+			}
+		}
+
+		if err != nil {
+			t.Fatalf("run %d: Send() error = %v", run, err)
+		}
+
+		cancel()
+		<-stopped
+	}
+
+	if len(sentTo()) != 2 {
+		t.Errorf("sent %d messages over two runs, want 2", len(sentTo()))
+	}
+}
@@ -0,0 +1,171 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	readability "github.com/go-shiori/go-readability"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+	"github.com/lostmyescape/news-tg-bot/logger"
+	"github.com/samber/lo"
+	"golang.org/x/net/html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// teaserLength is the length of the feed summary below which it is taken as a teaser of the full article
+	teaserLength = 500
+	// minContentLength is the length of the page text below which nothing useful was extracted
+	minContentLength = 200
+)
+
+// Extractor finds the content of an article: in the feed summary if the feed carries the full text,
+// otherwise on the article page
+type Extractor struct {
+	fetcher *Fetcher
+	rules   RuleProvider
+}
+
+func NewExtractor(fetcher *Fetcher, rules RuleProvider) *Extractor {
+	return &Extractor{fetcher: fetcher, rules: rules}
+}
+
+// Extract returns the content of the article. The page is downloaded only when the feed
+// has a teaser, if the page is behind a paywall or has no text the teaser is used instead
+func (e *Extractor) Extract(ctx context.Context, article model.Article) (model.ArticleContent, error) {
+	pageURL, err := url.Parse(article.Link)
+	if err != nil {
+		return model.ArticleContent{}, err
+	}
+
+	if content, ok := fromFeed(article.Summary, pageURL); ok {
+		return content, nil
+	}
+
+	content, err := e.fromPage(ctx, pageURL)
+	if err == nil {
+		return content, nil
+	}
+
+	teaser := render.PlainText(article.Summary)
+	if teaser == "" || !IsPermanent(err) {
+		return model.ArticleContent{}, err
+	}
+
+	logger.Log.Infow("content: using feed teaser", "link", article.Link, "err", err)
+
+	return model.ArticleContent{Text: teaser, ImageURL: firstImage(article.Summary, pageURL)}, nil
+}
+
+// fromFeed returns the article content from the feed summary, false if the summary is missing or only a teaser
+func fromFeed(summary string, pageURL *url.URL) (model.ArticleContent, bool) {
+	if utf8.RuneCountInString(render.PlainText(summary)) < teaserLength {
+		return model.ArticleContent{}, false
+	}
+
+	content := model.ArticleContent{ImageURL: firstImage(summary, pageURL)}
+
+	doc, err := readability.FromReader(strings.NewReader(summary), pageURL)
+	if err != nil || strings.TrimSpace(doc.TextContent) == "" {
+		content.Text = render.PlainText(summary)
+	} else {
+		content.Text = cleanText(doc.TextContent)
+	}
+
+	return content, true
+}
+
+// Page downloads the page and extracts its content, regardless of the feed
+func (e *Extractor) Page(ctx context.Context, link string) (model.ArticleContent, error) {
+	pageURL, err := url.Parse(link)
+	if err != nil {
+		return model.ArticleContent{}, err
+	}
+
+	return e.fromPage(ctx, pageURL)
+}
+
+// fromPage extracts the content of the page. The extraction rule of the site, if there is one, cleans
+// the page and may give the text, otherwise the text is found by readability
+func (e *Extractor) fromPage(ctx context.Context, pageURL *url.URL) (model.ArticleContent, error) {
+	page, err := e.fetcher.Fetch(ctx, pageURL.String())
+	if err != nil {
+		return model.ArticleContent{}, err
+	}
+
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return model.ArticleContent{}, fmt.Errorf("%s: %w: %v", pageURL, ErrNoContent, err)
+	}
+
+	rule, err := e.rules.RuleForHost(ctx, pageURL.Hostname())
+	if err != nil {
+		return model.ArticleContent{}, fmt.Errorf("failed to get extraction rule: %w", err)
+	}
+
+	var content model.ArticleContent
+
+	if rule != nil {
+		if content, err = applyRule(doc, *rule, pageURL); err != nil {
+			return model.ArticleContent{}, fmt.Errorf("extraction rule of %s: %w", rule.Domain, err)
+		}
+	}
+
+	// readability still gives the metadata if the rule gives the text
+	article, err := readability.FromDocument(doc, pageURL)
+	if err != nil && content.Text == "" {
+		return model.ArticleContent{}, fmt.Errorf("%s: %w: %v", pageURL, ErrNoContent, err)
+	}
+
+	if content.Text == "" {
+		content.Text = cleanText(article.TextContent)
+	}
+
+	if utf8.RuneCountInString(content.Text) < minContentLength {
+		return model.ArticleContent{}, fmt.Errorf("%s: %w", pageURL, ErrNoContent)
+	}
+
+	// the image of the page preview is the best choice, the first image of the article otherwise
+	content.ImageURL = lo.CoalesceOrEmpty(article.Image, content.ImageURL, firstImage(article.Content, pageURL))
+	content.Author = lo.CoalesceOrEmpty(content.Author, strings.TrimSpace(article.Byline))
+	content.Language = article.Language
+
+	return content, nil
+}
+
+var redundantNewLines = regexp.MustCompile(`\n{3,}`)
+
+func cleanText(text string) string {
+	return strings.TrimSpace(redundantNewLines.ReplaceAllString(text, "\n"))
+}
+
+// firstImage returns the absolute address of the first image in the html, empty if there is none
+func firstImage(src string, pageURL *url.URL) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(src))
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.Data != "img" {
+				continue
+			}
+
+			for _, attr := range token.Attr {
+				if attr.Key != "src" || strings.HasPrefix(attr.Val, "data:") {
+					continue
+				}
+
+				if image, err := pageURL.Parse(attr.Val); err == nil {
+					return image.String()
+				}
+			}
+		}
+	}
+}
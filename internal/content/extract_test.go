@@ -0,0 +1,158 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type ruleStub map[string]*model.ExtractionRule
+
+func (s ruleStub) RuleForHost(_ context.Context, host string) (*model.ExtractionRule, error) {
+	return s[host], nil
+}
+
+func TestExtractorExtract(t *testing.T) {
+	var (
+		articleText = strings.Repeat("The full text of the article. ", 20)
+		articlePage = `<html><head><title>News</title></head><body><article><h1>News</h1>` +
+			`<p>` + articleText + `</p><p>` + articleText + `</p></article></body></html>`
+		teaser   = `<p>A short teaser <b>of the news</b>.</p><img src="/teaser.jpg">`
+		fullFeed = `<p>` + strings.Repeat("The full text is in the feed. ", 20) + `</p>`
+	)
+
+	tests := []struct {
+		name      string
+		summary   string
+		handler   func(w http.ResponseWriter, attempt int)
+		wantText  string
+		wantImage string
+		wantErr   error
+		attempts  int32
+	}{
+		{
+			name:     "full text in the feed",
+			summary:  fullFeed,
+			handler:  func(w http.ResponseWriter, _ int) { writePage(w, "text/html", articlePage) },
+			wantText: strings.TrimSpace(strings.Repeat("The full text is in the feed. ", 20)),
+			attempts: 0,
+		},
+		{
+			name:     "teaser in the feed",
+			summary:  teaser,
+			handler:  func(w http.ResponseWriter, _ int) { writePage(w, "text/html", articlePage) },
+			wantText: "The full text of the article.",
+			attempts: 1,
+		},
+		{
+			name:    "teaser behind a paywall",
+			summary: teaser,
+			handler: func(w http.ResponseWriter, _ int) {
+				writePage(w, "text/html", `<script>{"isAccessibleForFree": false}</script>`+articlePage)
+			},
+			wantText:  "A short teaser of the news .",
+			wantImage: "/teaser.jpg",
+			attempts:  1,
+		},
+		{
+			name:    "teaser of a page without text",
+			summary: teaser,
+			handler: func(w http.ResponseWriter, _ int) {
+				writePage(w, "text/html", "<html><body><p>Subscribe</p></body></html>")
+			},
+			wantText:  "A short teaser of the news .",
+			wantImage: "/teaser.jpg",
+			attempts:  1,
+		},
+		{
+			name:    "teaser of a pdf",
+			summary: teaser,
+			handler: func(w http.ResponseWriter, _ int) {
+				writePage(w, "application/pdf", "%PDF-1.4")
+			},
+			wantText:  "A short teaser of the news .",
+			wantImage: "/teaser.jpg",
+			attempts:  1,
+		},
+		{
+			name:    "transient error is not covered with the teaser",
+			summary: teaser,
+			handler: func(w http.ResponseWriter, _ int) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantErr:  &statusError{},
+			attempts: fetchAttempts,
+		},
+		{
+			name: "no teaser behind a paywall",
+			handler: func(w http.ResponseWriter, _ int) {
+				w.WriteHeader(http.StatusPaymentRequired)
+			},
+			wantErr:  ErrPaywall,
+			attempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher, url, attempts := newTestFetcher(t, tt.handler)
+			extractor := NewExtractor(fetcher, ruleStub{})
+
+			content, err := extractor.Extract(context.Background(), model.Article{Link: url, Summary: tt.summary})
+
+			switch target := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("Extract() error = %v", err)
+				}
+			case *statusError:
+				if !errors.As(err, &target) {
+					t.Fatalf("Extract() error = %v, want a status error", err)
+				}
+			default:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Extract() error = %v, want %v", err, tt.wantErr)
+				}
+			}
+
+			if !strings.Contains(content.Text, tt.wantText) {
+				t.Errorf("Extract() text = %.100q, want it to contain %q", content.Text, tt.wantText)
+			}
+
+			if !strings.HasSuffix(content.ImageURL, tt.wantImage) {
+				t.Errorf("Extract() image = %q, want %q", content.ImageURL, tt.wantImage)
+			}
+
+			if got := attempts.Load(); got != tt.attempts {
+				t.Errorf("Extract() made %d attempts, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestExtractorPageRule(t *testing.T) {
+	page := `<html><body><div class="promo">` + strings.Repeat("Subscribe now! ", 50) + `</div>` +
+		`<div class="story"><span class="author">Jane Doe</span><p>` +
+		strings.Repeat("The story text. ", 20) + `</p></div></body></html>`
+
+	fetcher, url, _ := newTestFetcher(t, func(w http.ResponseWriter, _ int) { writePage(w, "text/html", page) })
+	extractor := NewExtractor(fetcher, ruleStub{
+		"127.0.0.1": {Domain: "127.0.0.1", Content: ".story p", Author: ".author", Remove: ".promo"},
+	})
+
+	content, err := extractor.Page(context.Background(), url)
+	if err != nil {
+		t.Fatalf("Page() error = %v", err)
+	}
+
+	if want := strings.TrimSpace(strings.Repeat("The story text. ", 20)); content.Text != want {
+		t.Errorf("Page() text = %q, want %q", content.Text, want)
+	}
+
+	if content.Author != "Jane Doe" {
+		t.Errorf("Page() author = %q, want %q", content.Author, "Jane Doe")
+	}
+}
@@ -0,0 +1,185 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/net/html/charset"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+const (
+	// fetchTimeout limits a single attempt to download a page
+	fetchTimeout = 20 * time.Second
+	// maxBodySize is the maximum size of a page, larger pages are not fetched
+	maxBodySize = 5 << 20
+	// fetchAttempts is the number of attempts to download a page on transient errors
+	fetchAttempts = 3
+	// fetchBackoffBase is the delay before the first retry, it doubles with every attempt
+	fetchBackoffBase = time.Second
+)
+
+var (
+	// ErrNotHTML is returned for links to something other than a web page, e.g. a pdf or a video
+	ErrNotHTML = errors.New("not an html page")
+	// ErrPaywall is returned for pages whose content is only available to subscribers
+	ErrPaywall = errors.New("page is behind a paywall")
+	// ErrNoContent is returned if no article text could be extracted from the page
+	ErrNoContent = errors.New("no content extracted")
+	// ErrTooLarge is returned for pages larger than maxBodySize
+	ErrTooLarge = errors.New("page is too large")
+)
+
+// IsPermanent reports whether the error won't go away if the page is fetched again
+func IsPermanent(err error) bool {
+	var statusErr *statusError
+
+	return errors.Is(err, ErrNotHTML) || errors.Is(err, ErrPaywall) || errors.Is(err, ErrNoContent) ||
+		errors.Is(err, ErrTooLarge) || errors.As(err, &statusErr) && !statusErr.transient()
+}
+
+type statusError struct {
+	url    string
+	status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("failed to fetch %s: status %d", e.url, e.status)
+}
+
+// transient reports whether the request may succeed later: rate limits and server errors
+func (e *statusError) transient() bool {
+	return e.status == http.StatusTooManyRequests || e.status == http.StatusRequestTimeout || e.status >= 500
+}
+
+// paywalled marks pages whose structured data says the content isn't free, as publishers do for search engines
+var paywalled = regexp.MustCompile(`(?i)"isAccessibleForFree"\s*:\s*"?false`)
+
+// Fetcher downloads web pages of articles
+type Fetcher struct {
+	client  *http.Client
+	backoff time.Duration
+}
+
+func NewFetcher() *Fetcher {
+	return &Fetcher{client: &http.Client{Timeout: fetchTimeout}, backoff: fetchBackoffBase}
+}
+
+// Fetch downloads the html page, transient errors are retried with backoff. The page is decoded
+// from its charset into utf-8
+func (f *Fetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
+	var err error
+
+	for attempt := 1; attempt <= fetchAttempts; attempt++ {
+		var page []byte
+		if page, err = f.fetch(ctx, url); err == nil || !transient(err) || attempt == fetchAttempts {
+			return page, err
+		}
+
+		select {
+		case <-time.After(f.backoff << (attempt - 1)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return nil, err
+}
+
+func (f *Fetcher) fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPaymentRequired:
+		return nil, fmt.Errorf("%s: %w", url, ErrPaywall)
+	case resp.StatusCode != http.StatusOK:
+		return nil, &statusError{url: url, status: resp.StatusCode}
+	}
+
+	// a pdf or a video is not downloaded at all if the server tells what it is
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !isHTML(contentType) {
+		return nil, fmt.Errorf("%s is %q: %w", url, contentType, ErrNotHTML)
+	}
+
+	if resp.ContentLength > maxBodySize {
+		return nil, fmt.Errorf("%s is %d bytes: %w", url, resp.ContentLength, ErrTooLarge)
+	}
+
+	// a byte over the limit tells a page that is too large from one that is exactly at the limit
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) > maxBodySize {
+		return nil, fmt.Errorf("%s is over %d bytes: %w", url, maxBodySize, ErrTooLarge)
+	}
+
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+
+		if !isHTML(contentType) {
+			return nil, fmt.Errorf("%s is %q: %w", url, contentType, ErrNotHTML)
+		}
+	}
+
+	// the charset comes from the header, a byte order mark or a meta tag
+	r, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if paywalled.Match(page) {
+		return nil, fmt.Errorf("%s: %w", url, ErrPaywall)
+	}
+
+	return page, nil
+}
+
+func isHTML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// transient reports whether the fetch is worth retrying: network errors, timeouts and server errors
+func transient(err error) bool {
+	var (
+		statusErr *statusError
+		netErr    net.Error
+	)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return false
+	case errors.As(err, &statusErr):
+		return statusErr.transient()
+	case errors.As(err, &netErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	default:
+		return false
+	}
+}
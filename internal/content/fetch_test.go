@@ -0,0 +1,306 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"github.com/lostmyescape/news-tg-bot/logger"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop().Sugar()
+	os.Exit(m.Run())
+}
+
+const testPage = `<html><head><title>News</title></head><body><p>Hello</p></body></html>`
+
+// newTestFetcher serves pages with the handler, which gets the number of the attempt starting from 1
+func newTestFetcher(t *testing.T, handler func(w http.ResponseWriter, attempt int)) (*Fetcher, string, *atomic.Int32) {
+	t.Helper()
+
+	var attempts atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, int(attempts.Add(1)))
+	}))
+	t.Cleanup(srv.Close)
+
+	return &Fetcher{client: srv.Client(), backoff: time.Millisecond}, srv.URL + "/news/1", &attempts
+}
+
+func writePage(w http.ResponseWriter, contentType string, page string) {
+	if contentType == "" {
+		// stops the server from sniffing the content type itself
+		w.Header()["Content-Type"] = nil
+	} else {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	_, _ = w.Write([]byte(page))
+}
+
+func TestFetcherFetch(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   func(w http.ResponseWriter, attempt int)
+		want      string
+		wantErr   error
+		permanent bool
+		attempts  int32
+	}{
+		{
+			name: "html page",
+			handler: func(w http.ResponseWriter, _ int) {
+				writePage(w, "text/html; charset=utf-8", testPage)
+			},
+			want:     testPage,
+			attempts: 1,
+		},
+		{
+			name: "xhtml page",
+			handler: func(w http.ResponseWriter, _ int) {
+				writePage(w, "application/xhtml+xml", testPage)
+			},
+			want:     testPage,
+			attempts: 1,
+		},
+		{
+			name: "page decoded from its charset",
+			handler: func(w http.ResponseWriter, _ int) {
+				// "Привет" in windows-1251
+				writePage(w, "text/html; charset=windows-1251", "<p>\xcf\xf0\xe8\xe2\xe5\xf2</p>")
+			},
+			want:     "<p>Привет</p>",
+			attempts: 1,
+		},
+		{
+			name: "content type sniffed",
+			handler: func(w http.ResponseWriter, _ int) {
+				writePage(w, "", testPage)
+			},
+			want:     testPage,
+			attempts: 1,
+		},
+		{
+			name: "pdf by content type",
+			handler: func(w http.ResponseWriter, _ int) {
+				writePage(w, "application/pdf", "%PDF-1.4")
+			},
+			wantErr:   ErrNotHTML,
+			permanent: true,
+			attempts:  1,
+		},
+		{
+			name: "pdf sniffed",
+			handler: func(w http.ResponseWriter, _ int) {
+				writePage(w, "", "%PDF-1.4\n%âãÏÓ")
+			},
+			wantErr:   ErrNotHTML,
+			permanent: true,
+			attempts:  1,
+		},
+		{
+			name: "content length over the limit",
+			handler: func(w http.ResponseWriter, _ int) {
+				w.Header().Set("Content-Type", "text/html")
+				w.Header().Set("Content-Length", strconv.Itoa(maxBodySize+1))
+				_, _ = w.Write([]byte(testPage))
+			},
+			wantErr:   ErrTooLarge,
+			permanent: true,
+			attempts:  1,
+		},
+		{
+			name: "body over the limit",
+			handler: func(w http.ResponseWriter, _ int) {
+				w.Header().Set("Content-Type", "text/html")
+				// flushing first sends the body chunked, without a length
+				w.(http.Flusher).Flush()
+				_, _ = w.Write([]byte(strings.Repeat("a", maxBodySize+1)))
+			},
+			wantErr:   ErrTooLarge,
+			permanent: true,
+			attempts:  1,
+		},
+		{
+			name: "body at the limit",
+			handler: func(w http.ResponseWriter, _ int) {
+				w.Header().Set("Content-Type", "text/html")
+				w.(http.Flusher).Flush()
+				_, _ = w.Write([]byte(strings.Repeat("a", maxBodySize)))
+			},
+			want:     strings.Repeat("a", maxBodySize),
+			attempts: 1,
+		},
+		{
+			name: "payment required",
+			handler: func(w http.ResponseWriter, _ int) {
+				w.WriteHeader(http.StatusPaymentRequired)
+			},
+			wantErr:   ErrPaywall,
+			permanent: true,
+			attempts:  1,
+		},
+		{
+			name: "paywall in structured data",
+			handler: func(w http.ResponseWriter, _ int) {
+				writePage(w, "text/html", `<script type="application/ld+json">{"isAccessibleForFree": "False"}</script>`)
+			},
+			wantErr:   ErrPaywall,
+			permanent: true,
+			attempts:  1,
+		},
+		{
+			name: "free page with structured data",
+			handler: func(w http.ResponseWriter, _ int) {
+				writePage(w, "text/html", `<script type="application/ld+json">{"isAccessibleForFree": true}</script>`)
+			},
+			want:     `<script type="application/ld+json">{"isAccessibleForFree": true}</script>`,
+			attempts: 1,
+		},
+		{
+			name: "not found is not retried",
+			handler: func(w http.ResponseWriter, _ int) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			wantErr:   &statusError{},
+			permanent: true,
+			attempts:  1,
+		},
+		{
+			name: "server error is retried",
+			handler: func(w http.ResponseWriter, attempt int) {
+				if attempt < fetchAttempts {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+
+				writePage(w, "text/html", testPage)
+			},
+			want:     testPage,
+			attempts: fetchAttempts,
+		},
+		{
+			name: "rate limit is retried",
+			handler: func(w http.ResponseWriter, attempt int) {
+				if attempt == 1 {
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+
+				writePage(w, "text/html", testPage)
+			},
+			want:     testPage,
+			attempts: 2,
+		},
+		{
+			name: "server error after every attempt",
+			handler: func(w http.ResponseWriter, _ int) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			wantErr:   &statusError{},
+			permanent: false,
+			attempts:  fetchAttempts,
+		},
+		{
+			name: "dropped connection is retried",
+			handler: func(w http.ResponseWriter, attempt int) {
+				if attempt == 1 {
+					conn, _, _ := w.(http.Hijacker).Hijack()
+					_ = conn.Close()
+					return
+				}
+
+				writePage(w, "text/html", testPage)
+			},
+			want:     testPage,
+			attempts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher, url, attempts := newTestFetcher(t, tt.handler)
+
+			page, err := fetcher.Fetch(context.Background(), url)
+
+			switch target := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("Fetch() error = %v", err)
+				}
+			case *statusError:
+				if !errors.As(err, &target) {
+					t.Fatalf("Fetch() error = %v, want a status error", err)
+				}
+			default:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Fetch() error = %v, want %v", err, tt.wantErr)
+				}
+			}
+
+			if err != nil && IsPermanent(err) != tt.permanent {
+				t.Errorf("IsPermanent(%v) = %t, want %t", err, IsPermanent(err), tt.permanent)
+			}
+
+			if string(page) != tt.want {
+				t.Errorf("Fetch() = %.100q, want %.100q", page, tt.want)
+			}
+
+			if got := attempts.Load(); got != tt.attempts {
+				t.Errorf("Fetch() made %d attempts, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestFetcherFetchBackoff(t *testing.T) {
+	var times []time.Time
+
+	fetcher, url, _ := newTestFetcher(t, func(w http.ResponseWriter, _ int) {
+		times = append(times, time.Now())
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	fetcher.backoff = 20 * time.Millisecond
+
+	if _, err := fetcher.Fetch(context.Background(), url); err == nil {
+		t.Fatal("Fetch() error = nil")
+	}
+
+	if len(times) != fetchAttempts {
+		t.Fatalf("Fetch() made %d attempts, want %d", len(times), fetchAttempts)
+	}
+
+	// the delay doubles with every attempt
+	for i := 1; i < len(times); i++ {
+		if want := fetcher.backoff << (i - 1); times[i].Sub(times[i-1]) < want {
+			t.Errorf("attempt %d came %s after the previous one, want at least %s", i+1, times[i].Sub(times[i-1]), want)
+		}
+	}
+}
+
+func TestFetcherFetchCanceledDuringBackoff(t *testing.T) {
+	fetcher, url, attempts := newTestFetcher(t, func(w http.ResponseWriter, _ int) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	fetcher.backoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := fetcher.Fetch(ctx, url); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Fetch() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("Fetch() made %d attempts, want 1", got)
+	}
+}
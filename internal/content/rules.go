@@ -0,0 +1,190 @@
+package content
+
+import (
+	"context"
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RuleProvider finds the extraction rule of a site
+type RuleProvider interface {
+	RuleForHost(ctx context.Context, host string) (*model.ExtractionRule, error)
+}
+
+// ValidateRule checks that the selectors of the rule are valid CSS selectors
+func ValidateRule(rule model.ExtractionRule) error {
+	for _, selector := range []string{rule.Content, rule.Remove, rule.Author, rule.Date} {
+		if selector == "" {
+			continue
+		}
+
+		if _, err := cascadia.ParseGroup(selector); err != nil {
+			return fmt.Errorf("invalid selector %q: %w", selector, err)
+		}
+	}
+
+	return nil
+}
+
+// applyRule drops elements matching the remove selector from the document, then finds the author, the date
+// and, if the rule has a content selector, the text and image of the article. Empty text means the text
+// is left to readability
+func applyRule(doc *html.Node, rule model.ExtractionRule, pageURL *url.URL) (model.ArticleContent, error) {
+	if err := ValidateRule(rule); err != nil {
+		return model.ArticleContent{}, err
+	}
+
+	var content model.ArticleContent
+
+	if rule.Remove != "" {
+		for _, node := range cascadia.MustCompile(rule.Remove).MatchAll(doc) {
+			// a node inside an already removed one is removed from a detached tree, which does no harm
+			if node.Parent != nil {
+				node.Parent.RemoveChild(node)
+			}
+		}
+	}
+
+	if rule.Author != "" {
+		if node := cascadia.MustCompile(rule.Author).MatchFirst(doc); node != nil {
+			content.Author = valueOf(node, "content")
+		}
+	}
+
+	if rule.Date != "" {
+		if node := cascadia.MustCompile(rule.Date).MatchFirst(doc); node != nil {
+			content.PublishedAt = parseDate(valueOf(node, "datetime", "content"))
+		}
+	}
+
+	if rule.Content == "" {
+		return content, nil
+	}
+
+	var sb strings.Builder
+
+	for _, node := range cascadia.MustCompile(rule.Content).MatchAll(doc) {
+		writeText(&sb, node)
+		sb.WriteString("\n")
+
+		if content.ImageURL == "" {
+			if img := imageSelector.MatchFirst(node); img != nil {
+				if image, err := pageURL.Parse(attr(img, "src")); err == nil {
+					content.ImageURL = image.String()
+				}
+			}
+		}
+	}
+
+	content.Text = normalizeText(sb.String())
+
+	return content, nil
+}
+
+var imageSelector = cascadia.MustCompile("img[src]:not([src^='data:'])")
+
+// valueOf returns the first non-empty attribute of the node, e.g. datetime of a time element
+// or content of a meta tag, the text of the node otherwise
+func valueOf(node *html.Node, attrs ...string) string {
+	for _, key := range attrs {
+		if value := strings.TrimSpace(attr(node, key)); value != "" {
+			return value
+		}
+	}
+
+	var sb strings.Builder
+	writeText(&sb, node)
+
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+func attr(node *html.Node, key string) string {
+	for _, a := range node.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+var (
+	// blockElements are on lines of their own
+	blockElements = map[atom.Atom]bool{
+		atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true, atom.Tr: true, atom.Pre: true,
+		atom.Blockquote: true, atom.Section: true, atom.Article: true, atom.Figcaption: true,
+		atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	}
+	// hiddenElements have no readable text
+	hiddenElements = map[atom.Atom]bool{
+		atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true, atom.Iframe: true,
+	}
+)
+
+func writeText(sb *strings.Builder, node *html.Node) {
+	switch {
+	case node.Type == html.TextNode:
+		sb.WriteString(node.Data)
+		return
+	case node.Type == html.ElementNode && hiddenElements[node.DataAtom]:
+		return
+	}
+
+	block := node.Type == html.ElementNode && blockElements[node.DataAtom]
+	if block {
+		sb.WriteString("\n")
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		writeText(sb, child)
+	}
+
+	if block {
+		sb.WriteString("\n")
+	}
+}
+
+// normalizeText collapses whitespace inside lines and drops empty lines
+func normalizeText(text string) string {
+	var lines []string
+
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// dateLayouts are the date formats found on pages, machine readable ones first
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"02.01.2006 15:04",
+	"02.01.2006",
+	"January 2, 2006",
+	"2 January 2006",
+}
+
+// parseDate parses the date in one of the known layouts, zero if it's in none of them
+func parseDate(value string) time.Time {
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date.UTC()
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,180 @@
+package content
+
+import (
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"golang.org/x/net/html"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const rulePage = `<html><head>
+<meta name="author" content="  Jane Doe ">
+<meta property="article:published_time" content="2025-05-05T09:30:00+03:00">
+</head><body>
+<header><nav>Menu</nav></header>
+<article>
+	<h1>Title</h1>
+	<span class="byline">by   John
+		Smith</span>
+	<time datetime="2025-05-04">4 May</time>
+	<span class="date">05.05.2025 10:15</span>
+	<span class="bad-date">yesterday</span>
+	<div class="body">
+		<p>First    paragraph.</p>
+		<div class="ad">Buy now</div>
+		<img src="data:image/png;base64,AAAA">
+		<img src="/images/photo.jpg">
+		<p>Second<br>line</p>
+		<script>var x = 1;</script>
+	</div>
+</article>
+</body></html>`
+
+func TestApplyRule(t *testing.T) {
+	tests := []struct {
+		name string
+		rule model.ExtractionRule
+		want model.ArticleContent
+	}{
+		{
+			name: "content with removed elements",
+			rule: model.ExtractionRule{Content: "div.body", Remove: ".ad, script"},
+			want: model.ArticleContent{
+				Text:     "First paragraph.\nSecond\nline",
+				ImageURL: "https://example.com/images/photo.jpg",
+			},
+		},
+		{
+			name: "content without remove",
+			rule: model.ExtractionRule{Content: "div.body"},
+			want: model.ArticleContent{
+				Text:     "First paragraph.\nBuy now\nSecond\nline",
+				ImageURL: "https://example.com/images/photo.jpg",
+			},
+		},
+		{
+			name: "author from meta content",
+			rule: model.ExtractionRule{Author: `meta[name="author"]`},
+			want: model.ArticleContent{Author: "Jane Doe"},
+		},
+		{
+			name: "author from element text",
+			rule: model.ExtractionRule{Author: ".byline"},
+			want: model.ArticleContent{Author: "by John Smith"},
+		},
+		{
+			name: "date from datetime",
+			rule: model.ExtractionRule{Date: "time"},
+			want: model.ArticleContent{PublishedAt: time.Date(2025, 5, 4, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "date from meta content",
+			rule: model.ExtractionRule{Date: `meta[property="article:published_time"]`},
+			want: model.ArticleContent{PublishedAt: time.Date(2025, 5, 5, 6, 30, 0, 0, time.UTC)},
+		},
+		{
+			name: "date from element text",
+			rule: model.ExtractionRule{Date: ".date"},
+			want: model.ArticleContent{PublishedAt: time.Date(2025, 5, 5, 10, 15, 0, 0, time.UTC)},
+		},
+		{
+			name: "unparseable date",
+			rule: model.ExtractionRule{Date: ".bad-date"},
+			want: model.ArticleContent{},
+		},
+		{
+			name: "removed author",
+			rule: model.ExtractionRule{Author: ".byline", Remove: ".byline"},
+			want: model.ArticleContent{},
+		},
+		{
+			name: "selectors match nothing",
+			rule: model.ExtractionRule{Content: ".missing", Author: ".missing", Date: ".missing", Remove: ".missing"},
+			want: model.ArticleContent{},
+		},
+	}
+
+	pageURL, _ := url.Parse("https://example.com/news/1")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader(rulePage))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := applyRule(doc, tt.rule, pageURL)
+			if err != nil {
+				t.Fatalf("applyRule() error = %v", err)
+			}
+
+			if got.Text != tt.want.Text || got.Author != tt.want.Author || got.ImageURL != tt.want.ImageURL ||
+				!got.PublishedAt.Equal(tt.want.PublishedAt) {
+				t.Errorf("applyRule() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyRuleInvalidSelector(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(rulePage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := applyRule(doc, model.ExtractionRule{Content: "div[", Author: ".byline"}, &url.URL{}); err == nil {
+		t.Error("applyRule() error = nil, want invalid selector")
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{value: "2025-05-05T09:30:00+03:00", want: time.Date(2025, 5, 5, 6, 30, 0, 0, time.UTC)},
+		{value: "2025-05-05T09:30:00", want: time.Date(2025, 5, 5, 9, 30, 0, 0, time.UTC)},
+		{value: "2025-05-05 09:30", want: time.Date(2025, 5, 5, 9, 30, 0, 0, time.UTC)},
+		{value: "2025-05-05", want: time.Date(2025, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{value: "Mon, 05 May 2025 09:30:00 +0300", want: time.Date(2025, 5, 5, 6, 30, 0, 0, time.UTC)},
+		{value: "05.05.2025", want: time.Date(2025, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{value: "May 5, 2025", want: time.Date(2025, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{value: "5 May 2025", want: time.Date(2025, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{value: "yesterday"},
+		{value: "5 мая 2025"},
+		{value: "2025-13-45"},
+		{value: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parseDate(tt.value); !got.Equal(tt.want) {
+				t.Errorf("parseDate(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "empty", text: "", want: ""},
+		{name: "whitespace only", text: " \n\t\n  ", want: ""},
+		{name: "collapses spaces", text: "  one   two\tthree ", want: "one two three"},
+		{name: "drops empty lines", text: "\n\none\n\n  \ntwo\n", want: "one\ntwo"},
+		{name: "non-breaking space", text: "one\u00a0 two", want: "one two"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeText(tt.text); got != tt.want {
+				t.Errorf("normalizeText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/schedule"
+	"github.com/lostmyescape/news-tg-bot/logger"
+	"time"
+)
+
+type ArticleProvider interface {
+	PostedSince(ctx context.Context, since time.Time) ([]model.Article, error)
+}
+
+type SubscriberStorage interface {
+	Subscribers(ctx context.Context) ([]model.Subscriber, error)
+	MarkSent(ctx context.Context, ids []int64, sentAt time.Time) error
+}
+
+type MailSender interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+// sendTimeout limits sending a single mail
+const sendTimeout = time.Minute
+
+// frequencies are the digest frequencies in the order their digests go out when due at the same time
+var frequencies = []string{model.DigestDaily, model.DigestWeekly}
+
+// periods are how far back the first digest of a subscriber goes
+var periods = map[string]time.Duration{
+	model.DigestDaily:  24 * time.Hour,
+	model.DigestWeekly: 7 * 24 * time.Hour,
+}
+
+// Digester mails digests of posted articles to subscribers, daily subscribers get them at the daily times
+// and weekly ones at the weekly times
+type Digester struct {
+	articles    ArticleProvider
+	subscribers SubscriberStorage
+	sender      MailSender
+	times       map[string]*schedule.Times
+}
+
+func NewDigester(
+	articleProvider ArticleProvider,
+	subscriberStorage SubscriberStorage,
+	sender MailSender,
+	daily *schedule.Times,
+	weekly *schedule.Times,
+) *Digester {
+	return &Digester{
+		articles:    articleProvider,
+		subscribers: subscriberStorage,
+		sender:      sender,
+		times: map[string]*schedule.Times{
+			model.DigestDaily:  daily,
+			model.DigestWeekly: weekly,
+		},
+	}
+}
+
+// Start sends digests at their times until the context is done
+func (d *Digester) Start(ctx context.Context) error {
+	for {
+		due, next := d.next(time.Now())
+		logger.Log.Infof("email: next %v digests at %s", due, next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+
+		for _, frequency := range due {
+			if err := d.SendDigests(ctx, frequency); err != nil {
+				logger.Log.Errorw("email: failed to send digests", "frequency", frequency, "err", err)
+			}
+		}
+	}
+}
+
+// next returns the frequencies whose digests are due first after now, e.g. daily and weekly digests
+// both due on monday morning, and the time they're due at
+func (d *Digester) next(now time.Time) ([]string, time.Time) {
+	var (
+		due  []string
+		next time.Time
+	)
+
+	for _, frequency := range frequencies {
+		at := d.times[frequency].Next(now)
+
+		switch {
+		case next.IsZero() || at.Before(next):
+			due, next = []string{frequency}, at
+		case at.Equal(next):
+			due = append(due, frequency)
+		}
+	}
+
+	return due, next
+}
+
+// SendDigests mails every subscriber of the frequency the articles posted since their previous digest,
+// subscribers without new articles get nothing. A failed mail doesn't stop the others
+func (d *Digester) SendDigests(ctx context.Context, frequency string) error {
+	subscribers, err := d.subscribers.Subscribers(ctx)
+	if err != nil {
+		return err
+	}
+
+	var (
+		now    = time.Now().UTC()
+		errs   []error
+		sentTo []int64
+	)
+
+	for _, subscriber := range subscribers {
+		if subscriber.Frequency != frequency {
+			continue
+		}
+
+		since := subscriber.LastSentAt
+		if since.IsZero() {
+			since = now.Add(-periods[frequency])
+		}
+
+		articles, err := d.articles.PostedSince(ctx, since)
+		if err != nil {
+			return err
+		}
+
+		if len(articles) == 0 {
+			continue
+		}
+
+		mail, err := renderDigest(subscriber, articles, since, now)
+		if err != nil {
+			return err
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err = d.sender.Send(sendCtx, mail)
+		cancel()
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", subscriber.Email, err))
+			continue
+		}
+
+		logger.Log.Infow("email: digest sent", "to", subscriber.Email, "articles", len(articles))
+		sentTo = append(sentTo, subscriber.ID)
+	}
+
+	if len(sentTo) > 0 {
+		if err := d.subscribers.MarkSent(ctx, sentTo, now); err != nil {
+			return err
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,73 @@
+package email
+
+import (
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/schedule"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigesterNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		daily    string
+		weekly   string
+		now      string
+		wantDue  []string
+		wantNext string
+	}{
+		// 2025-05-05 is a Monday
+		{
+			name:     "daily and weekly coincide",
+			daily:    "09:00",
+			weekly:   "mon 09:00",
+			now:      "2025-05-05 08:00:00",
+			wantDue:  []string{model.DigestDaily, model.DigestWeekly},
+			wantNext: "2025-05-05 09:00:00",
+		},
+		{
+			name:     "daily only on other days",
+			daily:    "09:00",
+			weekly:   "mon 09:00",
+			now:      "2025-05-06 08:00:00",
+			wantDue:  []string{model.DigestDaily},
+			wantNext: "2025-05-06 09:00:00",
+		},
+		{
+			name:     "weekly first",
+			daily:    "18:00",
+			weekly:   "mon 09:00",
+			now:      "2025-05-05 08:00:00",
+			wantDue:  []string{model.DigestWeekly},
+			wantNext: "2025-05-05 09:00:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			daily, err := schedule.ParseTimes(tt.daily, "UTC")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			weekly, err := schedule.ParseTimes(tt.weekly, "UTC")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d := NewDigester(nil, nil, nil, daily, weekly)
+
+			now, _ := time.Parse(time.DateTime, tt.now)
+			wantNext, _ := time.Parse(time.DateTime, tt.wantNext)
+
+			// the order is fixed, map iteration must not decide it
+			for range 50 {
+				due, next := d.next(now)
+				if strings.Join(due, ",") != strings.Join(tt.wantDue, ",") || !next.Equal(wantNext) {
+					t.Fatalf("next() = %v, %s, want %v, %s", due, next, tt.wantDue, wantNext)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package email
+
+import (
+	"bytes"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+	"github.com/samber/lo"
+	"html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// summaryLimit is the maximum length of an article summary in a digest in runes
+const summaryLimit = 300
+
+var subjects = map[string]string{
+	model.DigestDaily:  "Дайджест новостей за день",
+	model.DigestWeekly: "Дайджест новостей за неделю",
+}
+
+type digestData struct {
+	Subject string
+	Since   string
+	Until   string
+	Count   int
+	Sources []digestSource
+}
+
+type digestSource struct {
+	Name     string
+	Articles []digestArticle
+}
+
+type digestArticle struct {
+	Title   string
+	Link    string
+	Summary string
+}
+
+var htmlDigest = template.Must(template.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{ .Subject }}</title></head>
+<body style="font-family: sans-serif; max-width: 640px; margin: 0 auto;">
+<h1 style="font-size: 20px;">{{ .Subject }}</h1>
+<p style="color: #666;">{{ .Since }} — {{ .Until }}, статей: {{ .Count }}</p>
+{{ range .Sources }}
+<h2 style="font-size: 16px; margin-top: 24px;">{{ .Name }}</h2>
+<ul>
+{{ range .Articles }}<li style="margin-bottom: 8px;"><a href="{{ .Link }}">{{ .Title }}</a>{{ if .Summary }}<br><span style="color: #444;">{{ .Summary }}</span>{{ end }}</li>
+{{ end }}</ul>
+{{ end }}
+</body>
+</html>
+`))
+
+var textDigest = texttemplate.Must(texttemplate.New("text").Parse(`{{ .Subject }}
+{{ .Since }} — {{ .Until }}, статей: {{ .Count }}
+{{ range .Sources }}
+{{ .Name }}
+{{ range .Articles }}
+- {{ .Title }}
+  {{ .Link }}{{ if .Summary }}
+  {{ .Summary }}{{ end }}
+{{ end }}{{ end }}`))
+
+// renderDigest renders the articles grouped by source, in the order they come, into a mail to the subscriber
+func renderDigest(subscriber model.Subscriber, articles []model.Article, since, until time.Time) (Mail, error) {
+	data := digestData{
+		Subject: subjects[subscriber.Frequency],
+		Since:   since.Format("02.01.2006 15:04 MST"),
+		Until:   until.Format("02.01.2006 15:04 MST"),
+		Count:   len(articles),
+	}
+
+	for _, article := range articles {
+		name := lo.Ternary(article.SourceName != "", article.SourceName, "Без источника")
+
+		if len(data.Sources) == 0 || data.Sources[len(data.Sources)-1].Name != name {
+			data.Sources = append(data.Sources, digestSource{Name: name})
+		}
+
+		source := &data.Sources[len(data.Sources)-1]
+		source.Articles = append(source.Articles, digestArticle{
+			Title:   article.Title,
+			Link:    article.Link,
+			Summary: shortSummary(article.Summary),
+		})
+	}
+
+	var html, text bytes.Buffer
+
+	if err := htmlDigest.Execute(&html, data); err != nil {
+		return Mail{}, err
+	}
+
+	if err := textDigest.Execute(&text, data); err != nil {
+		return Mail{}, err
+	}
+
+	return Mail{
+		To:      subscriber.Email,
+		Subject: data.Subject,
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// shortSummary strips html from the feed summary and cuts it at a word boundary
+func shortSummary(summary string) string {
+	text := render.PlainText(summary)
+	if utf8.RuneCountInString(text) <= summaryLimit {
+		return text
+	}
+
+	text = string([]rune(text)[:summaryLimit])
+	if space := strings.LastIndex(text, " "); space > len(text)/2 {
+		text = text[:space]
+	}
+
+	return text + "…"
+}
@@ -0,0 +1,237 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpStub is an in-process smtp server that accepts a single mail
+type smtpStub struct {
+	auth       bool   // AUTH is advertised
+	rejectRcpt string // recipient answered with 550
+
+	// filled in by the conversation
+	credentials string
+	from        string
+	rcpt        string
+	data        string
+}
+
+// start serves a single connection and returns the port, the result is ready once done is closed
+func (s *smtpStub) start(t *testing.T) (int, <-chan struct{}) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		s.serve(textproto.NewConn(conn))
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port, done
+}
+
+func (s *smtpStub) serve(c *textproto.Conn) {
+	_ = c.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			if s.auth {
+				_ = c.PrintfLine("250-localhost")
+				_ = c.PrintfLine("250 AUTH PLAIN")
+			} else {
+				_ = c.PrintfLine("250 localhost")
+			}
+		case "AUTH":
+			_, encoded, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(encoded)
+			s.credentials = string(decoded)
+			_ = c.PrintfLine("235 authenticated")
+		case "MAIL":
+			s.from = arg
+			_ = c.PrintfLine("250 ok")
+		case "RCPT":
+			if s.rejectRcpt != "" && strings.Contains(arg, s.rejectRcpt) {
+				_ = c.PrintfLine("550 no such user")
+				continue
+			}
+			s.rcpt = arg
+			_ = c.PrintfLine("250 ok")
+		case "DATA":
+			_ = c.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(c.DotReader())
+			if err != nil {
+				return
+			}
+			s.data = string(data)
+			_ = c.PrintfLine("250 queued")
+		case "RSET", "NOOP":
+			_ = c.PrintfLine("250 ok")
+		case "QUIT":
+			_ = c.PrintfLine("221 bye")
+			return
+		default:
+			_ = c.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func testMail() Mail {
+	return Mail{
+		To:      "Reader <reader@example.com>",
+		Subject: "Дайджест новостей",
+		Text:    "Привет, это текст",
+		HTML:    "<p>Привет, это <b>html</b></p>",
+	}
+}
+
+func TestSMTPSenderSend(t *testing.T) {
+	tests := []struct {
+		name            string
+		stub            smtpStub
+		username        string
+		wantErr         bool
+		wantCredentials string
+	}{
+		{name: "without auth", stub: smtpStub{}},
+		{name: "with auth", stub: smtpStub{auth: true}, username: "bot", wantCredentials: "\x00bot\x00secret"},
+		{name: "auth not supported", stub: smtpStub{}, username: "bot", wantErr: true},
+		{name: "recipient rejected", stub: smtpStub{rejectRcpt: "reader@"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := tt.stub
+			port, done := stub.start(t)
+
+			sender := NewSMTPSender(SMTPConfig{
+				// plain auth is allowed without TLS to a local server only
+				Host:     "127.0.0.1",
+				Port:     port,
+				Username: tt.username,
+				Password: "secret",
+				From:     "News Bot <bot@example.com>",
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			err := sender.Send(ctx, testMail())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Send() = nil error, want error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			<-done
+
+			if stub.credentials != tt.wantCredentials {
+				t.Errorf("credentials = %q, want %q", stub.credentials, tt.wantCredentials)
+			}
+
+			if stub.from != "FROM:<bot@example.com>" || stub.rcpt != "TO:<reader@example.com>" {
+				t.Errorf("envelope %q -> %q", stub.from, stub.rcpt)
+			}
+
+			checkMessage(t, stub.data)
+		})
+	}
+}
+
+// checkMessage parses the received message and checks both parts decode to the mail
+func checkMessage(t *testing.T, data string) {
+	t.Helper()
+
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(data)))
+	if err != nil {
+		t.Fatalf("received message doesn't parse: %v", err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != testMail().Subject {
+		t.Errorf("subject = %q, %v, want %q", subject, err, testMail().Subject)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %q, %v, want multipart/alternative", mediaType, err)
+	}
+
+	var (
+		mr   = multipart.NewReader(msg.Body, params["boundary"])
+		want = []struct{ contentType, body string }{
+			{"text/plain; charset=utf-8", testMail().Text},
+			{"text/html; charset=utf-8", testMail().HTML},
+		}
+	)
+
+	for _, w := range want {
+		part, err := mr.NextRawPart()
+		if err != nil {
+			t.Fatalf("part %s: %v", w.contentType, err)
+		}
+
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if part.Header.Get("Content-Type") != w.contentType || string(body) != w.body {
+			t.Errorf("part %q = %q, want %q %q", part.Header.Get("Content-Type"), body, w.contentType, w.body)
+		}
+	}
+
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("message has more than two parts")
+	}
+}
+
+func TestComposeInvalidAddresses(t *testing.T) {
+	if _, err := compose("not an address", testMail(), time.Now()); err == nil {
+		t.Error("compose() with an invalid sender = nil error, want error")
+	}
+
+	m := testMail()
+	m.To = "@@"
+	if _, err := compose("bot@example.com", m, time.Now()); err == nil {
+		t.Error("compose() with an invalid recipient = nil error, want error")
+	}
+}
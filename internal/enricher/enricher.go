@@ -0,0 +1,215 @@
+package enricher
+
+import (
+	"context"
+	"fmt"
+	"github.com/lostmyescape/news-tg-bot/internal/content"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/logger"
+	"sync"
+	"time"
+)
+
+type ArticleQueue interface {
+	LeaseForEnrichment(ctx context.Context, limit int, leasedUntil time.Time) ([]model.Article, error)
+	SaveContent(ctx context.Context, id int64, content model.ArticleContent) error
+	MarkEnriched(ctx context.Context, id int64, summary string) error
+	MarkEnrichmentFailed(ctx context.Context, id int64, lastError string, retryAt time.Time) (int, error)
+	MarkEnrichmentDead(ctx context.Context, id int64) error
+}
+
+// ArticleEvents tells about newly inserted articles, so they're enriched without waiting for the next poll
+type ArticleEvents interface {
+	Notified(ctx context.Context) <-chan struct{}
+}
+
+type ContentExtractor interface {
+	Extract(ctx context.Context, article model.Article) (model.ArticleContent, error)
+}
+
+type Summarizer interface {
+	Summarize(ctx context.Context, text string) (string, error)
+}
+
+const (
+	// enrichmentLease is how long an article is reserved for a worker, an article of a crashed instance
+	// is enriched again once its lease expires
+	enrichmentLease = 10 * time.Minute
+	// pollInterval is how often the queue is checked when no notifications come
+	pollInterval = time.Minute
+	// retryDelayMax caps the doubling delay between attempts
+	retryDelayMax = 6 * time.Hour
+)
+
+// Enricher is a pool of workers that prepare stored articles for posting: extract the text, image and language
+// of the article and summarize it. Channels only post enriched articles, so posting doesn't wait on the network
+type Enricher struct {
+	articles        ArticleQueue
+	events          ArticleEvents
+	content         ContentExtractor
+	summarizer      Summarizer
+	concurrency     int
+	maxAttempts     int
+	retryDelay      time.Duration
+	summaryFallback string
+}
+
+func New(
+	articleQueue ArticleQueue,
+	articleEvents ArticleEvents,
+	contentExtractor ContentExtractor,
+	summarizer Summarizer,
+	concurrency int,
+	maxAttempts int,
+	retryDelay time.Duration,
+	summaryFallback string,
+) *Enricher {
+	return &Enricher{
+		articles:        articleQueue,
+		events:          articleEvents,
+		content:         contentExtractor,
+		summarizer:      summarizer,
+		concurrency:     max(concurrency, 1),
+		maxAttempts:     max(maxAttempts, 1),
+		retryDelay:      retryDelay,
+		summaryFallback: summaryFallback,
+	}
+}
+
+// Start leases articles for free workers as they come, the queue is read again when an article is inserted,
+// a worker is done after a full lease or every poll interval. Workers are waited for on exit
+func (e *Enricher) Start(ctx context.Context) error {
+	logger.Log.Infow("enricher started", "concurrency", e.concurrency)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var (
+		wg       sync.WaitGroup
+		running  int
+		poll     = true
+		finished = make(chan struct{}, e.concurrency)
+		inserted = e.events.Notified(ctx)
+	)
+
+	defer wg.Wait()
+
+	for {
+		if poll && running < e.concurrency {
+			free := e.concurrency - running
+
+			articles, err := e.articles.LeaseForEnrichment(ctx, free, time.Now().UTC().Add(enrichmentLease))
+			if err != nil && ctx.Err() == nil {
+				logger.Log.Errorw("enricher: failed to lease articles", "err", err)
+			}
+
+			// a full lease means more articles are waiting, they're leased as workers get free
+			poll = len(articles) == free
+
+			for _, article := range articles {
+				running++
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					e.enrich(ctx, article)
+					finished <- struct{}{}
+				}()
+			}
+		}
+
+		select {
+		case <-finished:
+			running--
+		case _, ok := <-inserted:
+			// the events stop when the listener is closed, the ticker polls on its own
+			if !ok {
+				inserted = nil
+				continue
+			}
+			poll = true
+		case <-ticker.C:
+			poll = true
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// enrich extracts the content of the article unless it's saved by a previous attempt, then summarizes it.
+// A failed attempt is retried with backoff, once attempts are exhausted, or right away if the content can't
+// be extracted, the article is posted without a summary or never posted, depending on the summary fallback
+func (e *Enricher) enrich(ctx context.Context, article model.Article) {
+	enrichErr := e.enrichArticle(ctx, article)
+	if enrichErr == nil {
+		logger.Log.Infow("enricher: article enriched", "article", article.ID)
+		return
+	}
+
+	// the lease expires and another attempt is made, the shutdown isn't the article's fault
+	if ctx.Err() != nil {
+		return
+	}
+
+	retryAt := time.Now().UTC().Add(e.backoff(article.EnrichmentAttempts + 1))
+
+	attempts, err := e.articles.MarkEnrichmentFailed(ctx, article.ID, enrichErr.Error(), retryAt)
+	if err != nil {
+		logger.Log.Errorw("enricher: failed to mark article", "article", article.ID, "err", err)
+		return
+	}
+
+	if attempts < e.maxAttempts && !content.IsPermanent(enrichErr) {
+		logger.Log.Warnw(
+			"enricher: failed to enrich article, will retry",
+			"article", article.ID, "attempts", attempts, "retry_at", retryAt, "err", enrichErr,
+		)
+		return
+	}
+
+	if e.summaryFallback == model.SummaryFallbackSkip {
+		logger.Log.Warnw("enricher: giving up on article", "article", article.ID, "attempts", attempts, "err", enrichErr)
+		err = e.articles.MarkEnrichmentDead(ctx, article.ID)
+	} else {
+		logger.Log.Warnw("enricher: article goes without summary", "article", article.ID, "attempts", attempts, "err", enrichErr)
+		err = e.articles.MarkEnriched(ctx, article.ID, "")
+	}
+
+	if err != nil {
+		logger.Log.Errorw("enricher: failed to mark article", "article", article.ID, "err", err)
+	}
+}
+
+func (e *Enricher) enrichArticle(ctx context.Context, article model.Article) error {
+	// the content is saved before summarizing, so a failed summary doesn't download the page again
+	if article.Content == "" {
+		extracted, err := e.content.Extract(ctx, article)
+		if err != nil {
+			return err
+		}
+
+		if err := e.articles.SaveContent(ctx, article.ID, extracted); err != nil {
+			return fmt.Errorf("failed to save content: %w", err)
+		}
+
+		article.Content = extracted.Text
+	}
+
+	summary, err := e.summarizer.Summarize(ctx, article.Content)
+	if err != nil {
+		return err
+	}
+
+	return e.articles.MarkEnriched(ctx, article.ID, summary)
+}
+
+func (e *Enricher) backoff(attempt int) time.Duration {
+	backoff := e.retryDelay
+
+	for i := 1; i < attempt && backoff < retryDelayMax; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, retryDelayMax)
+}
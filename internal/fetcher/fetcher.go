@@ -12,22 +12,37 @@ import (
 )
 
 type ArticleSaver interface {
-	Store(ctx context.Context, article model.Article) error
+	Store(ctx context.Context, article model.Article) (bool, error)
 }
 
-type SourceProvider interface {
-	Sources(ctx context.Context) ([]model.Source, error)
+type FetchRunSaver interface {
+	Store(ctx context.Context, run model.FetchRun) error
+}
+
+type SourceLeaser interface {
+	Lease(ctx context.Context, dueBefore time.Time, leasedUntil time.Time) ([]model.Source, error)
+	Release(ctx context.Context, id int64, fetchedAt time.Time) error
 }
 
 type Source interface {
 	ID() int64
 	Name() string
-	Fetch(ctx context.Context) ([]model.Item, error)
+	Fetch(ctx context.Context) ([]model.Item, int, error)
 }
 
+const (
+	// sourceLease is how long an instance holds a source it fetches, a source of a crashed instance
+	// is fetched by another one once the lease expires
+	sourceLease = 10 * time.Minute
+	// fetchIntervalSlack makes a source due slightly before the fetch interval passes,
+	// so the instance that fetched it last doesn't skip a round because of ticker jitter
+	fetchIntervalSlack = 10 * time.Second
+)
+
 type Fetcher struct {
-	articles ArticleSaver
-	sources  SourceProvider
+	articles  ArticleSaver
+	sources   SourceLeaser
+	fetchRuns FetchRunSaver
 
 	fetchInterval  time.Duration
 	filterKeywords []string
@@ -35,13 +50,15 @@ type Fetcher struct {
 
 func New(
 	articleSaver ArticleSaver,
-	sourceProvider SourceProvider,
+	sourceLeaser SourceLeaser,
+	fetchRunSaver FetchRunSaver,
 	fetchInterval time.Duration,
 	filterKeywords []string,
 ) *Fetcher {
 	return &Fetcher{
 		articles:       articleSaver,
-		sources:        sourceProvider,
+		sources:        sourceLeaser,
+		fetchRuns:      fetchRunSaver,
 		fetchInterval:  fetchInterval,
 		filterKeywords: filterKeywords,
 	}
@@ -68,10 +85,12 @@ func (f *Fetcher) Start(ctx context.Context) error {
 	}
 }
 
-// Fetch loads data from all sources, wraps each sources in goroutine,
+// Fetch leases sources that are due, so several instances share them, wraps each source in goroutine,
 // parses rss-feed and sends result to processItems
 func (f *Fetcher) Fetch(ctx context.Context) error {
-	sources, err := f.sources.Sources(ctx)
+	now := time.Now().UTC()
+
+	sources, err := f.sources.Lease(ctx, now.Add(-f.fetchInterval+fetchIntervalSlack), now.Add(sourceLease))
 	if err != nil {
 		return err
 	}
@@ -84,19 +103,15 @@ func (f *Fetcher) Fetch(ctx context.Context) error {
 		go func(source Source) {
 			defer wg.Done()
 
-			items, err := source.Fetch(ctx)
-			if err != nil {
-				log.Printf("Error: fetching items from source %s: %v", source.Name(), err)
-				return
-			}
+			run := f.fetchSource(ctx, source)
 
-			if err := f.processItems(ctx, source, items); err != nil {
-				log.Printf("Error: processing items from source %s: %v", source.Name(), err)
-				return
+			if err := f.fetchRuns.Store(ctx, run); err != nil {
+				logger.Log.Errorw("fetcher: failed to save fetch run", "source", source.Name(), "err", err)
 			}
 
-			logger.Log.Infof("fetcher: processed items for source %s", source.Name())
-
+			if err := f.sources.Release(ctx, source.ID(), run.StartedAt); err != nil {
+				logger.Log.Errorw("fetcher: failed to release source", "source", source.Name(), "err", err)
+			}
 		}(source.NewRSSSourceFromModel(src))
 	}
 
@@ -105,28 +120,69 @@ func (f *Fetcher) Fetch(ctx context.Context) error {
 	return nil
 }
 
-// processItems base logic - normalizes the date, filters items, saves article
-func (f *Fetcher) processItems(ctx context.Context, source Source, items []model.Item) error {
+// fetchSource fetches a single source and processes its items, the outcome is returned as a fetch run
+func (f *Fetcher) fetchSource(ctx context.Context, source Source) model.FetchRun {
+	run := model.FetchRun{
+		SourceID:  source.ID(),
+		StartedAt: time.Now().UTC(),
+	}
+
+	items, status, err := source.Fetch(ctx)
+	run.HTTPStatus = status
+
+	if err != nil {
+		log.Printf("Error: fetching items from source %s: %v", source.Name(), err)
+		run.Errors++
+		run.LastError = err.Error()
+	} else {
+		f.processItems(ctx, source, items, &run)
+		logger.Log.Infof("fetcher: processed items for source %s", source.Name())
+	}
+
+	run.Duration = time.Since(run.StartedAt)
+
+	return run
+}
+
+// processItems base logic - normalizes the date, filters items, saves article,
+// counts the outcome of every item in run
+func (f *Fetcher) processItems(ctx context.Context, source Source, items []model.Item, run *model.FetchRun) {
 	for _, item := range items {
+		run.ItemsSeen++
 		item.Date = item.Date.UTC()
 
 		if f.itemShouldBeSkipped(item) {
+			run.ItemsFiltered++
 			continue
 		}
 
-		if err := f.articles.Store(ctx, model.Article{
+		inserted, err := f.articles.Store(ctx, model.Article{
 			SourceID:    source.ID(),
 			Title:       item.Title,
 			Link:        item.Link,
 			Summary:     item.Summary,
+			Tags:        item.Categories,
+			Language:    item.Language,
+			CommentsURL: item.CommentsURL,
 			PublishedAt: item.Date,
-		}); err != nil {
-			return err
+		})
+		if err != nil {
+			log.Printf("Error: processing items from source %s: %v", source.Name(), err)
+			run.Errors++
+			run.LastError = err.Error()
+			continue
 		}
-	}
-	logger.Log.Infof("fetcher: got %d items from %s", len(items), source.Name())
 
-	return nil
+		if inserted {
+			run.ItemsNew++
+		} else {
+			run.ItemsDuplicate++
+		}
+	}
+	logger.Log.Infof(
+		"fetcher: got %d items from %s (new %d, duplicate %d, filtered %d, errors %d)",
+		run.ItemsSeen, source.Name(), run.ItemsNew, run.ItemsDuplicate, run.ItemsFiltered, run.Errors,
+	)
 }
 
 // itemShouldBeSkipped skips an item if the category or title contains keywords
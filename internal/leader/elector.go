@@ -0,0 +1,236 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/logger"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LockKey is the advisory lock key instances sharing a database compete for
+const LockKey int64 = 0x6e657773
+
+const (
+	// restartBackoffBase is the delay before a failed component is restarted, it doubles with every failure
+	restartBackoffBase = time.Second
+	restartBackoffMax  = time.Minute
+)
+
+type Lock interface {
+	TryAcquire(ctx context.Context) (bool, error)
+	Check(ctx context.Context) error
+	Release(ctx context.Context) error
+}
+
+type InstanceRegistry interface {
+	Heartbeat(ctx context.Context, instance model.Instance) error
+}
+
+// Mode tells where a component runs
+type Mode int
+
+const (
+	// EveryReplica components run on every instance and share the work through leases
+	EveryReplica Mode = iota
+	// LeaderOnly components run on the leader only and move to the next leader on failover
+	LeaderOnly
+)
+
+type component struct {
+	name string
+	mode Mode
+	run  func(ctx context.Context) error
+}
+
+// Elector elects a leader among instances sharing a database and runs registered components on it.
+// The leader holds an advisory lock, the lock is checked on every heartbeat, and if the leader loses
+// its database session the lock is released by postgres and taken by another instance
+type Elector struct {
+	lock      Lock
+	instances InstanceRegistry
+	id        string
+	heartbeat time.Duration
+
+	components []component
+
+	mu      sync.Mutex
+	running map[string]struct{}
+}
+
+func New(lock Lock, instanceRegistry InstanceRegistry, id string, heartbeat time.Duration) *Elector {
+	return &Elector{
+		lock:      lock,
+		instances: instanceRegistry,
+		id:        id,
+		heartbeat: heartbeat,
+		running:   make(map[string]struct{}),
+	}
+}
+
+// Register declares a component with the mode it runs in, components must be registered before Run
+func (e *Elector) Register(name string, mode Mode, run func(ctx context.Context) error) {
+	e.components = append(e.components, component{name: name, mode: mode, run: run})
+}
+
+// Run starts components running on every replica and competes for leadership every heartbeat,
+// leader-only components are started when the instance is elected and stopped when it loses the lock.
+// The lock is released when the context is done, so another instance takes over right away
+func (e *Elector) Run(ctx context.Context) error {
+	var (
+		wg       sync.WaitGroup
+		instance = model.Instance{ID: e.id, StartedAt: time.Now().UTC()}
+		stepDown func()
+	)
+
+	e.start(ctx, &wg, EveryReplica)
+
+	ticker := time.NewTicker(e.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		if stepDown == nil {
+			var acquired bool
+
+			if err := e.withTimeout(ctx, func(ctx context.Context) (err error) {
+				acquired, err = e.lock.TryAcquire(ctx)
+				return err
+			}); err != nil {
+				logger.Log.Errorw("leader: failed to acquire lock", "err", err)
+			}
+
+			if acquired {
+				logger.Log.Infow("leader: elected", "instance", e.id)
+
+				var leaderWG sync.WaitGroup
+
+				leaderCtx, cancel := context.WithCancel(ctx)
+				e.start(leaderCtx, &leaderWG, LeaderOnly)
+
+				// leader-only components stop before the lock is given up, so they never run twice
+				stepDown = func() {
+					cancel()
+					leaderWG.Wait()
+					stepDown = nil
+
+					if err := e.withTimeout(context.WithoutCancel(ctx), e.lock.Release); err != nil {
+						logger.Log.Errorw("leader: failed to release lock", "err", err)
+					}
+				}
+
+				instance.Leader, instance.LeaderSince = true, time.Now().UTC()
+			}
+		} else if err := e.withTimeout(ctx, e.lock.Check); err != nil && ctx.Err() == nil {
+			logger.Log.Errorw("leader: lost leadership", "instance", e.id, "err", err)
+			stepDown()
+			instance.Leader, instance.LeaderSince = false, time.Time{}
+		}
+
+		instance.Components = e.Running()
+		if err := e.withTimeout(ctx, func(ctx context.Context) error {
+			return e.instances.Heartbeat(ctx, instance)
+		}); err != nil && ctx.Err() == nil {
+			logger.Log.Errorw("leader: failed to send heartbeat", "err", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if stepDown != nil {
+				stepDown()
+			}
+			wg.Wait()
+
+			return ctx.Err()
+		}
+	}
+}
+
+// withTimeout limits a database call to a heartbeat, a hung connection must not keep a lost leader running
+func (e *Elector) withTimeout(ctx context.Context, call func(ctx context.Context) error) error {
+	callCtx, cancel := context.WithTimeout(ctx, e.heartbeat)
+	defer cancel()
+
+	return call(callCtx)
+}
+
+// Running returns the names of the components running on the instance
+func (e *Elector) Running() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	names := make([]string, 0, len(e.running))
+	for name := range e.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// start runs the components of the mode until the context is done. A component that stops on its own,
+// e.g. the bot failing to delete its webhook, is restarted with backoff, the leader holds the lock and
+// no other instance would run it
+func (e *Elector) start(ctx context.Context, wg *sync.WaitGroup, mode Mode) {
+	for _, c := range e.components {
+		if c.mode != mode {
+			continue
+		}
+
+		e.setRunning(c.name, true)
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer e.setRunning(c.name, false)
+
+			backoff := restartBackoffBase
+
+			for {
+				e.setRunning(c.name, true)
+				startedAt := time.Now()
+
+				err := c.run(ctx)
+				if ctx.Err() != nil {
+					logger.Log.Infow("leader: component stopped", "component", c.name)
+					return
+				}
+
+				e.setRunning(c.name, false)
+
+				// a component that ran for a while failed anew, not again
+				if time.Since(startedAt) > restartBackoffMax {
+					backoff = restartBackoffBase
+				}
+
+				if err != nil && !errors.Is(err, context.Canceled) {
+					logger.Log.Errorw("leader: component failed, restarting", "component", c.name, "err", err, "in", backoff)
+				} else {
+					logger.Log.Warnw("leader: component returned, restarting", "component", c.name, "in", backoff)
+				}
+
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					logger.Log.Infow("leader: component stopped", "component", c.name)
+					return
+				}
+
+				backoff = min(backoff*2, restartBackoffMax)
+			}
+		}()
+	}
+}
+
+func (e *Elector) setRunning(name string, running bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if running {
+		e.running[name] = struct{}{}
+	} else {
+		delete(e.running, name)
+	}
+}
@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"time"
+)
 
 type Item struct {
 	Title      string
@@ -9,6 +12,9 @@ type Item struct {
 	Date       time.Time
 	Summary    string
 	SourceName string
+	Language   string
+	// CommentsURL links to the discussion of the item, e.g. on HN, empty if the feed has none
+	CommentsURL string
 }
 
 type Source struct {
@@ -17,6 +23,11 @@ type Source struct {
 	FeedURL   string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	Priority  int
+	// Template overrides the channel post template for articles of the source
+	Template string
+	// FreshnessWindow overrides the global freshness window for articles of the source, zero means the global one
+	FreshnessWindow time.Duration
 }
 
 type Article struct {
@@ -25,7 +36,289 @@ type Article struct {
 	Title       string
 	Link        string
 	Summary     string
+	Tags        []string
+	Language    string
+	CommentsURL string
 	PublishedAt time.Time
 	PostedAt    time.Time
 	CreatedAt   time.Time
+	// Content is the extracted text of the article, empty until it is extracted
+	Content  string
+	ImageURL string
+	Author   string
+	// GeneratedSummary is the summary of the content made by the summarizer
+	GeneratedSummary string
+
+	EnrichmentStatus   string
+	EnrichmentAttempts int
+	EnrichmentError    string
+	EnrichedAt         time.Time
+
+	// SourcePriority, SourceName and SourceTemplate describe the article source, filled in for posting candidates only
+	SourcePriority int
+	SourceName     string
+	SourceTemplate string
+	// ReviewedSummary is the summary approved by moderators, filled in for moderated deliveries only
+	ReviewedSummary string
+}
+
+// ArticleContent is what is extracted from the article page or the full text feed
+type ArticleContent struct {
+	Text     string
+	ImageURL string
+	Language string
+	Author   string
+	// PublishedAt is the date found on the page, zero if there is none
+	PublishedAt time.Time
+}
+
+// ExtractionRule tunes content extraction for the pages of a domain and its subdomains, selectors are CSS selectors.
+// Elements matching Remove are dropped, the text of elements matching Content is taken instead of readability
+type ExtractionRule struct {
+	ID        int64
+	Domain    string
+	Content   string
+	Remove    string
+	Author    string
+	Date      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Enrichment statuses of an article, only enriched articles are posted
+const (
+	EnrichmentStatusPending   = "pending"
+	EnrichmentStatusEnriching = "enriching"
+	EnrichmentStatusEnriched  = "enriched"
+	EnrichmentStatusFailed    = "failed"
+	EnrichmentStatusDead      = "dead"
+)
+
+// Orders the channel queue is read in
+const (
+	QueueNewest = "newest"
+	QueueOldest = "oldest"
+	// QueueNewestPerSource takes the newest article of every source
+	QueueNewestPerSource = "newest_per_source"
+)
+
+// QueueQuery selects the next posting candidates from the queue of the channel
+type QueueQuery struct {
+	ChannelID int64
+	// Order is one of Queue* constants
+	Order string
+	Limit int
+	// ExcludeSources are sources whose articles are skipped, e.g. ones that reached the posting cap
+	ExcludeSources []int64
+	// FreshnessWindow bounds articles already posted to other channels by the time they were posted,
+	// older ones have expired for the channel. Zero means no bound
+	FreshnessWindow time.Duration
+}
+
+type FetchRun struct {
+	ID             int64
+	SourceID       int64
+	StartedAt      time.Time
+	Duration       time.Duration
+	HTTPStatus     int
+	ItemsSeen      int
+	ItemsNew       int
+	ItemsDuplicate int
+	ItemsFiltered  int
+	Errors         int
+	LastError      string
+}
+
+type SourceStats struct {
+	SourceID       int64
+	Since          time.Time
+	Fetches        int
+	FetchesOK      int
+	ItemsNew       int
+	Articles       int
+	ArticlesPosted int
+	// ArticlesExpired counts articles that were not posted in time to some channel
+	ArticlesExpired int
+	MedianDelay     time.Duration
+}
+
+type Channel struct {
+	ID              int64
+	Name            string
+	ChatID          int64
+	PostingInterval time.Duration
+	CreatedAt       time.Time
+
+	// Strategy is the name of the queue selection strategy, see notifier.NewStrategy
+	Strategy string
+	// MaxPostsPerSourcePerHour caps posts of a single source, zero means no cap
+	MaxPostsPerSourcePerHour int
+	// Schedule lists posting windows in the channel timezone, see schedule.Parse, empty means always
+	Schedule string
+	Timezone string
+	// Mode is either ChannelModeStream, one post per article, or ChannelModeDigest, periodic summaries
+	Mode string
+	// DigestTimes lists when digests are posted in the channel timezone, see schedule.ParseTimes
+	DigestTimes string
+	// DigestGroupBy is how digest sections are made, DigestGroupBySource or DigestGroupByTag
+	DigestGroupBy string
+	// Template is the post template, see render.Parse, empty means the default one
+	Template string
+	// Format is the parse mode of the template, render.FormatMarkdownV2 or render.FormatHTML
+	Format string
+	// FeedbackButtons adds 👍/👎 buttons to posts
+	FeedbackButtons bool
+	// ModerationChatID is the chat where posts are reviewed before going to the channel, zero disables moderation
+	ModerationChatID int64
+	// ModerationTimeout is how long a post waits for review before ModerationTimeoutAction is applied
+	ModerationTimeout       time.Duration
+	ModerationTimeoutAction string
+	// Publisher is the kind of the destination, one of Publisher* constants, ChatID is used by telegram
+	// and WebhookURL by the others
+	Publisher  string
+	WebhookURL string
+	// Backlog makes the channel receive articles stored before it was created that aren't posted anywhere
+	Backlog bool
+}
+
+const (
+	ChannelModeStream = "stream"
+	ChannelModeDigest = "digest"
+)
+
+const (
+	DigestGroupBySource = "source"
+	DigestGroupByTag    = "tag"
+)
+
+const (
+	PublisherTelegram = "telegram"
+	PublisherDiscord  = "discord"
+	PublisherSlack    = "slack"
+	PublisherWebhook  = "webhook"
+)
+
+const (
+	ModerationApprove = "approve"
+	ModerationExpire  = "expire"
+)
+
+// What to do with an article that couldn't be summarized
+const (
+	SummaryFallbackPost = "post"
+	SummaryFallbackSkip = "skip"
+)
+
+// Route sends articles of the source or, if SourceID is zero, articles with the tag to the channel
+type Route struct {
+	SourceID  int64
+	Tag       string
+	ChannelID int64
+}
+
+const (
+	DeliveryStatusPending = "pending"
+	DeliveryStatusSending = "sending"
+	DeliveryStatusSent    = "sent"
+	DeliveryStatusFailed  = "failed"
+	DeliveryStatusDead    = "dead"
+	// DeliveryStatusExpired marks articles that got older than the freshness window before being posted
+	DeliveryStatusExpired = "expired"
+	// DeliveryStatusModeration marks posts waiting for review in the moderation chat
+	DeliveryStatusModeration = "moderation"
+	DeliveryStatusApproved   = "approved"
+	DeliveryStatusRejected   = "rejected"
+)
+
+// Votes counts feedback of readers on an article
+type Votes struct {
+	Up   int
+	Down int
+}
+
+type Delivery struct {
+	ID            int64
+	ArticleID     int64
+	ChannelID     int64
+	Status        string
+	Attempts      int
+	LastError     string
+	MessageID     int
+	NextAttemptAt time.Time
+	PostedAt      time.Time
+	UpdatedAt     time.Time
+	// Summary is the summary reviewed by moderators, posted instead of a fresh one
+	Summary         string
+	ReviewMessageID int
+
+	// ArticleTitle is filled in for listings only
+	ArticleTitle string
+}
+
+const (
+	ScheduledPostStatusDraft     = "draft"
+	ScheduledPostStatusScheduled = "scheduled"
+	ScheduledPostStatusSending   = "sending"
+	ScheduledPostStatusSent      = "sent"
+	ScheduledPostStatusFailed    = "failed"
+	ScheduledPostStatusDead      = "dead"
+	ScheduledPostStatusCancelled = "cancelled"
+)
+
+// Media types of scheduled posts, an empty type means a text post
+const (
+	MediaPhoto     = "photo"
+	MediaVideo     = "video"
+	MediaAnimation = "animation"
+	MediaDocument  = "document"
+)
+
+// ScheduledPost is an announcement composed by an admin, it is a draft until the admin chooses
+// to publish it now or at PublishAt
+type ScheduledPost struct {
+	ID        int64
+	ChannelID int64
+	AuthorID  int64
+	// Content is the text of the post or the caption of its media
+	Content     markup.Message
+	MediaType   string
+	MediaFileID string
+	Status      string
+	Attempts    int
+	LastError   string
+	MessageID   int
+	PublishAt   time.Time
+	PostedAt    time.Time
+	CreatedAt   time.Time
+
+	// ChannelName, ChatID and Timezone are filled in from the channel
+	ChannelName string
+	ChatID      int64
+	Timezone    string
+}
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Subscriber gets email digests of posted articles
+type Subscriber struct {
+	ID    int64
+	Email string
+	// Frequency is DigestDaily or DigestWeekly
+	Frequency  string
+	LastSentAt time.Time
+	CreatedAt  time.Time
+}
+
+// Instance is a running replica of the bot as seen by its last heartbeat
+type Instance struct {
+	ID     string
+	Leader bool
+	// Components are the names of the components running on the instance
+	Components  []string
+	StartedAt   time.Time
+	LeaderSince time.Time
+	HeartbeatAt time.Time
 }
@@ -0,0 +1,102 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/logger"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// maxDeliveryAttempts is the number of attempts after which a delivery goes to dead-letter
+	maxDeliveryAttempts = 5
+	// deliveryBackoffBase is the delay before the first retry, it doubles with every attempt
+	deliveryBackoffBase = time.Minute
+	deliveryBackoffMax  = time.Hour
+	// sendingLease is how long an instance holds a delivery or a post it sends, if the instance crashes
+	// between sending and noting the result, the delivery is retried once the lease expires
+	sendingLease = 10 * time.Minute
+)
+
+// errRenderFailed marks errors of post rendering, a broken template won't render on retry either
+var errRenderFailed = errors.New("failed to render post")
+
+// permanentErrors are telegram error descriptions that won't go away on retry
+var permanentErrors = []string{
+	"can't parse entities",
+	"chat not found",
+	"message is too long",
+	"bot was kicked",
+	"not enough rights",
+	"need administrator rights",
+}
+
+// recordFailure notes a failed delivery attempt, the delivery is retried with backoff
+// unless the error is permanent or attempts are exhausted
+func (n *Notifier) recordFailure(ctx context.Context, channel model.Channel, article model.Article, attempt int, sendErr error) error {
+	if isPermanentSendError(sendErr) || attempt >= maxDeliveryAttempts {
+		logger.Log.Errorw(
+			"notifier: delivery moved to dead-letter",
+			"channel", channel.Name, "article", article.ID, "attempt", attempt, "err", sendErr,
+		)
+
+		return n.deliveries.MarkDead(ctx, article.ID, channel.ID, sendErr.Error())
+	}
+
+	nextAttemptAt := time.Now().UTC().Add(deliveryBackoff(attempt))
+
+	logger.Log.Warnw(
+		"notifier: delivery failed, will retry",
+		"channel", channel.Name, "article", article.ID, "attempt", attempt, "retry_at", nextAttemptAt, "err", sendErr,
+	)
+
+	return n.deliveries.MarkFailed(ctx, article.ID, channel.ID, sendErr.Error(), nextAttemptAt)
+}
+
+func deliveryBackoff(attempt int) time.Duration {
+	backoff := deliveryBackoffBase
+
+	for i := 1; i < attempt && backoff < deliveryBackoffMax; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, deliveryBackoffMax)
+}
+
+func isPermanentSendError(err error) bool {
+	if errors.Is(err, errRenderFailed) {
+		return true
+	}
+
+	var hookErr *webhookError
+	if errors.As(err, &hookErr) {
+		return hookErr.permanent()
+	}
+
+	var tgErr *tgbotapi.Error
+	if !errors.As(err, &tgErr) {
+		return false
+	}
+
+	if tgErr.Code == http.StatusForbidden {
+		return true
+	}
+
+	if tgErr.Code != http.StatusBadRequest {
+		return false
+	}
+
+	description := strings.ToLower(tgErr.Message)
+
+	for _, permanent := range permanentErrors {
+		if strings.Contains(description, permanent) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,182 @@
+package notifier
+
+import (
+	"context"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+	"github.com/lostmyescape/news-tg-bot/internal/schedule"
+	"github.com/lostmyescape/news-tg-bot/logger"
+	"github.com/samber/lo"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// digestSummaryLimit is the maximum length of a one-line summary in a digest in runes
+	digestSummaryLimit = 200
+	// digestMaxArticles is the maximum number of articles in a digest, the newest ones are taken
+	digestMaxArticles = 100
+)
+
+// digestPart is a single digest message and the articles it lists
+type digestPart struct {
+	message  markup.Message
+	articles []model.Article
+}
+
+// runDigest posts a digest to the channel at every digest time
+func (n *Notifier) runDigest(ctx context.Context, channel model.Channel) error {
+	times, err := schedule.ParseTimes(channel.DigestTimes, channel.Timezone)
+	if err != nil {
+		return err
+	}
+
+	for {
+		next := times.Next(time.Now())
+		logger.Log.Infof("notifier: next digest for channel %s at %s", channel.Name, next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+
+		if err := n.SendDigest(ctx, channel); err != nil {
+			logger.Log.Errorw("notifier: failed to send digest", "channel", channel.Name, "err", err)
+		}
+	}
+}
+
+// SendDigest posts the newest articles not yet posted to the channel as a digest grouped by source or tag,
+// the digest is split into several messages if it doesn't fit into one
+func (n *Notifier) SendDigest(ctx context.Context, channel model.Channel) error {
+	if err := n.expireStale(ctx, channel); err != nil {
+		return err
+	}
+
+	articles, err := n.articles.Candidates(ctx, n.queueQuery(channel, model.QueueNewest, digestMaxArticles))
+	if err != nil {
+		return err
+	}
+
+	logger.Log.Infof("notifier: found %d articles for digest to %s", len(articles), channel.Name)
+
+	if len(articles) == 0 {
+		return nil
+	}
+
+	for _, part := range renderDigest(articles, channel.DigestGroupBy) {
+		msg := part.message.Config(channel.ChatID)
+		msg.DisableWebPagePreview = true
+
+		sent, err := n.bot.Send(msg)
+		if err != nil {
+			return err
+		}
+
+		// articles are marked part by part so a failure doesn't repeat parts already posted,
+		// articles of parts not posted stay in the queue for the next digest
+		if err := n.deliveries.MarkSent(ctx, part.articles, channel.ID, sent.MessageID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renderDigest renders articles grouped by source or by their first tag, see model.DigestGroupBy*,
+// into messages within the telegram length limit
+func renderDigest(articles []model.Article, groupBy string) []digestPart {
+	var (
+		byGroup = lo.GroupBy(articles, digestGroup(groupBy))
+		groups  = lo.Keys(byGroup)
+		parts   []digestPart
+		current = markup.NewBuilder().Bold("Дайджест новостей").Textf(" (%d)", len(articles))
+		listed  []model.Article
+	)
+
+	sort.Strings(groups)
+
+	for _, groupName := range groups {
+		group := byGroup[groupName]
+		sort.Slice(group, func(i, j int) bool { return group[i].PublishedAt.After(group[j].PublishedAt) })
+
+		if groupName == "" {
+			groupName = lo.Ternary(groupBy == model.DigestGroupByTag, "Без тега", "Без источника")
+		}
+
+		for i, article := range group {
+			line := markup.NewBuilder().Text("\n• ").Append(renderDigestItem(article)).Message()
+
+			block := line
+			if i == 0 {
+				block = markup.NewBuilder().Text("\n\n").Bold(groupName).Append(line).Message()
+			}
+
+			if len(listed) > 0 && current.Len()+block.Len() > markup.MessageLimit {
+				parts = append(parts, digestPart{message: current.Message(), articles: listed})
+				current = markup.NewBuilder().Bold(groupName)
+				listed = nil
+				block = line
+			}
+
+			current.Append(block)
+			listed = append(listed, article)
+		}
+	}
+
+	return append(parts, digestPart{message: current.Message(), articles: listed})
+}
+
+// digestGroup returns the section of an article in the digest: its source name or its first tag
+// in lower case, so tags differing only in case share a section
+func digestGroup(groupBy string) func(model.Article) string {
+	if groupBy == model.DigestGroupByTag {
+		return func(a model.Article) string {
+			if len(a.Tags) == 0 {
+				return ""
+			}
+			return strings.ToLower(strings.TrimSpace(a.Tags[0]))
+		}
+	}
+
+	return func(a model.Article) string { return a.SourceName }
+}
+
+func renderDigestItem(article model.Article) markup.Message {
+	item := markup.NewBuilder().Link(article.Title, article.Link)
+
+	if summary := oneLineSummary(digestSummary(article)); summary != "" {
+		item.Text(" — " + summary)
+	}
+
+	return item.Message()
+}
+
+// digestSummary returns the summary of the article as plain text on one line, the generated summary
+// single posts use is preferred and the feed summary with html stripped is the fallback
+func digestSummary(article model.Article) string {
+	if article.GeneratedSummary != "" {
+		return strings.Join(strings.Fields(markup.ParseCommonMark(article.GeneratedSummary).Text), " ")
+	}
+
+	return render.PlainText(article.Summary)
+}
+
+// oneLineSummary keeps the first sentence of the summary
+func oneLineSummary(text string) string {
+	if i := strings.Index(text, ". "); i >= 0 {
+		text = text[:i+1]
+	}
+
+	if runes := []rune(text); len(runes) > digestSummaryLimit {
+		text = strings.TrimSpace(string(runes[:digestSummaryLimit-1])) + "…"
+	}
+
+	return text
+}
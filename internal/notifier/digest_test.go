@@ -0,0 +1,137 @@
+package notifier
+
+import (
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestRenderDigestGroups(t *testing.T) {
+	articles := []model.Article{
+		{ID: 1, Title: "Go 1.25", SourceName: "Go Blog", Tags: []string{"Golang", "release"}},
+		{ID: 2, Title: "Rust 2.0", SourceName: "HN", Tags: []string{"rust"}},
+		{ID: 3, Title: "Generics", SourceName: "HN", Tags: []string{"golang"}},
+		{ID: 4, Title: "No tags", SourceName: ""},
+	}
+
+	tests := []struct {
+		groupBy string
+		want    []string
+	}{
+		{groupBy: model.DigestGroupBySource, want: []string{"Без источника", "Go Blog", "HN"}},
+		{groupBy: model.DigestGroupByTag, want: []string{"Без тега", "golang", "rust"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.groupBy, func(t *testing.T) {
+			parts := renderDigest(articles, tt.groupBy)
+			if len(parts) != 1 {
+				t.Fatalf("renderDigest() = %d parts, want 1", len(parts))
+			}
+
+			if got := sectionTitles(parts[0].message); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("sections = %q, want %q", got, tt.want)
+			}
+
+			if len(parts[0].articles) != len(articles) {
+				t.Errorf("digest lists %d articles, want %d", len(parts[0].articles), len(articles))
+			}
+		})
+	}
+}
+
+func TestRenderDigestSplitsLongDigests(t *testing.T) {
+	var articles []model.Article
+
+	for i := range 200 {
+		articles = append(articles, model.Article{
+			ID:         int64(i + 1),
+			Title:      strings.Repeat("long title ", 5),
+			Link:       "https://example.com/article",
+			SourceName: "Source",
+		})
+	}
+
+	parts := renderDigest(articles, model.DigestGroupBySource)
+	if len(parts) < 2 {
+		t.Fatalf("renderDigest() = %d parts, want the digest split", len(parts))
+	}
+
+	listed := 0
+
+	for i, part := range parts {
+		if part.message.Len() > markup.MessageLimit {
+			t.Errorf("part %d is %d long, over the limit", i, part.message.Len())
+		}
+
+		// every part starts with the section it continues
+		if titles := sectionTitles(part.message); i > 0 && (len(titles) == 0 || titles[0] != "Source") {
+			t.Errorf("part %d starts with sections %q, want Source", i, titles)
+		}
+
+		listed += len(part.articles)
+	}
+
+	if listed != len(articles) {
+		t.Errorf("parts list %d articles, want %d", listed, len(articles))
+	}
+}
+
+// sectionTitles returns the bold texts of the message except the digest heading
+func sectionTitles(m markup.Message) []string {
+	var (
+		titles []string
+		units  = utf16.Encode([]rune(m.Text))
+	)
+
+	for _, entity := range m.Entities {
+		if entity.Type != markup.EntityBold {
+			continue
+		}
+
+		title := string(utf16.Decode(units[entity.Offset : entity.Offset+entity.Length]))
+		if title != "Дайджест новостей" {
+			titles = append(titles, title)
+		}
+	}
+
+	return titles
+}
+
+func TestRenderDigestItemSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		article model.Article
+		want    string
+	}{
+		{
+			name: "generated summary",
+			article: model.Article{
+				Title:            "Go 1.25",
+				Summary:          "<p>Feed summary. More text.</p>",
+				GeneratedSummary: "**Вышел Go 1.25**\nс новым GC. Подробности в блоге.",
+			},
+			want: "Go 1.25 — Вышел Go 1.25 с новым GC.",
+		},
+		{
+			name:    "feed summary without a generated one",
+			article: model.Article{Title: "Go 1.25", Summary: "<p>Feed summary. More <b>text</b>.</p>"},
+			want:    "Go 1.25 — Feed summary.",
+		},
+		{
+			name:    "no summary",
+			article: model.Article{Title: "Go 1.25"},
+			want:    "Go 1.25",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderDigestItem(tt.article).Text; got != tt.want {
+				t.Errorf("renderDigestItem() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
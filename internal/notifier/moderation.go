@@ -0,0 +1,137 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+	"github.com/lostmyescape/news-tg-bot/logger"
+	"time"
+)
+
+// moderationBatch is the maximum number of articles sent for review per posting interval
+const moderationBatch = 10
+
+// submitForReview applies the timeout action to posts left without review and sends new articles
+// to the moderation chat of the channel
+func (n *Notifier) submitForReview(ctx context.Context, channel model.Channel) error {
+	if err := n.resolveTimedOutReviews(ctx, channel); err != nil {
+		return err
+	}
+
+	if err := n.expireStale(ctx, channel); err != nil {
+		return err
+	}
+
+	articles, err := n.articles.Candidates(ctx, n.queueQuery(channel, model.QueueNewest, moderationBatch))
+	if err != nil {
+		return err
+	}
+
+	for _, article := range articles {
+		message, err := render.ReviewPost(channel, article, article.GeneratedSummary)
+		if err != nil {
+			// a broken template won't render on the next tick either, the article goes to dead-letter
+			attempt, ok, leaseErr := n.deliveries.Lease(ctx, article.ID, channel.ID, time.Now().UTC().Add(sendingLease))
+			if leaseErr != nil {
+				return leaseErr
+			}
+
+			if !ok {
+				continue
+			}
+
+			if err := n.recordFailure(ctx, channel, article, attempt, fmt.Errorf("%w: %v", errRenderFailed, err)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		// the post is claimed before it is sent, so replicas polling the same channel don't send it twice
+		claimed, err := n.deliveries.ClaimForReview(ctx, article.ID, channel.ID, article.GeneratedSummary)
+		if err != nil {
+			return err
+		}
+
+		if !claimed {
+			continue
+		}
+
+		msg := message.Config(channel.ModerationChatID)
+		msg.ReplyMarkup = render.ModerationKeyboard(article.ID, channel.ID)
+
+		sent, err := n.bot.Send(msg)
+		if err != nil {
+			if releaseErr := n.deliveries.ReleaseReview(ctx, article.ID, channel.ID); releaseErr != nil {
+				logger.Log.Errorw("notifier: failed to release post for review", "channel", channel.Name, "article", article.ID, "err", releaseErr)
+			}
+
+			return err
+		}
+
+		if err := n.deliveries.SetReviewMessage(ctx, article.ID, channel.ID, sent.MessageID); err != nil {
+			return err
+		}
+
+		logger.Log.Infow("notifier: article sent for review", "channel", channel.Name, "article", article.ID)
+	}
+
+	return nil
+}
+
+// resolveTimedOutReviews approves or expires posts waiting for review longer than the moderation timeout
+func (n *Notifier) resolveTimedOutReviews(ctx context.Context, channel model.Channel) error {
+	if channel.ModerationTimeout <= 0 {
+		return nil
+	}
+
+	status := model.DeliveryStatusExpired
+	if channel.ModerationTimeoutAction == model.ModerationApprove {
+		status = model.DeliveryStatusApproved
+	}
+
+	resolved, err := n.deliveries.ResolveReviews(ctx, channel.ID, time.Now().UTC().Add(-channel.ModerationTimeout), status)
+	if err != nil {
+		return err
+	}
+
+	if resolved > 0 {
+		logger.Log.Infow("notifier: reviews timed out", "channel", channel.Name, "count", resolved, "status", status)
+	}
+
+	return nil
+}
+
+// PostNow sends a post waiting for review to the channel right away, regardless of the channel schedule.
+// false is returned if the post is not waiting for review anymore
+func (n *Notifier) PostNow(ctx context.Context, articleID int64, channelID int64) (bool, error) {
+	delivery, err := n.deliveries.Delivery(ctx, articleID, channelID)
+	if err != nil {
+		return false, err
+	}
+
+	// the post is approved first so it can be leased, the lease keeps the channel loop from posting it twice
+	ok, err := n.deliveries.Review(ctx, articleID, channelID, model.DeliveryStatusApproved)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	channel, err := n.channels.ChannelById(ctx, channelID)
+	if err != nil {
+		return false, err
+	}
+
+	article, err := n.articles.ArticleById(ctx, articleID)
+	if err != nil {
+		return false, err
+	}
+
+	article.ReviewedSummary = delivery.Summary
+
+	if err := n.deliver(ctx, *channel, *article); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -2,69 +2,158 @@ package notifier
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	readability "github.com/go-shiori/go-readability"
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
 	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/schedule"
 	"github.com/lostmyescape/news-tg-bot/logger"
-	"io"
-	"net/http"
-	"regexp"
-	"strings"
 	"time"
 )
 
 type ArticleProvider interface {
-	AllNotPosted(ctx context.Context) ([]model.Article, error)
-	MarkAsPosted(ctx context.Context, article model.Article) error
+	Candidates(ctx context.Context, query model.QueueQuery) ([]model.Article, error)
+	Approved(ctx context.Context, channelID int64) ([]model.Article, error)
+	ArticleById(ctx context.Context, id int64) (*model.Article, error)
+	DueForRetry(ctx context.Context, channelID int64) ([]model.Article, error)
+	PostedCountBySource(ctx context.Context, channelID int64, since time.Time) (map[int64]int, error)
 }
 
-type Summarizer interface {
-	Summarize(ctx context.Context, text string) (string, error)
+// ArticleEvents tells about newly enriched articles, so channels don't wait for the next poll
+type ArticleEvents interface {
+	Notified(ctx context.Context) <-chan struct{}
 }
 
+type DeliveryLedger interface {
+	Lease(ctx context.Context, articleID int64, channelID int64, leasedUntil time.Time) (int, bool, error)
+	MarkSent(ctx context.Context, articles []model.Article, channelID int64, messageID int) error
+	MarkFailed(ctx context.Context, articleID int64, channelID int64, lastError string, nextAttemptAt time.Time) error
+	MarkDead(ctx context.Context, articleID int64, channelID int64, lastError string) error
+	Expire(ctx context.Context, channelID int64, window time.Duration) (map[int64]int, error)
+	ClaimForReview(ctx context.Context, articleID int64, channelID int64, summary string) (bool, error)
+	SetReviewMessage(ctx context.Context, articleID int64, channelID int64, reviewMessageID int) error
+	ReleaseReview(ctx context.Context, articleID int64, channelID int64) error
+	Review(ctx context.Context, articleID int64, channelID int64, status string) (bool, error)
+	ResolveReviews(ctx context.Context, channelID int64, before time.Time, status string) (int64, error)
+	Delivery(ctx context.Context, articleID int64, channelID int64) (*model.Delivery, error)
+}
+
+type ChannelProvider interface {
+	Channels(ctx context.Context) ([]model.Channel, error)
+	ChannelById(ctx context.Context, id int64) (*model.Channel, error)
+}
+
+// queueCandidates is the number of articles of the channel queue a strategy selects from
+const queueCandidates = 50
+
+// channelsRefreshInterval is how often the notifier reloads channels to pick up added, changed or deleted ones
+const channelsRefreshInterval = time.Minute
+
 type Notifier struct {
-	articles         ArticleProvider
-	summarizer       Summarizer
-	bot              *tgbotapi.BotAPI
-	sendInterval     time.Duration
-	lookupTimeWindow time.Duration
-	channelID        int64
+	articles        ArticleProvider
+	events          ArticleEvents
+	deliveries      DeliveryLedger
+	channels        ChannelProvider
+	posts           ScheduledPostQueue
+	bot             botkit.API
+	publishers      map[string]Publisher
+	freshnessWindow time.Duration
 }
 
 func New(
 	articleProvider ArticleProvider,
-	summarizer Summarizer,
-	bot *tgbotapi.BotAPI,
-	sendInterval time.Duration,
-	lookupTimeWindow time.Duration,
-	channelID int64,
+	articleEvents ArticleEvents,
+	deliveryLedger DeliveryLedger,
+	channelProvider ChannelProvider,
+	scheduledPosts ScheduledPostQueue,
+	bot botkit.API,
+	freshnessWindow time.Duration,
 ) *Notifier {
 	return &Notifier{
-		articles:         articleProvider,
-		summarizer:       summarizer,
-		bot:              bot,
-		sendInterval:     sendInterval,
-		lookupTimeWindow: lookupTimeWindow,
-		channelID:        channelID,
+		articles:        articleProvider,
+		events:          articleEvents,
+		deliveries:      deliveryLedger,
+		channels:        channelProvider,
+		posts:           scheduledPosts,
+		bot:             bot,
+		publishers:      defaultPublishers(bot),
+		freshnessWindow: freshnessWindow,
 	}
 }
 
+type runningChannel struct {
+	channel model.Channel
+	cancel  context.CancelFunc
+	// wake is signalled when new articles arrive
+	wake chan struct{}
+}
+
+// Start runs a posting loop for every stream channel, each channel has its own interval and queue.
+// Channels are reloaded periodically, a channel whose loop failed is restarted on the next reload.
+// New articles wake the channel loops right away, the posting interval remains the fallback poll.
+// Admin composed posts are published by a separate loop at their time
 func (n *Notifier) Start(ctx context.Context) error {
 	logger.Log.Info("notifier started")
-	ticker := time.NewTicker(n.sendInterval)
+
+	go n.runScheduledPosts(ctx)
+
+	return n.superviseChannels(ctx, false, n.events.Notified(ctx))
+}
+
+// StartDigests posts periodic digests to digest channels. Digests are not leased like single posts,
+// so it must run on a single replica or every replica would post each digest
+func (n *Notifier) StartDigests(ctx context.Context) error {
+	logger.Log.Info("notifier digests started")
+
+	// digests go out at their times, new articles don't wake them
+	return n.superviseChannels(ctx, true, nil)
+}
+
+// superviseChannels keeps a loop running for every digest or every stream channel
+// and passes wake-ups about new articles to them
+func (n *Notifier) superviseChannels(ctx context.Context, digest bool, enriched <-chan struct{}) error {
+	ticker := time.NewTicker(channelsRefreshInterval)
 	defer ticker.Stop()
 
-	if err := n.SelectAndSendArticle(ctx); err != nil {
-		return err
+	var (
+		running = make(map[int64]*runningChannel)
+		stopped = make(chan *runningChannel)
+	)
+
+	defer func() {
+		for _, rc := range running {
+			rc.cancel()
+		}
+	}()
+
+	// channels that failed to load are loaded on the next refresh
+	if err := n.syncChannels(ctx, digest, running, stopped); err != nil {
+		logger.Log.Errorw("notifier: failed to load channels", "err", err)
 	}
 
 	for {
 		select {
 		case <-ticker.C:
-			if err := n.SelectAndSendArticle(ctx); err != nil {
-				return err
+			if err := n.syncChannels(ctx, digest, running, stopped); err != nil {
+				logger.Log.Errorw("notifier: failed to load channels", "err", err)
+			}
+		case _, ok := <-enriched:
+			// the events stop when the listener is closed, channel loops fall back to their intervals
+			if !ok {
+				enriched = nil
+				continue
+			}
+
+			for _, rc := range running {
+				select {
+				case rc.wake <- struct{}{}:
+				default:
+				}
+			}
+		case rc := <-stopped:
+			// the channel may have been restarted meanwhile, only the current loop is forgotten
+			if running[rc.channel.ID] == rc {
+				delete(running, rc.channel.ID)
 			}
 		case <-ctx.Done():
 			return ctx.Err()
@@ -72,82 +161,264 @@ func (n *Notifier) Start(ctx context.Context) error {
 	}
 }
 
-// SelectAndSendArticle selects an article that has not yet been published
-func (n *Notifier) SelectAndSendArticle(ctx context.Context) error {
-	topOneArticles, err := n.articles.AllNotPosted(ctx)
+// syncChannels starts loops for new digest or stream channels, restarts loops of changed channels
+// and stops loops of deleted ones or of ones that changed the mode
+func (n *Notifier) syncChannels(
+	ctx context.Context,
+	digest bool,
+	running map[int64]*runningChannel,
+	stopped chan<- *runningChannel,
+) error {
+	channels, err := n.channels.Channels(ctx)
 	if err != nil {
 		return err
 	}
 
-	logger.Log.Infof("notifier: found %d articles to send", len(topOneArticles))
+	actual := make(map[int64]struct{}, len(channels))
 
-	if len(topOneArticles) == 0 {
-		return nil
+	for _, channel := range channels {
+		if (channel.Mode == model.ChannelModeDigest) != digest {
+			continue
+		}
+
+		actual[channel.ID] = struct{}{}
+
+		if rc, ok := running[channel.ID]; ok {
+			if rc.channel == channel {
+				continue
+			}
+			rc.cancel()
+		}
+
+		channelCtx, cancel := context.WithCancel(ctx)
+		rc := &runningChannel{channel: channel, cancel: cancel, wake: make(chan struct{}, 1)}
+		running[channel.ID] = rc
+
+		go func() {
+			var err error
+
+			if digest {
+				err = n.runDigest(channelCtx, rc.channel)
+			} else {
+				err = n.runChannel(channelCtx, rc.channel, rc.wake)
+			}
+
+			if err != nil && !errors.Is(err, context.Canceled) {
+				logger.Log.Errorw("notifier: channel loop stopped", "channel", rc.channel.Name, "err", err)
+			}
+
+			select {
+			case stopped <- rc:
+			case <-ctx.Done():
+			}
+		}()
 	}
 
-	article := topOneArticles[0]
+	for id, rc := range running {
+		if _, ok := actual[id]; !ok {
+			rc.cancel()
+			delete(running, id)
+		}
+	}
+
+	return nil
+}
+
+// runChannel sends articles to the channel every posting interval while the channel schedule is open,
+// articles that arrive during quiet hours wait in the queue and are released one per interval once it opens.
+// A wake-up about new articles sends one right away unless the channel posted less than an interval ago.
+// A failed attempt is logged and the loop goes on, so a single article or outage doesn't stop the channel
+func (n *Notifier) runChannel(ctx context.Context, channel model.Channel, wake <-chan struct{}) error {
+	if channel.PostingInterval <= 0 {
+		return fmt.Errorf("invalid posting interval %s", channel.PostingInterval)
+	}
 
-	summary, err := n.extractSummary(ctx, article)
+	strategy, err := NewStrategy(channel.Strategy)
 	if err != nil {
 		return err
 	}
 
-	if err := n.sendArticle(article, summary); err != nil {
+	sched, err := schedule.Parse(channel.Schedule, channel.Timezone)
+	if err != nil {
 		return err
 	}
 
-	return n.articles.MarkAsPosted(ctx, article)
-}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	var lastSent time.Time
+
+	for {
+		woken := false
 
-// extractSummary считывает html из новости
-func (n *Notifier) extractSummary(ctx context.Context, article model.Article) (string, error) {
-	var r io.Reader
+		select {
+		case <-timer.C:
+		case <-wake:
+			woken = true
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		now := time.Now()
+
+		interval, open := sched.At(now)
+		if !open {
+			next := sched.NextOpen(now)
+			logger.Log.Infof("notifier: channel %s is in quiet hours until %s", channel.Name, next.Format(time.RFC3339))
+			timer.Reset(next.Sub(now))
+			continue
+		}
 
-	if article.Summary != "" {
-		r = strings.NewReader(article.Summary)
-	} else {
-		resp, err := http.Get(article.Link)
+		if interval == 0 {
+			interval = channel.PostingInterval
+		}
+
+		// the article waits for the timer, a wake-up doesn't shorten the spacing between posts
+		if woken && now.Sub(lastSent) < interval {
+			continue
+		}
+
+		sent, err := n.SelectAndSendArticle(ctx, channel, strategy)
 		if err != nil {
-			return "", err
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			logger.Log.Errorw("notifier: failed to send article", "channel", channel.Name, "err", err)
+		}
+
+		if sent {
+			lastSent = now
 		}
-		defer resp.Body.Close()
 
-		r = resp.Body
+		timer.Reset(interval)
+	}
+}
+
+// SelectAndSendArticle sends an article whose delivery to the channel is due for a retry,
+// otherwise selects an article that has not yet been published to the channel using the strategy.
+// Articles for a moderated channel go to the moderation chat first and only approved ones are sent.
+// Reports whether an article was selected
+func (n *Notifier) SelectAndSendArticle(ctx context.Context, channel model.Channel, strategy Strategy) (bool, error) {
+	if channel.ModerationChatID != 0 {
+		if err := n.submitForReview(ctx, channel); err != nil {
+			return false, err
+		}
+	}
+
+	article, ok, err := n.selectArticle(ctx, channel, strategy)
+	if err != nil || !ok {
+		return false, err
 	}
 
-	doc, err := readability.FromReader(r, nil)
+	return true, n.deliver(ctx, channel, article)
+}
+
+// deliver sends the article to the channel and notes the result, reviewed articles keep the summary
+// the moderators saw. The article is skipped if another instance is sending it or has already sent it
+func (n *Notifier) deliver(ctx context.Context, channel model.Channel, article model.Article) error {
+	summary := article.ReviewedSummary
+	if summary == "" {
+		summary = article.GeneratedSummary
+	}
+
+	publisher, err := n.publisherFor(channel)
+	if err != nil {
+		return err
+	}
+
+	attempt, ok, err := n.deliveries.Lease(ctx, article.ID, channel.ID, time.Now().UTC().Add(sendingLease))
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	summary, err := n.summarizer.Summarize(ctx, cleanText(doc.TextContent))
+	if !ok {
+		logger.Log.Infow("notifier: article is leased by another instance", "channel", channel.Name, "article", article.ID)
+		return nil
+	}
 
+	messageID, err := publisher.Publish(ctx, channel, article, summary)
 	if err != nil {
-		return "", err
+		return n.recordFailure(ctx, channel, article, attempt, err)
 	}
 
-	return "\n\n" + summary, nil
+	return n.deliveries.MarkSent(ctx, []model.Article{article}, channel.ID, messageID)
 }
 
-// sendArticle отправляет статью
-func (n *Notifier) sendArticle(article model.Article, summary string) error {
-	const msgFormat = "*%s*%s\n\n%s"
+func (n *Notifier) selectArticle(ctx context.Context, channel model.Channel, strategy Strategy) (model.Article, bool, error) {
+	if err := n.expireStale(ctx, channel); err != nil {
+		return model.Article{}, false, err
+	}
 
-	msg := tgbotapi.NewMessage(n.channelID, fmt.Sprintf(msgFormat, markup.EscapeForMarkdown(article.Title), markup.EscapeForMarkdown(summary), markup.EscapeForMarkdown(article.Link)))
-	msg.ParseMode = tgbotapi.ModeMarkdownV2
+	retries, err := n.articles.DueForRetry(ctx, channel.ID)
+	if err != nil {
+		return model.Article{}, false, err
+	}
 
-	_, err := n.bot.Send(msg)
+	if len(retries) > 0 {
+		logger.Log.Infof("notifier: found %d articles to retry in %s", len(retries), channel.Name)
+		return retries[0], true, nil
+	}
+
+	if channel.ModerationChatID != 0 {
+		approved, err := n.articles.Approved(ctx, channel.ID)
+		if err != nil {
+			return model.Article{}, false, err
+		}
+
+		if len(approved) == 0 {
+			return model.Article{}, false, nil
+		}
+
+		logger.Log.Infof("notifier: found %d approved articles to send to %s", len(approved), channel.Name)
 
-	logger.Log.Infof("notifier: sending message to channel %d", n.channelID)
+		return approved[0], true, nil
+	}
+
+	query := n.queueQuery(channel, strategy.Queue(), queueCandidates)
+
+	// capped sources are skipped by the query, so their articles don't take up the candidates
+	if channel.MaxPostsPerSourcePerHour > 0 {
+		postedLastHour, err := n.articles.PostedCountBySource(ctx, channel.ID, time.Now().UTC().Add(-time.Hour))
+		if err != nil {
+			return model.Article{}, false, err
+		}
+
+		query.ExcludeSources = cappedSources(postedLastHour, channel.MaxPostsPerSourcePerHour)
+	}
+
+	articles, err := n.articles.Candidates(ctx, query)
 	if err != nil {
-		logger.Log.Errorw("telegram send error", "err", err)
+		return model.Article{}, false, err
 	}
 
-	return nil
+	logger.Log.Infof("notifier: found %d candidates to send to %s", len(articles), channel.Name)
+
+	article, ok := strategy.Select(articles)
+
+	return article, ok, nil
 }
 
-var redundantNewLines = regexp.MustCompile(`\n{3,}`)
+// queueQuery selects up to limit articles of the channel queue in the order
+func (n *Notifier) queueQuery(channel model.Channel, order string, limit int) model.QueueQuery {
+	return model.QueueQuery{
+		ChannelID:       channel.ID,
+		Order:           order,
+		Limit:           limit,
+		FreshnessWindow: n.freshnessWindow,
+	}
+}
 
-func cleanText(text string) string {
-	return redundantNewLines.ReplaceAllString(text, "\n")
+// expireStale moves articles older than the freshness window out of the channel queue
+func (n *Notifier) expireStale(ctx context.Context, channel model.Channel) error {
+	expired, err := n.deliveries.Expire(ctx, channel.ID, n.freshnessWindow)
+	if err != nil {
+		return err
+	}
+
+	for sourceID, count := range expired {
+		logger.Log.Infow("notifier: expired stale articles", "channel", channel.Name, "source_id", sourceID, "count", count)
+	}
+
+	return nil
 }
@@ -0,0 +1,86 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+	"github.com/lostmyescape/news-tg-bot/logger"
+	"net/http"
+	"time"
+)
+
+// Publisher posts articles to a destination of some kind, each publisher renders posts its own way
+type Publisher interface {
+	// Publish posts the article with the summary to the channel and returns the id of the message,
+	// zero if the destination has no message ids
+	Publish(ctx context.Context, channel model.Channel, article model.Article, summary string) (int, error)
+}
+
+// webhookTimeout limits a single webhook request
+const webhookTimeout = 10 * time.Second
+
+// defaultPublishers returns a publisher of every kind, telegram posts go through the bot
+func defaultPublishers(bot botkit.API) map[string]Publisher {
+	client := &http.Client{Timeout: webhookTimeout}
+
+	return map[string]Publisher{
+		model.PublisherTelegram: NewTelegramPublisher(bot),
+		model.PublisherDiscord:  NewDiscordPublisher(client),
+		model.PublisherSlack:    NewSlackPublisher(client),
+		model.PublisherWebhook:  NewWebhookPublisher(client),
+	}
+}
+
+// publisherFor returns the publisher of the channel, channels without a publisher are telegram ones
+func (n *Notifier) publisherFor(channel model.Channel) (Publisher, error) {
+	kind := channel.Publisher
+	if kind == "" {
+		kind = model.PublisherTelegram
+	}
+
+	publisher, ok := n.publishers[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown publisher %q", kind)
+	}
+
+	return publisher, nil
+}
+
+// TelegramPublisher posts articles rendered with the template of the source or the channel
+type TelegramPublisher struct {
+	bot botkit.API
+}
+
+func NewTelegramPublisher(bot botkit.API) *TelegramPublisher {
+	return &TelegramPublisher{bot: bot}
+}
+
+func (p *TelegramPublisher) Publish(_ context.Context, channel model.Channel, article model.Article, summary string) (int, error) {
+	tmpl, err := render.TemplateFor(channel, article)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errRenderFailed, err)
+	}
+
+	message, err := tmpl.Render(render.NewPost(article, summary))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errRenderFailed, err)
+	}
+
+	msg := message.Truncate(markup.MessageLimit).Config(channel.ChatID)
+
+	if keyboard := render.Keyboard(article, channel.FeedbackButtons, model.Votes{}); keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+
+	logger.Log.Infof("notifier: sending message to channel %d", channel.ChatID)
+
+	sent, err := p.bot.Send(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	return sent.MessageID, nil
+}
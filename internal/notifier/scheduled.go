@@ -0,0 +1,94 @@
+package notifier
+
+import (
+	"context"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/lostmyescape/news-tg-bot/internal/render"
+	"github.com/lostmyescape/news-tg-bot/logger"
+	"time"
+)
+
+type ScheduledPostQueue interface {
+	Due(ctx context.Context) ([]model.ScheduledPost, error)
+	Lease(ctx context.Context, id int64, leasedUntil time.Time) (int, bool, error)
+	MarkSent(ctx context.Context, id int64, messageID int) error
+	MarkFailed(ctx context.Context, id int64, lastError string, nextAttemptAt time.Time) error
+	MarkDead(ctx context.Context, id int64, lastError string) error
+}
+
+// scheduledPostsInterval is how often the notifier looks for admin composed posts whose time has come
+const scheduledPostsInterval = 30 * time.Second
+
+// runScheduledPosts publishes admin composed posts at their time, regardless of channel schedules.
+// Failed posts are retried with the same backoff as articles
+func (n *Notifier) runScheduledPosts(ctx context.Context) {
+	ticker := time.NewTicker(scheduledPostsInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := n.SendScheduledPosts(ctx); err != nil {
+			logger.Log.Errorw("notifier: failed to send scheduled posts", "err", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// SendScheduledPosts publishes every post that is due, posts leased by other instances are skipped
+func (n *Notifier) SendScheduledPosts(ctx context.Context) error {
+	posts, err := n.posts.Due(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		attempt, ok, err := n.posts.Lease(ctx, post.ID, time.Now().UTC().Add(sendingLease))
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			continue
+		}
+
+		logger.Log.Infof("notifier: sending scheduled post %d to channel %d", post.ID, post.ChatID)
+
+		sent, err := n.bot.Send(render.ManualPost(post, post.ChatID, nil))
+		if err != nil {
+			if err := n.recordPostFailure(ctx, post, attempt, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := n.posts.MarkSent(ctx, post.ID, sent.MessageID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *Notifier) recordPostFailure(ctx context.Context, post model.ScheduledPost, attempt int, sendErr error) error {
+	if isPermanentSendError(sendErr) || attempt >= maxDeliveryAttempts {
+		logger.Log.Errorw(
+			"notifier: scheduled post failed permanently",
+			"channel", post.ChannelName, "post", post.ID, "attempt", attempt, "err", sendErr,
+		)
+
+		return n.posts.MarkDead(ctx, post.ID, sendErr.Error())
+	}
+
+	nextAttemptAt := time.Now().UTC().Add(deliveryBackoff(attempt))
+
+	logger.Log.Warnw(
+		"notifier: scheduled post failed, will retry",
+		"channel", post.ChannelName, "post", post.ID, "attempt", attempt, "retry_at", nextAttemptAt, "err", sendErr,
+	)
+
+	return n.posts.MarkFailed(ctx, post.ID, sendErr.Error(), nextAttemptAt)
+}
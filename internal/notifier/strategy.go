@@ -0,0 +1,135 @@
+package notifier
+
+import (
+	"fmt"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/samber/lo"
+	"math/rand"
+	"sort"
+)
+
+const (
+	StrategyNewest     = "newest"
+	StrategyOldest     = "oldest"
+	StrategyRoundRobin = "round_robin"
+	StrategyWeighted   = "weighted"
+)
+
+// Strategy picks the next article to post from the candidates of the channel queue
+type Strategy interface {
+	// Queue is the order the candidates are read in, one of model.Queue* constants
+	Queue() string
+	Select(articles []model.Article) (model.Article, bool)
+}
+
+// NewStrategy creates a strategy by name, strategies keep their own state
+// so every channel needs its own instance
+func NewStrategy(name string) (Strategy, error) {
+	switch name {
+	case StrategyNewest, "":
+		return newestFirst{}, nil
+	case StrategyOldest:
+		return oldestFirst{}, nil
+	case StrategyRoundRobin:
+		return &roundRobin{}, nil
+	case StrategyWeighted:
+		return weighted{}, nil
+	default:
+		return nil, fmt.Errorf("unknown strategy %q", name)
+	}
+}
+
+// newestFirst takes the most recently published article
+type newestFirst struct{}
+
+func (newestFirst) Queue() string { return model.QueueNewest }
+
+func (newestFirst) Select(articles []model.Article) (model.Article, bool) {
+	if len(articles) == 0 {
+		return model.Article{}, false
+	}
+
+	return lo.MaxBy(articles, func(a, b model.Article) bool { return a.PublishedAt.After(b.PublishedAt) }), true
+}
+
+// oldestFirst takes the earliest published article, so the backlog is drained in order
+type oldestFirst struct{}
+
+func (oldestFirst) Queue() string { return model.QueueOldest }
+
+func (oldestFirst) Select(articles []model.Article) (model.Article, bool) {
+	if len(articles) == 0 {
+		return model.Article{}, false
+	}
+
+	return lo.MinBy(articles, func(a, b model.Article) bool { return a.PublishedAt.Before(b.PublishedAt) }), true
+}
+
+// roundRobin takes the newest article of the next source after the one posted last
+type roundRobin struct {
+	lastSourceID int64
+}
+
+func (*roundRobin) Queue() string { return model.QueueNewestPerSource }
+
+func (r *roundRobin) Select(articles []model.Article) (model.Article, bool) {
+	if len(articles) == 0 {
+		return model.Article{}, false
+	}
+
+	bySource := lo.GroupBy(articles, func(a model.Article) int64 { return a.SourceID })
+	sourceIDs := lo.Keys(bySource)
+	sort.Slice(sourceIDs, func(i, j int) bool { return sourceIDs[i] < sourceIDs[j] })
+
+	next, found := lo.Find(sourceIDs, func(id int64) bool { return id > r.lastSourceID })
+	if !found {
+		next = sourceIDs[0]
+	}
+
+	r.lastSourceID = next
+
+	return newestFirst{}.Select(bySource[next])
+}
+
+// weighted picks a source at random proportionally to its priority and takes its newest article,
+// sources with zero priority are never picked
+type weighted struct{}
+
+func (weighted) Queue() string { return model.QueueNewestPerSource }
+
+func (weighted) Select(articles []model.Article) (model.Article, bool) {
+	bySource := lo.GroupBy(articles, func(a model.Article) int64 { return a.SourceID })
+
+	var (
+		sourceIDs = lo.Keys(bySource)
+		total     int
+	)
+
+	sort.Slice(sourceIDs, func(i, j int) bool { return sourceIDs[i] < sourceIDs[j] })
+
+	for _, id := range sourceIDs {
+		total += bySource[id][0].SourcePriority
+	}
+
+	if total <= 0 {
+		return model.Article{}, false
+	}
+
+	pick := rand.Intn(total)
+
+	for _, id := range sourceIDs {
+		pick -= bySource[id][0].SourcePriority
+		if pick < 0 {
+			return newestFirst{}.Select(bySource[id])
+		}
+	}
+
+	return model.Article{}, false
+}
+
+// cappedSources returns sources that already reached the per hour cap
+func cappedSources(postedLastHour map[int64]int, limit int) []int64 {
+	return lo.FilterMap(lo.Entries(postedLastHour), func(e lo.Entry[int64, int], _ int) (int64, bool) {
+		return e.Key, e.Value >= limit
+	})
+}
@@ -0,0 +1,185 @@
+package notifier
+
+import (
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"testing"
+	"time"
+)
+
+func testArticle(id int64, sourceID int64, publishedAt string, priority int) model.Article {
+	published, err := time.Parse(time.DateTime, publishedAt)
+	if err != nil {
+		panic(err)
+	}
+
+	return model.Article{ID: id, SourceID: sourceID, PublishedAt: published, SourcePriority: priority}
+}
+
+func TestNewStrategy(t *testing.T) {
+	tests := []struct {
+		name    string
+		queue   string
+		wantErr bool
+	}{
+		{name: "", queue: model.QueueNewest},
+		{name: StrategyNewest, queue: model.QueueNewest},
+		{name: StrategyOldest, queue: model.QueueOldest},
+		{name: StrategyRoundRobin, queue: model.QueueNewestPerSource},
+		{name: StrategyWeighted, queue: model.QueueNewestPerSource},
+		{name: "random", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := NewStrategy(tt.name)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("NewStrategy(%q) = nil error, want error", tt.name)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("NewStrategy(%q) error = %v", tt.name, err)
+			}
+
+			if got := strategy.Queue(); got != tt.queue {
+				t.Errorf("Queue() = %q, want %q", got, tt.queue)
+			}
+		})
+	}
+}
+
+func TestStrategySelect(t *testing.T) {
+	articles := []model.Article{
+		testArticle(1, 1, "2025-05-01 10:00:00", 1),
+		testArticle(2, 2, "2025-05-01 12:00:00", 1),
+		testArticle(3, 1, "2025-05-01 08:00:00", 1),
+	}
+
+	tests := []struct {
+		strategy string
+		articles []model.Article
+		wantID   int64
+		wantOK   bool
+	}{
+		{strategy: StrategyNewest, articles: articles, wantID: 2, wantOK: true},
+		{strategy: StrategyOldest, articles: articles, wantID: 3, wantOK: true},
+		{strategy: StrategyRoundRobin, articles: articles, wantID: 1, wantOK: true},
+		{strategy: StrategyNewest, articles: nil},
+		{strategy: StrategyOldest, articles: nil},
+		{strategy: StrategyRoundRobin, articles: nil},
+		{strategy: StrategyWeighted, articles: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			strategy, err := NewStrategy(tt.strategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, ok := strategy.Select(tt.articles)
+			if ok != tt.wantOK || got.ID != tt.wantID {
+				t.Errorf("Select() = %d, %t, want %d, %t", got.ID, ok, tt.wantID, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRoundRobinCyclesSources(t *testing.T) {
+	articles := []model.Article{
+		testArticle(1, 3, "2025-05-01 10:00:00", 1),
+		testArticle(2, 1, "2025-05-01 11:00:00", 1),
+		testArticle(3, 2, "2025-05-01 09:00:00", 1),
+		testArticle(4, 1, "2025-05-01 12:00:00", 1),
+	}
+
+	strategy := &roundRobin{}
+
+	// sources are taken in id order, the newest article of each, and the order wraps around
+	for i, want := range []int64{4, 3, 1, 4} {
+		got, ok := strategy.Select(articles)
+		if !ok || got.ID != want {
+			t.Fatalf("Select() #%d = %d, %t, want %d, true", i, got.ID, ok, want)
+		}
+	}
+}
+
+func TestWeightedSkipsZeroPriority(t *testing.T) {
+	tests := []struct {
+		name     string
+		articles []model.Article
+		wantIDs  map[int64]bool
+		wantOK   bool
+	}{
+		{
+			name: "only positive priority",
+			articles: []model.Article{
+				testArticle(1, 1, "2025-05-01 10:00:00", 0),
+				testArticle(2, 2, "2025-05-01 11:00:00", 3),
+				testArticle(3, 2, "2025-05-01 12:00:00", 3),
+			},
+			wantIDs: map[int64]bool{3: true},
+			wantOK:  true,
+		},
+		{
+			name: "every source picked",
+			articles: []model.Article{
+				testArticle(1, 1, "2025-05-01 10:00:00", 1),
+				testArticle(2, 2, "2025-05-01 11:00:00", 1),
+			},
+			wantIDs: map[int64]bool{1: true, 2: true},
+			wantOK:  true,
+		},
+		{
+			name: "all zero priority",
+			articles: []model.Article{
+				testArticle(1, 1, "2025-05-01 10:00:00", 0),
+				testArticle(2, 2, "2025-05-01 11:00:00", 0),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			picked := map[int64]bool{}
+
+			for range 200 {
+				got, ok := weighted{}.Select(tt.articles)
+				if ok != tt.wantOK {
+					t.Fatalf("Select() ok = %t, want %t", ok, tt.wantOK)
+				}
+
+				if !ok {
+					continue
+				}
+
+				if !tt.wantIDs[got.ID] {
+					t.Fatalf("Select() = %d, want one of %v", got.ID, tt.wantIDs)
+				}
+
+				picked[got.ID] = true
+			}
+
+			if tt.wantOK && len(picked) != len(tt.wantIDs) {
+				t.Errorf("picked %v over 200 runs, want all of %v", picked, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestCappedSources(t *testing.T) {
+	got := cappedSources(map[int64]int{1: 2, 2: 5, 3: 4}, 4)
+
+	want := map[int64]bool{2: true, 3: true}
+	if len(got) != len(want) {
+		t.Fatalf("cappedSources() = %v, want %v", got, want)
+	}
+
+	for _, id := range got {
+		if !want[id] {
+			t.Errorf("cappedSources() = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"io"
+	"net/http"
+	"time"
+)
+
+// webhookError is a non-2xx response of a webhook
+type webhookError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *webhookError) Error() string {
+	return fmt.Sprintf("webhook responded with %d: %s", e.StatusCode, e.Body)
+}
+
+// permanent reports whether the request won't succeed on retry, client errors but rate limiting are permanent
+func (e *webhookError) permanent() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500 && e.StatusCode != http.StatusTooManyRequests
+}
+
+// postJSON posts the payload to the url, a non-2xx response is a *webhookError
+func postJSON(ctx context.Context, client *http.Client, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// responses of webhooks are short, a limit keeps a misbehaving endpoint from flooding the logs
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &webhookError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	return nil
+}
+
+// Discord limits of an embed in characters
+const (
+	discordTitleLimit       = 256
+	discordDescriptionLimit = 4096
+)
+
+// DiscordPublisher posts articles as embeds through a Discord webhook
+type DiscordPublisher struct {
+	client *http.Client
+}
+
+func NewDiscordPublisher(client *http.Client) *DiscordPublisher {
+	return &DiscordPublisher{client: client}
+}
+
+type discordPayload struct {
+	Embeds []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	URL         string         `json:"url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+	Footer      *discordFooter `json:"footer,omitempty"`
+}
+
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+func (p *DiscordPublisher) Publish(ctx context.Context, channel model.Channel, article model.Article, summary string) (int, error) {
+	description := markup.NewBuilder().Append(markup.ParseCommonMark(summary))
+	if article.CommentsURL != "" {
+		description.Text("\n\n").Link("Обсуждение", article.CommentsURL)
+	}
+
+	embed := discordEmbed{
+		Title: truncateRunes(article.Title, discordTitleLimit),
+		URL:   article.Link,
+		// escaping makes the text longer, half of the limit leaves room for it
+		Description: description.Message().Truncate(discordDescriptionLimit / 2).DiscordMarkdown(),
+	}
+
+	if !article.PublishedAt.IsZero() {
+		embed.Timestamp = article.PublishedAt.UTC().Format(time.RFC3339)
+	}
+
+	if article.SourceName != "" {
+		embed.Footer = &discordFooter{Text: article.SourceName}
+	}
+
+	if err := postJSON(ctx, p.client, channel.WebhookURL, discordPayload{Embeds: []discordEmbed{embed}}); err != nil {
+		return 0, err
+	}
+
+	return 0, nil
+}
+
+// slackTextLimit is the limit of a text of a Slack section block in characters
+const slackTextLimit = 3000
+
+// SlackPublisher posts articles through a Slack incoming webhook
+type SlackPublisher struct {
+	client *http.Client
+}
+
+func NewSlackPublisher(client *http.Client) *SlackPublisher {
+	return &SlackPublisher{client: client}
+}
+
+type slackPayload struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func (p *SlackPublisher) Publish(ctx context.Context, channel model.Channel, article model.Article, summary string) (int, error) {
+	b := markup.NewBuilder()
+	b.Wrap(tgbotapi.MessageEntity{Type: markup.EntityBold}, func(b *markup.Builder) { b.Link(article.Title, article.Link) })
+
+	if summary != "" {
+		b.Text("\n\n").Append(markup.ParseCommonMark(summary))
+	}
+
+	if article.CommentsURL != "" {
+		b.Text("\n\n").Link("Обсуждение", article.CommentsURL)
+	}
+
+	payload := slackPayload{
+		// the text is shown in notifications, blocks are shown in the channel
+		Text: article.Title,
+		Blocks: []slackBlock{{
+			Type: "section",
+			// escaping makes the text longer, half of the limit leaves room for it
+			Text: &slackText{Type: "mrkdwn", Text: b.Message().Truncate(slackTextLimit / 2).Mrkdwn()},
+		}},
+	}
+
+	if article.SourceName != "" {
+		payload.Blocks = append(payload.Blocks, slackBlock{
+			Type:     "context",
+			Elements: []slackText{{Type: "plain_text", Text: article.SourceName}},
+		})
+	}
+
+	if err := postJSON(ctx, p.client, channel.WebhookURL, payload); err != nil {
+		return 0, err
+	}
+
+	return 0, nil
+}
+
+// WebhookPublisher posts articles as plain JSON for custom integrations
+type WebhookPublisher struct {
+	client *http.Client
+}
+
+func NewWebhookPublisher(client *http.Client) *WebhookPublisher {
+	return &WebhookPublisher{client: client}
+}
+
+type webhookPayload struct {
+	Channel string         `json:"channel"`
+	Article webhookArticle `json:"article"`
+}
+
+type webhookArticle struct {
+	ID          int64     `json:"id"`
+	Title       string    `json:"title"`
+	Link        string    `json:"link"`
+	CommentsURL string    `json:"comments_url,omitempty"`
+	Summary     string    `json:"summary"`
+	SummaryText string    `json:"summary_text"`
+	Source      string    `json:"source"`
+	Tags        []string  `json:"tags"`
+	Language    string    `json:"language,omitempty"`
+	ImageURL    string    `json:"image_url,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, channel model.Channel, article model.Article, summary string) (int, error) {
+	payload := webhookPayload{
+		Channel: channel.Name,
+		Article: webhookArticle{
+			ID:          article.ID,
+			Title:       article.Title,
+			Link:        article.Link,
+			CommentsURL: article.CommentsURL,
+			Summary:     summary,
+			SummaryText: markup.ParseCommonMark(summary).Text,
+			Source:      article.SourceName,
+			Tags:        article.Tags,
+			Language:    article.Language,
+			ImageURL:    article.ImageURL,
+			Author:      article.Author,
+			PublishedAt: article.PublishedAt.UTC(),
+		},
+	}
+
+	if err := postJSON(ctx, p.client, channel.WebhookURL, payload); err != nil {
+		return 0, err
+	}
+
+	return 0, nil
+}
+
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+
+	return string(runes[:limit-1]) + "…"
+}
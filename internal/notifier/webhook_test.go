@@ -0,0 +1,199 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// webhookStub records the last request body and answers with the status
+func webhookStub(t *testing.T, status int) (*httptest.Server, *[]byte) {
+	t.Helper()
+
+	var body []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request %s with content type %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+
+		body, _ = io.ReadAll(r.Body)
+
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, strings.Repeat("x", 10000))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &body
+}
+
+func webhookTestArticle() model.Article {
+	return model.Article{
+		ID:          7,
+		Title:       "Go 1.25 is released",
+		Link:        "https://go.dev/blog/go1.25",
+		CommentsURL: "https://news.example.com/item?id=1",
+		SourceName:  "Go Blog",
+		Tags:        []string{"golang"},
+		PublishedAt: time.Date(2025, 8, 12, 10, 0, 0, 0, time.FixedZone("MSK", 3*60*60)),
+	}
+}
+
+func TestPublishersPayload(t *testing.T) {
+	const summary = "**Главное**: вышел Go"
+
+	tests := []struct {
+		name      string
+		publisher func(client *http.Client) Publisher
+		check     func(t *testing.T, body []byte)
+	}{
+		{
+			name:      model.PublisherDiscord,
+			publisher: func(client *http.Client) Publisher { return NewDiscordPublisher(client) },
+			check: func(t *testing.T, body []byte) {
+				var payload discordPayload
+				if err := json.Unmarshal(body, &payload); err != nil {
+					t.Fatal(err)
+				}
+
+				if len(payload.Embeds) != 1 {
+					t.Fatalf("payload has %d embeds, want 1", len(payload.Embeds))
+				}
+
+				embed := payload.Embeds[0]
+				if embed.Title != "Go 1.25 is released" || embed.URL != "https://go.dev/blog/go1.25" {
+					t.Errorf("embed title %q, url %q", embed.Title, embed.URL)
+				}
+
+				if !strings.HasPrefix(embed.Description, "**Главное**: вышел Go") ||
+					!strings.Contains(embed.Description, "[Обсуждение](<https://news.example.com/item?id=1>)") {
+					t.Errorf("embed description = %q", embed.Description)
+				}
+
+				if embed.Timestamp != "2025-08-12T07:00:00Z" || embed.Footer == nil || embed.Footer.Text != "Go Blog" {
+					t.Errorf("embed timestamp %q, footer %+v", embed.Timestamp, embed.Footer)
+				}
+			},
+		},
+		{
+			name:      model.PublisherSlack,
+			publisher: func(client *http.Client) Publisher { return NewSlackPublisher(client) },
+			check: func(t *testing.T, body []byte) {
+				var payload slackPayload
+				if err := json.Unmarshal(body, &payload); err != nil {
+					t.Fatal(err)
+				}
+
+				if payload.Text != "Go 1.25 is released" || len(payload.Blocks) != 2 {
+					t.Fatalf("payload text %q with %d blocks", payload.Text, len(payload.Blocks))
+				}
+
+				want := "<https://go.dev/blog/go1.25|*Go 1.25 is released*>\n\n*Главное*: вышел Go\n\n<https://news.example.com/item?id=1|Обсуждение>"
+				if got := payload.Blocks[0].Text; got == nil || got.Type != "mrkdwn" || got.Text != want {
+					t.Errorf("section text = %+v, want %q", got, want)
+				}
+
+				if got := payload.Blocks[1].Elements; len(got) != 1 || got[0].Text != "Go Blog" {
+					t.Errorf("context elements = %+v, want the source", got)
+				}
+			},
+		},
+		{
+			name:      model.PublisherWebhook,
+			publisher: func(client *http.Client) Publisher { return NewWebhookPublisher(client) },
+			check: func(t *testing.T, body []byte) {
+				var payload webhookPayload
+				if err := json.Unmarshal(body, &payload); err != nil {
+					t.Fatal(err)
+				}
+
+				article := payload.Article
+				if payload.Channel != "hooks" || article.ID != 7 || article.Source != "Go Blog" {
+					t.Errorf("payload channel %q, article %d from %q", payload.Channel, article.ID, article.Source)
+				}
+
+				if article.Summary != summary || article.SummaryText != "Главное: вышел Go" {
+					t.Errorf("summary %q, summary text %q", article.Summary, article.SummaryText)
+				}
+
+				if !article.PublishedAt.Equal(time.Date(2025, 8, 12, 7, 0, 0, 0, time.UTC)) || len(article.Tags) != 1 {
+					t.Errorf("published at %s, tags %q", article.PublishedAt, article.Tags)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, body := webhookStub(t, http.StatusNoContent)
+			channel := model.Channel{Name: "hooks", Publisher: tt.name, WebhookURL: server.URL}
+
+			id, err := tt.publisher(server.Client()).Publish(context.Background(), channel, webhookTestArticle(), summary)
+			if err != nil {
+				t.Fatalf("Publish() error = %v", err)
+			}
+
+			if id != 0 {
+				t.Errorf("Publish() id = %d, want 0", id)
+			}
+
+			tt.check(t, *body)
+		})
+	}
+}
+
+func TestPublishersErrors(t *testing.T) {
+	tests := []struct {
+		status        int
+		wantPermanent bool
+	}{
+		{status: http.StatusBadRequest, wantPermanent: true},
+		{status: http.StatusNotFound, wantPermanent: true},
+		{status: http.StatusTooManyRequests, wantPermanent: false},
+		{status: http.StatusInternalServerError, wantPermanent: false},
+		{status: http.StatusBadGateway, wantPermanent: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server, _ := webhookStub(t, tt.status)
+			channel := model.Channel{Publisher: model.PublisherWebhook, WebhookURL: server.URL}
+
+			_, err := NewWebhookPublisher(server.Client()).Publish(context.Background(), channel, webhookTestArticle(), "")
+			if err == nil {
+				t.Fatal("Publish() = nil error, want error")
+			}
+
+			if got := isPermanentSendError(err); got != tt.wantPermanent {
+				t.Errorf("isPermanentSendError(%v) = %t, want %t", err, got, tt.wantPermanent)
+			}
+
+			// the response body is cut so a misbehaving endpoint doesn't flood the logs
+			if len(err.Error()) > 4200 {
+				t.Errorf("error is %d long, want the body cut", len(err.Error()))
+			}
+		})
+	}
+}
+
+func TestPublisherUnreachableWebhookIsRetried(t *testing.T) {
+	server, _ := webhookStub(t, http.StatusOK)
+	server.Close()
+
+	channel := model.Channel{Publisher: model.PublisherSlack, WebhookURL: server.URL}
+
+	_, err := NewSlackPublisher(server.Client()).Publish(context.Background(), channel, webhookTestArticle(), "")
+	if err == nil {
+		t.Fatal("Publish() = nil error, want error")
+	}
+
+	if isPermanentSendError(err) {
+		t.Errorf("isPermanentSendError(%v) = true, want a retry", err)
+	}
+}
@@ -0,0 +1,61 @@
+package render
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"strings"
+)
+
+// VoteCallback prefixes callback data of feedback buttons, the data is "vote:<article id>:<up|down>"
+const VoteCallback = "vote"
+
+const (
+	VoteUp   = "up"
+	VoteDown = "down"
+)
+
+// Keyboard returns the inline keyboard of a post: a button to read the article, a button to its discussion
+// if the source has one and feedback buttons with vote counters if enabled. nil is returned if there are no buttons
+func Keyboard(article model.Article, feedback bool, votes model.Votes) *tgbotapi.InlineKeyboardMarkup {
+	var (
+		rows  [][]tgbotapi.InlineKeyboardButton
+		links []tgbotapi.InlineKeyboardButton
+	)
+
+	if isWebURL(article.Link) {
+		links = append(links, tgbotapi.NewInlineKeyboardButtonURL("Читать", article.Link))
+	}
+
+	if isWebURL(article.CommentsURL) {
+		links = append(links, tgbotapi.NewInlineKeyboardButtonURL("Обсуждение", article.CommentsURL))
+	}
+
+	if len(links) > 0 {
+		rows = append(rows, links)
+	}
+
+	if feedback {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("👍 %d", votes.Up), voteData(article.ID, VoteUp)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("👎 %d", votes.Down), voteData(article.ID, VoteDown)),
+		))
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	return &keyboard
+}
+
+func voteData(articleID int64, vote string) string {
+	return fmt.Sprintf("%s:%d:%s", VoteCallback, articleID, vote)
+}
+
+// telegram accepts only http and https urls in url buttons
+func isWebURL(url string) bool {
+	return strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://")
+}
@@ -0,0 +1,107 @@
+package render
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"time"
+)
+
+// PostCallback prefixes callback data of buttons of admin composed posts, the data is "post:<action>:<post id>"
+const PostCallback = "post"
+
+const (
+	PostPublishNow = "now"
+	PostSchedule   = "schedule"
+	PostCancel     = "cancel"
+)
+
+// ManualPost returns the config to send an admin composed post to the chat, a post with media carries
+// its text as the caption. The keyboard may be nil
+func ManualPost(post model.ScheduledPost, chatID int64, keyboard *tgbotapi.InlineKeyboardMarkup) tgbotapi.Chattable {
+	var (
+		file    = tgbotapi.FileID(post.MediaFileID)
+		caption = post.Content.Truncate(markup.CaptionLimit)
+	)
+
+	switch post.MediaType {
+	case model.MediaPhoto:
+		photo := tgbotapi.NewPhoto(chatID, file)
+		photo.Caption, photo.CaptionEntities = caption.Text, caption.Entities
+		if keyboard != nil {
+			photo.ReplyMarkup = keyboard
+		}
+		return photo
+	case model.MediaVideo:
+		video := tgbotapi.NewVideo(chatID, file)
+		video.Caption, video.CaptionEntities = caption.Text, caption.Entities
+		if keyboard != nil {
+			video.ReplyMarkup = keyboard
+		}
+		return video
+	case model.MediaAnimation:
+		animation := tgbotapi.NewAnimation(chatID, file)
+		animation.Caption, animation.CaptionEntities = caption.Text, caption.Entities
+		if keyboard != nil {
+			animation.ReplyMarkup = keyboard
+		}
+		return animation
+	case model.MediaDocument:
+		document := tgbotapi.NewDocument(chatID, file)
+		document.Caption, document.CaptionEntities = caption.Text, caption.Entities
+		if keyboard != nil {
+			document.ReplyMarkup = keyboard
+		}
+		return document
+	default:
+		msg := post.Content.Truncate(markup.MessageLimit).Config(chatID)
+		if keyboard != nil {
+			msg.ReplyMarkup = keyboard
+		}
+		return msg
+	}
+}
+
+// ManualPostKeyboard returns the buttons under the preview of a draft: publish now, publish at the chosen time
+// if there is one, and cancel
+func ManualPostKeyboard(post model.ScheduledPost) tgbotapi.InlineKeyboardMarkup {
+	row := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🚀 Опубликовать сейчас", postData(PostPublishNow, post.ID)),
+	)
+
+	if !post.PublishAt.IsZero() {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("🕒 В %s", FormatPublishAt(post)),
+			postData(PostSchedule, post.ID),
+		))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		row,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🗑 Отменить", postData(PostCancel, post.ID))),
+	)
+}
+
+// CancelPostButton returns a button cancelling the post
+func CancelPostButton(postID int64) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🗑 Отменить #%d", postID), postData(PostCancel, postID))
+}
+
+// FormatPublishAt formats the publishing time of the post in the time zone of its channel
+func FormatPublishAt(post model.ScheduledPost) string {
+	if post.PublishAt.IsZero() {
+		return "сразу"
+	}
+
+	loc, err := time.LoadLocation(post.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	return post.PublishAt.In(loc).Format("02.01.2006 15:04 MST")
+}
+
+func postData(action string, postID int64) string {
+	return fmt.Sprintf("%s:%s:%d", PostCallback, action, postID)
+}
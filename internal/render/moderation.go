@@ -0,0 +1,57 @@
+package render
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+)
+
+// ModerationCallback prefixes callback data of moderation buttons,
+// the data is "mod:<action>:<article id>:<channel id>"
+const ModerationCallback = "mod"
+
+const (
+	ModerationApprove = "approve"
+	ModerationReject  = "reject"
+	ModerationEdit    = "edit"
+	ModerationPostNow = "post"
+)
+
+// ReviewPost renders the post as it will look in the channel under a header naming the channel
+func ReviewPost(channel model.Channel, article model.Article, summary string) (markup.Message, error) {
+	tmpl, err := TemplateFor(channel, article)
+	if err != nil {
+		return markup.Message{}, err
+	}
+
+	post, err := tmpl.Render(NewPost(article, summary))
+	if err != nil {
+		return markup.Message{}, err
+	}
+
+	return markup.NewBuilder().
+		Italic(fmt.Sprintf("На модерацию в %s", channel.Name)).
+		Text("\n\n").
+		Append(post).
+		Message().
+		Truncate(markup.MessageLimit), nil
+}
+
+// ModerationKeyboard returns the buttons of a post waiting for review
+func ModerationKeyboard(articleID int64, channelID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Одобрить", moderationData(ModerationApprove, articleID, channelID)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отклонить", moderationData(ModerationReject, articleID, channelID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✏️ Изменить саммари", moderationData(ModerationEdit, articleID, channelID)),
+			tgbotapi.NewInlineKeyboardButtonData("🚀 Опубликовать сейчас", moderationData(ModerationPostNow, articleID, channelID)),
+		),
+	)
+}
+
+func moderationData(action string, articleID int64, channelID int64) string {
+	return fmt.Sprintf("%s:%s:%d:%d", ModerationCallback, action, articleID, channelID)
+}
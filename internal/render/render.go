@@ -0,0 +1,226 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"golang.org/x/net/html"
+	"html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+const (
+	FormatMarkdownV2 = "MarkdownV2"
+	FormatHTML       = "HTML"
+)
+
+// DefaultTemplates are used when neither the source nor the channel has a template
+var DefaultTemplates = map[string]string{
+	FormatMarkdownV2: "*{{ escape .Title }}*{{ if .Summary }}\n\n{{ markdown .Summary }}{{ end }}\n\n{{ escape .Link }}",
+	FormatHTML:       "<b>{{ escape .Title }}</b>{{ if .Summary }}\n\n{{ markdown .Summary }}{{ end }}\n\n{{ escape .Link }}",
+}
+
+// Post is the data available to templates
+type Post struct {
+	Title       string
+	Summary     string
+	Link        string
+	SourceName  string
+	Tags        []string
+	PublishedAt time.Time
+	Language    string
+	ImageURL    string
+	Author      string
+}
+
+// Template renders posts written in telegram MarkdownV2 or HTML into messages with entities
+type Template struct {
+	tmpl  *texttemplate.Template
+	parse func(string) (markup.Message, error)
+}
+
+// Parse parses a text/template in the format, an empty source means the default template of the format
+func Parse(src string, format string) (*Template, error) {
+	escape, ok := escapers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+
+	var (
+		parse    = parsers[format]
+		markdown = markdowns[format]
+	)
+
+	if strings.TrimSpace(src) == "" {
+		src = DefaultTemplates[format]
+	}
+
+	funcs := texttemplate.FuncMap{
+		"escape":   escape,
+		"markdown": markdown,
+		"truncate": truncate,
+		"reltime":  relativeTime,
+		"join":     strings.Join,
+		"hashtags": hashtags,
+		"date":     func(layout string, t time.Time) string { return t.Format(layout) },
+	}
+
+	tmpl, err := texttemplate.New("post").Funcs(funcs).Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Template{tmpl: tmpl, parse: parse}, nil
+}
+
+// Render renders the post and parses the markup of the result
+func (t *Template) Render(post Post) (markup.Message, error) {
+	var buf bytes.Buffer
+
+	if err := t.tmpl.Execute(&buf, post); err != nil {
+		return markup.Message{}, err
+	}
+
+	return t.parse(buf.String())
+}
+
+// Validate parses the template and renders a sample post to catch execution and markup errors
+func Validate(src string, format string) error {
+	tmpl, err := Parse(src, format)
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpl.Render(Post{
+		Title:       "Title",
+		Summary:     "Summary",
+		Link:        "https://example.com",
+		SourceName:  "Source",
+		Tags:        []string{"go"},
+		PublishedAt: time.Now(),
+		Language:    "en",
+		ImageURL:    "https://example.com/image.png",
+		Author:      "Author",
+	})
+
+	return err
+}
+
+var escapers = map[string]func(string) string{
+	FormatMarkdownV2: escapeMarkdownV2,
+	FormatHTML:       template.HTMLEscapeString,
+}
+
+// escapeMarkdownV2 escapes text for MarkdownV2, backslashes go first so a path like C:\dir isn't taken for escapes
+func escapeMarkdownV2(src string) string {
+	return markup.EscapeForMarkdown(strings.ReplaceAll(src, "\\", "\\\\"))
+}
+
+// markdowns convert CommonMark, e.g. of the LLM summary, into markup of the format
+var markdowns = map[string]func(string) string{
+	FormatMarkdownV2: func(src string) string { return markup.ParseCommonMark(src).MarkdownV2() },
+	FormatHTML:       func(src string) string { return markup.ParseCommonMark(src).HTML() },
+}
+
+var parsers = map[string]func(string) (markup.Message, error){
+	FormatMarkdownV2: markup.ParseMarkdownV2,
+	FormatHTML:       markup.ParseHTML,
+}
+
+// truncate cuts s to n runes adding an ellipsis, it's used before escaping
+func truncate(n int, s string) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+
+	if n <= 1 {
+		return "…"
+	}
+
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
+
+// relativeTime formats t relative to now, e.g. "5 мин назад"
+func relativeTime(t time.Time) string {
+	d := time.Since(t)
+
+	switch {
+	case d < time.Minute:
+		return "только что"
+	case d < time.Hour:
+		return fmt.Sprintf("%d мин назад", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d ч назад", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%d дн назад", int(d.Hours()/24))
+	}
+}
+
+// hashtags turns tags into hashtags, characters not allowed in hashtags are replaced with underscores
+func hashtags(tags []string) string {
+	result := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.Map(func(r rune) rune {
+			if r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 127 {
+				return r
+			}
+			return '_'
+		}, strings.TrimSpace(tag))
+
+		if tag != "" {
+			result = append(result, "#"+tag)
+		}
+	}
+
+	return strings.Join(result, " ")
+}
+
+// PlainText strips html tags from src and collapses whitespace
+func PlainText(src string) string {
+	var (
+		sb        strings.Builder
+		tokenizer = html.NewTokenizer(strings.NewReader(src))
+	)
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(sb.String()), " ")
+		case html.TextToken:
+			sb.Write(tokenizer.Text())
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			sb.WriteString(" ")
+		}
+	}
+}
+
+// NewPost builds the template data of the article with the summary
+func NewPost(article model.Article, summary string) Post {
+	return Post{
+		Title:       article.Title,
+		Summary:     summary,
+		Link:        article.Link,
+		SourceName:  article.SourceName,
+		Tags:        article.Tags,
+		PublishedAt: article.PublishedAt,
+		Language:    article.Language,
+		ImageURL:    article.ImageURL,
+		Author:      article.Author,
+	}
+}
+
+// TemplateFor returns the template of the article source if it has one, otherwise the template of the channel,
+// both are rendered in the channel format
+func TemplateFor(channel model.Channel, article model.Article) (*Template, error) {
+	src := channel.Template
+	if article.SourceTemplate != "" {
+		src = article.SourceTemplate
+	}
+
+	return Parse(src, channel.Format)
+}
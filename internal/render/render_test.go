@@ -0,0 +1,36 @@
+package render
+
+import (
+	"testing"
+)
+
+func TestRenderEscapes(t *testing.T) {
+	post := Post{
+		Title:   `C:\path title_[1].`,
+		Summary: `Use C:\Users\go *now* <b>`,
+		Link:    "https://example.com/a_(b)",
+	}
+
+	for _, format := range []string{FormatMarkdownV2, FormatHTML} {
+		t.Run(format, func(t *testing.T) {
+			tmpl, err := Parse("", format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			message, err := tmpl.Render(post)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+
+			want := "C:\\path title_[1].\n\nUse C:\\Users\\go now <b>\n\nhttps://example.com/a_(b)"
+			if message.Text != want {
+				t.Errorf("Render() = %q, want %q", message.Text, want)
+			}
+
+			if len(message.Entities) != 2 || message.Entities[0].Type != "bold" || message.Entities[1].Type != "italic" {
+				t.Errorf("entities = %+v, want the bold title and the italic word", message.Entities)
+			}
+		})
+	}
+}
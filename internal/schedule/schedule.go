@@ -0,0 +1,331 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a set of posting windows, each window has days of week, a time range
+// and an optional posting interval, e.g.
+//
+//	weekdays 08:00-22:00 every 15m; weekends 10:00-20:00 every 1h
+//
+// A time range may wrap over midnight (22:00-02:00), the part after midnight belongs to the
+// listed days. Days are optional and default to every day. An empty schedule is always open
+type Schedule struct {
+	windows  []window
+	location *time.Location
+}
+
+type window struct {
+	days     [7]bool
+	start    int // minutes since midnight
+	end      int // minutes since midnight, less than start if the window wraps over midnight
+	interval time.Duration
+}
+
+// maxLookahead bounds the search for the next open window
+const maxLookahead = 8 * 24 * time.Hour
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Parse parses windows separated by ";" in the timezone, an empty timezone means UTC
+func Parse(src string, timezone string) (*Schedule, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Schedule{location: location}
+
+	for _, part := range strings.Split(src, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		w, err := parseWindow(part)
+		if err != nil {
+			return nil, fmt.Errorf("schedule window %q: %w", part, err)
+		}
+
+		s.windows = append(s.windows, w)
+	}
+
+	return s, nil
+}
+
+func parseWindow(src string) (window, error) {
+	var (
+		w      window
+		fields = strings.Fields(src)
+	)
+
+	if len(fields) > 1 && fields[len(fields)-2] == "every" {
+		interval, err := time.ParseDuration(fields[len(fields)-1])
+		if err != nil {
+			return window{}, err
+		}
+
+		if interval < time.Second {
+			return window{}, fmt.Errorf("interval %s is too short", interval)
+		}
+
+		w.interval = interval
+		fields = fields[:len(fields)-2]
+	}
+
+	switch len(fields) {
+	case 1:
+		w.days = allDays()
+	case 2:
+		days, err := parseDays(fields[0])
+		if err != nil {
+			return window{}, err
+		}
+		w.days = days
+	default:
+		return window{}, fmt.Errorf("expected [days] HH:MM-HH:MM [every interval]")
+	}
+
+	start, end, ok := strings.Cut(fields[len(fields)-1], "-")
+	if !ok {
+		return window{}, fmt.Errorf("expected time range HH:MM-HH:MM")
+	}
+
+	var err error
+	if w.start, err = parseClock(start); err != nil {
+		return window{}, err
+	}
+
+	if w.end, err = parseClock(end); err != nil {
+		return window{}, err
+	}
+
+	if w.start == w.end {
+		return window{}, fmt.Errorf("empty time range")
+	}
+
+	return w, nil
+}
+
+func parseDays(src string) ([7]bool, error) {
+	src = strings.ToLower(src)
+
+	switch src {
+	case "*", "daily":
+		return allDays(), nil
+	case "weekdays":
+		src = "mon-fri"
+	case "weekends":
+		src = "sat,sun"
+	}
+
+	var days [7]bool
+
+	for _, part := range strings.Split(src, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+
+		first, ok := dayNames[from]
+		if !ok {
+			return days, fmt.Errorf("unknown day %q", from)
+		}
+
+		last := first
+		if isRange {
+			if last, ok = dayNames[to]; !ok {
+				return days, fmt.Errorf("unknown day %q", to)
+			}
+		}
+
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+func parseClock(src string) (int, error) {
+	hours, minutes, ok := strings.Cut(src, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time %q", src)
+	}
+
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("invalid time %q", src)
+	}
+
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", src)
+	}
+
+	return h*60 + m, nil
+}
+
+func allDays() [7]bool {
+	return [7]bool{true, true, true, true, true, true, true}
+}
+
+// IsZero reports whether the schedule has no windows and therefore is always open
+func (s *Schedule) IsZero() bool {
+	return len(s.windows) == 0
+}
+
+// At reports whether posting is allowed at t and the interval of the window t falls into,
+// the interval is zero if the window doesn't set one
+func (s *Schedule) At(t time.Time) (time.Duration, bool) {
+	if s.IsZero() {
+		return 0, true
+	}
+
+	var (
+		local     = t.In(s.location)
+		minute    = local.Hour()*60 + local.Minute()
+		today     = local.Weekday()
+		yesterday = (today + 6) % 7
+	)
+
+	for _, w := range s.windows {
+		if w.start < w.end {
+			if w.days[today] && minute >= w.start && minute < w.end {
+				return w.interval, true
+			}
+			continue
+		}
+
+		if (w.days[today] && minute >= w.start) || (w.days[yesterday] && minute < w.end) {
+			return w.interval, true
+		}
+	}
+
+	return 0, false
+}
+
+// NextOpen returns the earliest time at or after t when posting is allowed
+func (s *Schedule) NextOpen(t time.Time) time.Time {
+	if _, open := s.At(t); open {
+		return t
+	}
+
+	next := t.Truncate(time.Minute)
+
+	for deadline := t.Add(maxLookahead); next.Before(deadline); {
+		next = next.Add(time.Minute)
+
+		if _, open := s.At(next); open {
+			return next
+		}
+	}
+
+	return next
+}
+
+// Times is a set of moments of the day, each with optional days of week, e.g.
+//
+//	weekdays 09:00; weekdays 18:00; sun 12:00
+type Times struct {
+	moments  []moment
+	location *time.Location
+}
+
+type moment struct {
+	days   [7]bool
+	minute int // minutes since midnight
+}
+
+// ParseTimes parses moments separated by ";" in the timezone, an empty timezone means UTC
+func ParseTimes(src string, timezone string) (*Times, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Times{location: location}
+
+	for _, part := range strings.Split(src, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		m, err := parseMoment(part)
+		if err != nil {
+			return nil, fmt.Errorf("schedule time %q: %w", part, err)
+		}
+
+		t.moments = append(t.moments, m)
+	}
+
+	if len(t.moments) == 0 {
+		return nil, fmt.Errorf("no schedule times")
+	}
+
+	return t, nil
+}
+
+func parseMoment(src string) (moment, error) {
+	var (
+		m      moment
+		fields = strings.Fields(src)
+		err    error
+	)
+
+	switch len(fields) {
+	case 1:
+		m.days = allDays()
+	case 2:
+		if m.days, err = parseDays(fields[0]); err != nil {
+			return moment{}, err
+		}
+	default:
+		return moment{}, fmt.Errorf("expected [days] HH:MM")
+	}
+
+	if m.minute, err = parseClock(fields[len(fields)-1]); err != nil {
+		return moment{}, err
+	}
+
+	if m.minute == 24*60 {
+		return moment{}, fmt.Errorf("invalid time 24:00")
+	}
+
+	return m, nil
+}
+
+// Next returns the earliest moment strictly after t
+func (t *Times) Next(after time.Time) time.Time {
+	next := after.Truncate(time.Minute)
+
+	for deadline := after.Add(maxLookahead); next.Before(deadline); {
+		next = next.Add(time.Minute)
+
+		var (
+			local  = next.In(t.location)
+			minute = local.Hour()*60 + local.Minute()
+		)
+
+		for _, m := range t.moments {
+			if m.days[local.Weekday()] && m.minute == minute {
+				return next
+			}
+		}
+	}
+
+	return next
+}
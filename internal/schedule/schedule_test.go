@@ -0,0 +1,183 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// 2025-05-05 is a Monday, 2025-05-03 and 2025-05-04 are a weekend
+func utc(value string) time.Time {
+	t, err := time.Parse(time.DateTime, value)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src     string
+		windows int
+		wantErr bool
+	}{
+		{src: "", windows: 0},
+		{src: " ; ", windows: 0},
+		{src: "08:00-22:00", windows: 1},
+		{src: "weekdays 08:00-22:00 every 15m; weekends 10:00-20:00 every 1h", windows: 2},
+		{src: "mon-fri 09:00-18:00", windows: 1},
+		{src: "sat,sun 22:00-02:00", windows: 1},
+		{src: "fri-mon 00:00-24:00", windows: 1},
+		{src: "daily 10:00-11:00 every 30s", windows: 1},
+		{src: "08:00", wantErr: true},
+		{src: "10:00-10:00", wantErr: true},
+		{src: "25:00-26:00", wantErr: true},
+		{src: "08:60-09:00", wantErr: true},
+		{src: "24:30-01:00", wantErr: true},
+		{src: "funday 08:00-09:00", wantErr: true},
+		{src: "mon-xyz 08:00-09:00", wantErr: true},
+		{src: "08:00-09:00 every soon", wantErr: true},
+		{src: "08:00-09:00 every 10ms", wantErr: true},
+		{src: "mon 08:00-09:00 extra", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			s, err := Parse(tt.src, "UTC")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) = nil error, want error", tt.src)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.src, err)
+			}
+
+			if len(s.windows) != tt.windows {
+				t.Errorf("Parse(%q) has %d windows, want %d", tt.src, len(s.windows), tt.windows)
+			}
+		})
+	}
+}
+
+func TestParseUnknownTimezone(t *testing.T) {
+	if _, err := Parse("08:00-22:00", "Mars/Olympus"); err == nil {
+		t.Fatal("Parse() = nil error, want error for an unknown timezone")
+	}
+}
+
+func TestAt(t *testing.T) {
+	const src = "weekdays 08:00-22:00 every 15m; weekends 10:00-20:00 every 1h; fri 23:00-01:00"
+
+	tests := []struct {
+		at           string
+		wantOpen     bool
+		wantInterval time.Duration
+	}{
+		{at: "2025-05-05 07:59:00", wantOpen: false},
+		{at: "2025-05-05 08:00:00", wantOpen: true, wantInterval: 15 * time.Minute},
+		{at: "2025-05-05 21:59:59", wantOpen: true, wantInterval: 15 * time.Minute},
+		{at: "2025-05-05 22:00:00", wantOpen: false},
+		{at: "2025-05-03 09:00:00", wantOpen: false},
+		{at: "2025-05-03 10:00:00", wantOpen: true, wantInterval: time.Hour},
+		// the window wraps over midnight, the part after midnight belongs to friday
+		{at: "2025-05-02 23:30:00", wantOpen: true},
+		{at: "2025-05-03 00:30:00", wantOpen: true},
+		{at: "2025-05-03 01:00:00", wantOpen: false},
+		{at: "2025-05-05 00:30:00", wantOpen: false},
+	}
+
+	s, err := Parse(src, "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.at, func(t *testing.T) {
+			interval, open := s.At(utc(tt.at))
+			if open != tt.wantOpen || interval != tt.wantInterval {
+				t.Errorf("At(%s) = %s, %t, want %s, %t", tt.at, interval, open, tt.wantInterval, tt.wantOpen)
+			}
+		})
+	}
+}
+
+func TestAtEmptyScheduleIsAlwaysOpen(t *testing.T) {
+	s, err := Parse("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, open := s.At(utc("2025-05-05 03:00:00")); !open || !s.IsZero() {
+		t.Errorf("empty schedule: open = %t, IsZero() = %t, want true, true", open, s.IsZero())
+	}
+}
+
+func TestNextOpen(t *testing.T) {
+	tests := []struct {
+		src      string
+		timezone string
+		from     string
+		want     string
+	}{
+		{src: "08:00-22:00", timezone: "UTC", from: "2025-05-05 12:34:56", want: "2025-05-05 12:34:56"},
+		{src: "08:00-22:00", timezone: "UTC", from: "2025-05-05 03:10:30", want: "2025-05-05 08:00:00"},
+		{src: "08:00-22:00", timezone: "UTC", from: "2025-05-05 22:00:00", want: "2025-05-06 08:00:00"},
+		// friday night skips the weekend
+		{src: "weekdays 09:00-18:00", timezone: "UTC", from: "2025-05-02 18:00:00", want: "2025-05-05 09:00:00"},
+		// windows are in the schedule timezone, Moscow is UTC+3
+		{src: "09:00-18:00", timezone: "Europe/Moscow", from: "2025-05-05 03:00:00", want: "2025-05-05 06:00:00"},
+		{src: "09:00-18:00", timezone: "Europe/Moscow", from: "2025-05-05 15:00:00", want: "2025-05-06 06:00:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src+" "+tt.from, func(t *testing.T) {
+			s, err := Parse(tt.src, tt.timezone)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := s.NextOpen(utc(tt.from)); !got.Equal(utc(tt.want)) {
+				t.Errorf("NextOpen(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimesNext(t *testing.T) {
+	tests := []struct {
+		src   string
+		after string
+		want  string
+	}{
+		{src: "09:00", after: "2025-05-05 08:00:00", want: "2025-05-05 09:00:00"},
+		// strictly after, a digest posted at 09:00 is not due again at 09:00
+		{src: "09:00", after: "2025-05-05 09:00:00", want: "2025-05-06 09:00:00"},
+		{src: "weekdays 09:00; sun 12:00", after: "2025-05-02 10:00:00", want: "2025-05-04 12:00:00"},
+		{src: "weekdays 09:00; weekdays 18:00", after: "2025-05-05 09:30:00", want: "2025-05-05 18:00:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src+" "+tt.after, func(t *testing.T) {
+			times, err := ParseTimes(tt.src, "UTC")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := times.Next(utc(tt.after)); !got.Equal(utc(tt.want)) {
+				t.Errorf("Next(%s) = %s, want %s", tt.after, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimesErrors(t *testing.T) {
+	for _, src := range []string{"", "24:00", "9", "mon tue 09:00", "someday 09:00"} {
+		if _, err := ParseTimes(src, "UTC"); err == nil {
+			t.Errorf("ParseTimes(%q) = nil error, want error", src)
+		}
+	}
+}
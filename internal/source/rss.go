@@ -2,9 +2,13 @@ package source
 
 import (
 	"context"
+	"fmt"
 	"github.com/SlyMarbo/rss"
 	"github.com/lostmyescape/news-tg-bot/internal/model"
 	"github.com/samber/lo"
+	"html"
+	"net/http"
+	"regexp"
 )
 
 type RSSSource struct {
@@ -23,10 +27,11 @@ func NewRSSSourceFromModel(m model.Source) RSSSource {
 }
 
 // Fetch loads RSS-feed by s.URL, converts each rss item to model item, returns a slice of these items
-func (s RSSSource) Fetch(ctx context.Context) ([]model.Item, error) {
-	feed, err := s.loadFeed(ctx, s.URL)
+// and the HTTP status code of the feed response (0 if no response was received)
+func (s RSSSource) Fetch(ctx context.Context) ([]model.Item, int, error) {
+	feed, status, err := s.loadFeed(ctx, s.URL)
 	if err != nil {
-		return nil, err
+		return nil, status, err
 	}
 
 	return lo.Map(feed.Items, func(item *rss.Item, _ int) model.Item {
@@ -37,22 +42,58 @@ func (s RSSSource) Fetch(ctx context.Context) ([]model.Item, error) {
 			Date:       item.Date,
 			Summary:    item.Summary,
 			SourceName: s.SourceName,
+			Language:   feed.Language,
+
+			CommentsURL: commentsURL(item.Summary),
 		}
-	}), nil
+	}), status, nil
+}
+
+// commentsLink matches a link titled "Comments" that aggregators such as HN put into the item summary
+var commentsLink = regexp.MustCompile(`(?i)<a\s[^>]*href="([^"]+)"[^>]*>\s*comments\s*</a>`)
+
+// commentsURL returns the url of the item discussion if the summary links to one
+func commentsURL(summary string) string {
+	if m := commentsLink.FindStringSubmatch(summary); m != nil {
+		return html.UnescapeString(m[1])
+	}
+
+	return ""
 }
 
 // loadFeed does async rss feed loading,
 // returns an error if the context is canceled,
-// returns an error if parsing failed,
+// returns an error if the response status is not successful or parsing failed,
 // returns a feed if successful
-func (s RSSSource) loadFeed(ctx context.Context, url string) (*rss.Feed, error) {
+func (s RSSSource) loadFeed(ctx context.Context, url string) (*rss.Feed, int, error) {
 	var (
-		feedCh = make(chan *rss.Feed)
-		errCh  = make(chan error)
+		feedCh = make(chan *rss.Feed, 1)
+		errCh  = make(chan error, 1)
+		status int
 	)
 
+	fetchFunc := func(url string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		status = resp.StatusCode
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		}
+
+		return resp, nil
+	}
+
 	go func() {
-		feed, err := rss.Fetch(url)
+		feed, err := rss.FetchByFunc(fetchFunc, url)
 		if err != nil {
 			errCh <- err
 			return
@@ -63,11 +104,11 @@ func (s RSSSource) loadFeed(ctx context.Context, url string) (*rss.Feed, error)
 
 	select {
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		return nil, 0, ctx.Err()
 	case err := <-errCh:
-		return nil, err
+		return nil, status, err
 	case feed := <-feedCh:
-		return feed, nil
+		return feed, status, nil
 	}
 }
 
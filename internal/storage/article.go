@@ -3,7 +3,9 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/lostmyescape/news-tg-bot/internal/model"
 	"github.com/samber/lo"
 	"time"
@@ -17,30 +19,285 @@ func NewArticleStorage(db *sqlx.DB) *ArticlePostgresStorage {
 	return &ArticlePostgresStorage{db: db}
 }
 
-// Store save an article
-func (s *ArticlePostgresStorage) Store(ctx context.Context, article model.Article) error {
+// Channels notified of inserted and enriched articles, the payload is the article source id
+const (
+	ArticlesInserted = "articles_inserted"
+	ArticlesEnriched = "articles_enriched"
+)
+
+// Store save an article, reports whether a new row was inserted. Listeners of ArticlesInserted
+// are notified of a new row
+func (s *ArticlePostgresStorage) Store(ctx context.Context, article model.Article) (bool, error) {
 	conn, err := s.db.Connx(ctx)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer conn.Close()
-	if _, err := conn.ExecContext(ctx,
-		`INSERT INTO articles (source_id, title, link, summary, published_at)
-			VALUES ($1, $2, $3, $4, $5)
+	res, err := conn.ExecContext(ctx,
+		`INSERT INTO articles (source_id, title, link, summary, tags, language, comments_url, published_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 			ON CONFLICT DO NOTHING`,
 		article.SourceID,
 		article.Title,
 		article.Link,
 		article.Summary,
+		pq.StringArray(lo.Ternary(article.Tags != nil, article.Tags, []string{})),
+		article.Language,
+		article.CommentsURL,
 		article.PublishedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if inserted == 0 {
+		return false, nil
+	}
+
+	// the article is stored anyway, a lost notification only delays it until the next poll of the enricher
+	_, _ = conn.ExecContext(ctx, `SELECT pg_notify($1, $2::BIGINT::TEXT)`, ArticlesInserted, article.SourceID)
+
+	return true, nil
+}
+
+// articleColumns and articleSourceColumns are the columns dbArticle is read from,
+// queries join articles as a and sources as s
+const (
+	articleColumns = `a.id, a.source_id, a.title, a.link, a.summary, a.tags, a.language, a.comments_url,
+                a.published_at, a.posted_at, a.created_at, a.content, a.image_url, a.author, a.generated_summary,
+                a.enrichment_status, a.enrichment_attempts, a.enrichment_error, a.enriched_at`
+	articleSourceColumns = `COALESCE(s.priority, 1) AS source_priority, COALESCE(s.name, '') AS source_name,
+                COALESCE(s.template, '') AS source_template`
+)
+
+// routeFilter keeps articles routed to the channel c: of a source or with a tag routed to the channel,
+// a channel without routes receives every article. Articles stored before the channel was created
+// are kept only by a channel with backlog and only if they're not posted anywhere
+const routeFilter = `(a.created_at >= c.created_at OR (c.backlog AND a.posted_at IS NULL))
+           AND (
+               NOT EXISTS (SELECT 1 FROM source_channels sc WHERE sc.channel_id = c.id)
+               AND NOT EXISTS (SELECT 1 FROM tag_channels tc WHERE tc.channel_id = c.id)
+               OR a.source_id IN (SELECT sc.source_id FROM source_channels sc WHERE sc.channel_id = c.id)
+               OR EXISTS (
+                   SELECT 1 FROM tag_channels tc
+                   WHERE tc.channel_id = c.id AND tc.tag IN (SELECT lower(t) FROM unnest(a.tags) t)
+               )
+           )`
+
+// queueFilter keeps articles of the channel queue: routed to the channel, enriched, not delivered
+// to the channel and not of the excluded sources
+const queueFilter = routeFilter + `
+           AND a.enrichment_status = 'enriched'
+           AND NOT EXISTS (SELECT 1 FROM deliveries d WHERE d.article_id = a.id AND d.channel_id = c.id)
+           AND NOT (a.source_id = ANY($2))`
+
+// queueBranches reads the queue in two parts: articles not settled anywhere, found by the partial indexes
+// on unsettled rows, and articles posted, expired, rejected or dead in other channels within the freshness
+// window of any source.
+// Each part is ordered by the publishing time in dir and cut at limit
+func queueBranches(where string, dir string, limit string) string {
+	branch := func(posted string) string {
+		return fmt.Sprintf(`(SELECT %s FROM articles a
+             JOIN channels c ON c.id = $1
+             WHERE %s AND %s AND %s
+             ORDER BY a.published_at %s
+             LIMIT %s)`, articleColumns, where, posted, queueFilter, dir, limit)
+	}
+
+	return branch(`a.settled_at IS NULL`) + `
+         UNION ALL
+         ` + branch(`a.settled_at IS NOT NULL AND ($3 = 0 OR a.settled_at >= $4::TIMESTAMP - make_interval(
+                 secs => GREATEST($3, (SELECT COALESCE(MAX(freshness_window_seconds), 0) FROM sources))
+             ))`)
+}
+
+// Candidates will show the next articles of the channel queue in the order of the query, up to its limit.
+// Only articles routed to the channel by source or tag are taken into account, a channel without routes
+// receives every article
+func (s *ArticlePostgresStorage) Candidates(ctx context.Context, query model.QueueQuery) ([]model.Article, error) {
+	var sqlQuery string
+
+	switch query.Order {
+	case model.QueueNewest, model.QueueOldest:
+		dir := lo.Ternary(query.Order == model.QueueNewest, "DESC", "ASC")
+
+		sqlQuery = `SELECT ` + articleColumns + `, ` + articleSourceColumns + `
+         FROM (
+         ` + queueBranches("TRUE", dir, "$5") + `
+         ) a
+         LEFT JOIN sources s ON s.id = a.source_id
+         ORDER BY a.published_at ` + dir + `
+         LIMIT $5`
+	case model.QueueNewestPerSource:
+		sqlQuery = `SELECT ` + articleColumns + `, ` + articleSourceColumns + `
+         FROM sources s
+         CROSS JOIN LATERAL (
+             SELECT ` + articleColumns + ` FROM (
+             ` + queueBranches("a.source_id = s.id", "DESC", "1") + `
+             ) a
+             ORDER BY a.published_at DESC
+             LIMIT 1
+         ) a
+         WHERE NOT (s.id = ANY($2))
+         ORDER BY a.published_at DESC
+         LIMIT $5`
+	default:
+		return nil, fmt.Errorf("unknown queue order %q", query.Order)
+	}
+
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var articles []dbArticle
+
+	if err := conn.SelectContext(
+		ctx,
+		&articles,
+		sqlQuery,
+		query.ChannelID,
+		pq.Array(lo.Ternary(query.ExcludeSources != nil, query.ExcludeSources, []int64{})),
+		int64(query.FreshnessWindow.Seconds()),
+		time.Now().UTC(),
+		query.Limit,
 	); err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+
+	return lo.Map(articles, func(article dbArticle, _ int) model.Article {
+		return article.toModel()
+	}), nil
+}
+
+// ArticleById selects article by id together with its source name and template
+func (s *ArticlePostgresStorage) ArticleById(ctx context.Context, id int64) (*model.Article, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var article dbArticle
+	if err := conn.GetContext(
+		ctx,
+		&article,
+		`SELECT `+articleColumns+`, `+articleSourceColumns+`
+         FROM articles a
+         LEFT JOIN sources s ON s.id = a.source_id
+         WHERE a.id = $1`,
+		id,
+	); err != nil {
+		return nil, err
+	}
+
+	result := article.toModel()
+
+	return &result, nil
+}
+
+// DueForRetry will show articles whose delivery to the channel failed and is due for another attempt,
+// deliveries left in sending by an instance whose lease has expired are considered failed
+func (s *ArticlePostgresStorage) DueForRetry(ctx context.Context, channelID int64) ([]model.Article, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var articles []dbArticle
+
+	if err := conn.SelectContext(
+		ctx,
+		&articles,
+		`SELECT `+articleColumns+`, `+articleSourceColumns+`, d.summary AS reviewed_summary
+         FROM articles a
+         JOIN deliveries d ON d.article_id = a.id AND d.channel_id = $1
+         LEFT JOIN sources s ON s.id = a.source_id
+         WHERE (d.status = 'failed' AND d.next_attempt_at <= $2)
+            OR (d.status = 'sending' AND d.leased_until < $2)
+         ORDER BY d.next_attempt_at NULLS FIRST
+         `,
+		channelID,
+		time.Now().UTC(),
+	); err != nil {
+		return nil, err
+	}
+
+	return lo.Map(articles, func(article dbArticle, _ int) model.Article {
+		return article.toModel()
+	}), nil
+}
+
+// Approved will show articles approved by moderators for the channel in the order of approval
+// together with the reviewed summaries
+func (s *ArticlePostgresStorage) Approved(ctx context.Context, channelID int64) ([]model.Article, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var articles []dbArticle
+
+	if err := conn.SelectContext(
+		ctx,
+		&articles,
+		`SELECT `+articleColumns+`, `+articleSourceColumns+`, d.summary AS reviewed_summary
+         FROM articles a
+         JOIN deliveries d ON d.article_id = a.id AND d.channel_id = $1
+         LEFT JOIN sources s ON s.id = a.source_id
+         WHERE d.status = 'approved'
+         ORDER BY d.updated_at
+         `,
+		channelID,
+	); err != nil {
+		return nil, err
+	}
+
+	return lo.Map(articles, func(article dbArticle, _ int) model.Article {
+		return article.toModel()
+	}), nil
+}
+
+// PostedSince will show articles posted to some channel after since, grouped by source and newest first
+func (s *ArticlePostgresStorage) PostedSince(ctx context.Context, since time.Time) ([]model.Article, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var articles []dbArticle
+
+	if err := conn.SelectContext(
+		ctx,
+		&articles,
+		`SELECT `+articleColumns+`, `+articleSourceColumns+`
+         FROM articles a
+         LEFT JOIN sources s ON s.id = a.source_id
+         WHERE a.posted_at > $1
+         ORDER BY source_name, a.published_at DESC
+         `,
+		since,
+	); err != nil {
+		return nil, err
+	}
+
+	return lo.Map(articles, func(article dbArticle, _ int) model.Article {
+		return article.toModel()
+	}), nil
 }
 
-// AllNotPosted will show articles that have not yet been published
-func (s *ArticlePostgresStorage) AllNotPosted(ctx context.Context) ([]model.Article, error) {
+// LeaseForEnrichment leases up to limit articles waiting for enrichment, newest first, until leasedUntil.
+// Articles leased by another instance or waiting for a retry are skipped
+func (s *ArticlePostgresStorage) LeaseForEnrichment(ctx context.Context, limit int, leasedUntil time.Time) ([]model.Article, error) {
 	conn, err := s.db.Connx(ctx)
 	if err != nil {
 		return nil, err
@@ -52,29 +309,120 @@ func (s *ArticlePostgresStorage) AllNotPosted(ctx context.Context) ([]model.Arti
 	if err := conn.SelectContext(
 		ctx,
 		&articles,
-		`SELECT * FROM articles
-         WHERE posted_at IS NULL
-         ORDER BY published_at DESC
-         `); err != nil {
+		`UPDATE articles a SET (enrichment_status, enrichment_leased_until) = ('enriching', $1)
+			FROM (
+			    SELECT id FROM articles
+			    WHERE enrichment_status IN ('pending', 'enriching', 'failed')
+			      AND (enrichment_retry_at IS NULL OR enrichment_retry_at <= $2)
+			      AND (enrichment_leased_until IS NULL OR enrichment_leased_until < $2)
+			    ORDER BY created_at DESC
+			    LIMIT $3
+			    FOR UPDATE SKIP LOCKED
+			) due
+			WHERE a.id = due.id
+			RETURNING `+articleColumns,
+		leasedUntil,
+		time.Now().UTC(),
+		limit,
+	); err != nil {
 		return nil, err
 	}
 
 	return lo.Map(articles, func(article dbArticle, _ int) model.Article {
-		return model.Article{
-			ID:          article.ID,
-			SourceID:    article.SourceID,
-			Title:       article.Title,
-			Link:        article.Link,
-			Summary:     article.Summary,
-			PostedAt:    article.PostedAt.Time,
-			PublishedAt: article.PublishedAt,
-			CreatedAt:   article.CreatedAt,
-		}
+		return article.toModel()
 	}), nil
 }
 
-// MarkAsPosted notes an article that was posted
-func (s *ArticlePostgresStorage) MarkAsPosted(ctx context.Context, article model.Article) error {
+// SaveContent saves the extracted content of the article, the language of the feed is kept if it has one.
+// A date found on the page by an extraction rule replaces the date of the feed
+func (s *ArticlePostgresStorage) SaveContent(ctx context.Context, id int64, content model.ArticleContent) error {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(
+		ctx,
+		`UPDATE articles SET (content, image_url, language, author, published_at) =
+			    ($1, $2, COALESCE(NULLIF(language, ''), $3), $4, COALESCE($5, published_at))
+			WHERE id = $6`,
+		content.Text,
+		content.ImageURL,
+		content.Language,
+		content.Author,
+		sql.NullTime{Time: content.PublishedAt.UTC(), Valid: !content.PublishedAt.IsZero()},
+		id,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// MarkEnriched saves the summary of the article and releases it to channel queues.
+// Listeners of ArticlesEnriched are notified
+func (s *ArticlePostgresStorage) MarkEnriched(ctx context.Context, id int64, summary string) error {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var sourceID int64
+
+	if err := conn.GetContext(
+		ctx,
+		&sourceID,
+		`UPDATE articles
+			SET (enrichment_status, generated_summary, enriched_at, enrichment_retry_at, enrichment_leased_until) =
+			    ('enriched', $1, $2, NULL, NULL)
+			WHERE id = $3
+			RETURNING source_id`,
+		summary,
+		time.Now().UTC(),
+		id,
+	); err != nil {
+		return err
+	}
+
+	// the article is enriched anyway, a lost notification only delays it until the next poll of the notifier
+	_, _ = conn.ExecContext(ctx, `SELECT pg_notify($1, $2::BIGINT::TEXT)`, ArticlesEnriched, sourceID)
+
+	return nil
+}
+
+// MarkEnrichmentFailed notes a failed attempt to enrich the article, it's enriched again after retryAt.
+// Returns the number of attempts
+func (s *ArticlePostgresStorage) MarkEnrichmentFailed(ctx context.Context, id int64, lastError string, retryAt time.Time) (int, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var attempts int
+
+	if err := conn.GetContext(
+		ctx,
+		&attempts,
+		`UPDATE articles
+			SET (enrichment_status, enrichment_attempts, enrichment_error, enrichment_retry_at, enrichment_leased_until) =
+			    ('failed', enrichment_attempts + 1, $1, $2, NULL)
+			WHERE id = $3
+			RETURNING enrichment_attempts`,
+		lastError,
+		retryAt,
+		id,
+	); err != nil {
+		return 0, err
+	}
+
+	return attempts, nil
+}
+
+// MarkEnrichmentDead gives up enriching the article, it's never posted
+func (s *ArticlePostgresStorage) MarkEnrichmentDead(ctx context.Context, id int64) error {
 	conn, err := s.db.Connx(ctx)
 	if err != nil {
 		return err
@@ -83,9 +431,9 @@ func (s *ArticlePostgresStorage) MarkAsPosted(ctx context.Context, article model
 
 	if _, err := conn.ExecContext(
 		ctx,
-		`UPDATE articles SET posted_at = $1::timestamp WHERE id = $2;`,
-		time.Now().UTC().Format(time.RFC3339),
-		article.ID,
+		`UPDATE articles SET (enrichment_status, enrichment_retry_at, enrichment_leased_until) = ('dead', NULL, NULL)
+			WHERE id = $1`,
+		id,
 	); err != nil {
 		return err
 	}
@@ -93,13 +441,153 @@ func (s *ArticlePostgresStorage) MarkAsPosted(ctx context.Context, article model
 	return nil
 }
 
+// EnrichmentCounts counts articles stored since the given time by enrichment status
+func (s *ArticlePostgresStorage) EnrichmentCounts(ctx context.Context, since time.Time) (map[string]int, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var counts []dbStatusCount
+
+	if err := conn.SelectContext(
+		ctx,
+		&counts,
+		`SELECT enrichment_status AS status, COUNT(*) AS count FROM articles
+			WHERE created_at >= $1
+			GROUP BY enrichment_status`,
+		since,
+	); err != nil {
+		return nil, err
+	}
+
+	return lo.SliceToMap(counts, func(c dbStatusCount) (string, int) { return c.Status, c.Count }), nil
+}
+
+// NotEnriched returns up to limit articles that failed enrichment, the latest first
+func (s *ArticlePostgresStorage) NotEnriched(ctx context.Context, limit int) ([]model.Article, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var articles []dbArticle
+
+	if err := conn.SelectContext(
+		ctx,
+		&articles,
+		`SELECT `+articleColumns+`, `+articleSourceColumns+`
+         FROM articles a
+         LEFT JOIN sources s ON s.id = a.source_id
+         WHERE a.enrichment_status IN ('failed', 'dead')
+         ORDER BY a.created_at DESC
+         LIMIT $1`,
+		limit,
+	); err != nil {
+		return nil, err
+	}
+
+	return lo.Map(articles, func(article dbArticle, _ int) model.Article {
+		return article.toModel()
+	}), nil
+}
+
+// PostedCountBySource counts articles posted to the channel since the given time grouped by source id
+func (s *ArticlePostgresStorage) PostedCountBySource(ctx context.Context, channelID int64, since time.Time) (map[int64]int, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var counts []dbSourceCount
+
+	if err := conn.SelectContext(
+		ctx,
+		&counts,
+		`SELECT a.source_id, COUNT(*) AS count FROM deliveries d
+         JOIN articles a ON a.id = d.article_id
+         WHERE d.channel_id = $1 AND d.status = 'sent' AND d.posted_at >= $2
+         GROUP BY a.source_id`,
+		channelID,
+		since,
+	); err != nil {
+		return nil, err
+	}
+
+	return lo.SliceToMap(counts, func(c dbSourceCount) (int64, int) { return c.SourceID, c.Count }), nil
+}
+
 type dbArticle struct {
-	ID          int64        `db:"id"`
-	SourceID    int64        `db:"source_id"`
-	Title       string       `db:"title"`
-	Link        string       `db:"link"`
-	Summary     string       `db:"summary"`
-	PublishedAt time.Time    `db:"published_at"`
-	PostedAt    sql.NullTime `db:"posted_at"`
-	CreatedAt   time.Time    `db:"created_at"`
+	ID          int64          `db:"id"`
+	SourceID    int64          `db:"source_id"`
+	Title       string         `db:"title"`
+	Link        string         `db:"link"`
+	Summary     string         `db:"summary"`
+	Tags        pq.StringArray `db:"tags"`
+	Language    string         `db:"language"`
+	CommentsURL string         `db:"comments_url"`
+	PublishedAt time.Time      `db:"published_at"`
+	PostedAt    sql.NullTime   `db:"posted_at"`
+	CreatedAt   time.Time      `db:"created_at"`
+
+	Content          string `db:"content"`
+	ImageURL         string `db:"image_url"`
+	Author           string `db:"author"`
+	GeneratedSummary string `db:"generated_summary"`
+
+	EnrichmentStatus   string       `db:"enrichment_status"`
+	EnrichmentAttempts int          `db:"enrichment_attempts"`
+	EnrichmentError    string       `db:"enrichment_error"`
+	EnrichedAt         sql.NullTime `db:"enriched_at"`
+
+	SourcePriority int    `db:"source_priority"`
+	SourceName     string `db:"source_name"`
+	SourceTemplate string `db:"source_template"`
+
+	ReviewedSummary string `db:"reviewed_summary"`
+}
+
+type dbSourceCount struct {
+	SourceID int64 `db:"source_id"`
+	Count    int   `db:"count"`
+}
+
+type dbStatusCount struct {
+	Status string `db:"status"`
+	Count  int    `db:"count"`
+}
+
+func (a dbArticle) toModel() model.Article {
+	return model.Article{
+		ID:          a.ID,
+		SourceID:    a.SourceID,
+		Title:       a.Title,
+		Link:        a.Link,
+		Summary:     a.Summary,
+		Tags:        a.Tags,
+		Language:    a.Language,
+		CommentsURL: a.CommentsURL,
+		PostedAt:    a.PostedAt.Time,
+		PublishedAt: a.PublishedAt,
+		CreatedAt:   a.CreatedAt,
+
+		Content:          a.Content,
+		ImageURL:         a.ImageURL,
+		Author:           a.Author,
+		GeneratedSummary: a.GeneratedSummary,
+
+		EnrichmentStatus:   a.EnrichmentStatus,
+		EnrichmentAttempts: a.EnrichmentAttempts,
+		EnrichmentError:    a.EnrichmentError,
+		EnrichedAt:         a.EnrichedAt.Time,
+
+		SourcePriority: a.SourcePriority,
+		SourceName:     a.SourceName,
+		SourceTemplate: a.SourceTemplate,
+
+		ReviewedSummary: a.ReviewedSummary,
+	}
 }
@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/samber/lo"
+	"time"
+)
+
+type ChannelPostgresStorage struct {
+	db *sqlx.DB
+}
+
+func NewChannelStorage(db *sqlx.DB) *ChannelPostgresStorage {
+	return &ChannelPostgresStorage{db: db}
+}
+
+// Add adds channel to database and returns an id
+func (s *ChannelPostgresStorage) Add(ctx context.Context, channel model.Channel) (int64, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var id int64
+
+	row := conn.QueryRowContext(
+		ctx,
+		`INSERT INTO channels (name, chat_id, posting_interval_seconds, strategy, max_posts_per_source_per_hour,
+                      schedule, timezone, mode, digest_times, template, format, feedback_buttons,
+                      moderation_chat_id, moderation_timeout_seconds, moderation_timeout_action,
+                      publisher, webhook_url, digest_group_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18) RETURNING id`,
+		channel.Name,
+		channel.ChatID,
+		int64(channel.PostingInterval.Seconds()),
+		channel.Strategy,
+		channel.MaxPostsPerSourcePerHour,
+		channel.Schedule,
+		channel.Timezone,
+		channel.Mode,
+		channel.DigestTimes,
+		channel.Template,
+		channel.Format,
+		channel.FeedbackButtons,
+		channel.ModerationChatID,
+		int64(channel.ModerationTimeout.Seconds()),
+		channel.ModerationTimeoutAction,
+		channel.Publisher,
+		channel.WebhookURL,
+		channel.DigestGroupBy,
+	)
+
+	if err := row.Err(); err != nil {
+		return 0, err
+	}
+
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Register adds channel or, if a channel with the same chat id or webhook url is already registered,
+// updates the settings that come from config. The name, template, format and feedback buttons are left
+// to admins, backlog is only set on insert
+func (s *ChannelPostgresStorage) Register(ctx context.Context, channel model.Channel) error {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// the conflict target is the unique index of the destination kind
+	conflict := `(chat_id) WHERE publisher = 'telegram'`
+	if channel.Publisher != model.PublisherTelegram {
+		conflict = `(webhook_url) WHERE publisher <> 'telegram'`
+	}
+
+	if _, err := conn.ExecContext(
+		ctx,
+		`INSERT INTO channels (name, chat_id, posting_interval_seconds, strategy, max_posts_per_source_per_hour,
+                      schedule, timezone, mode, digest_times, template, format, feedback_buttons,
+                      moderation_chat_id, moderation_timeout_seconds, moderation_timeout_action,
+                      publisher, webhook_url, backlog, digest_group_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+			ON CONFLICT `+conflict+` DO UPDATE
+			SET (posting_interval_seconds, strategy, max_posts_per_source_per_hour, schedule, timezone, mode,
+			     digest_times, digest_group_by, moderation_chat_id, moderation_timeout_seconds, moderation_timeout_action) =
+			    (EXCLUDED.posting_interval_seconds, EXCLUDED.strategy, EXCLUDED.max_posts_per_source_per_hour,
+			     EXCLUDED.schedule, EXCLUDED.timezone, EXCLUDED.mode, EXCLUDED.digest_times, EXCLUDED.digest_group_by,
+			     EXCLUDED.moderation_chat_id, EXCLUDED.moderation_timeout_seconds, EXCLUDED.moderation_timeout_action)`,
+		channel.Name,
+		channel.ChatID,
+		int64(channel.PostingInterval.Seconds()),
+		channel.Strategy,
+		channel.MaxPostsPerSourcePerHour,
+		channel.Schedule,
+		channel.Timezone,
+		channel.Mode,
+		channel.DigestTimes,
+		channel.Template,
+		channel.Format,
+		channel.FeedbackButtons,
+		channel.ModerationChatID,
+		int64(channel.ModerationTimeout.Seconds()),
+		channel.ModerationTimeoutAction,
+		channel.Publisher,
+		channel.WebhookURL,
+		channel.Backlog,
+		channel.DigestGroupBy,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Edit edits channel settings by id and returns an id
+func (s *ChannelPostgresStorage) Edit(ctx context.Context, channel model.Channel) (int64, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var id int64
+
+	row := conn.QueryRowContext(
+		ctx,
+		`UPDATE channels SET (name, posting_interval_seconds, strategy, max_posts_per_source_per_hour,
+                              schedule, timezone, mode, digest_times, template, format, feedback_buttons,
+                              moderation_chat_id, moderation_timeout_seconds, moderation_timeout_action,
+                              webhook_url, digest_group_by)
+			= ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+			WHERE id = $17 RETURNING id`,
+		channel.Name,
+		int64(channel.PostingInterval.Seconds()),
+		channel.Strategy,
+		channel.MaxPostsPerSourcePerHour,
+		channel.Schedule,
+		channel.Timezone,
+		channel.Mode,
+		channel.DigestTimes,
+		channel.Template,
+		channel.Format,
+		channel.FeedbackButtons,
+		channel.ModerationChatID,
+		int64(channel.ModerationTimeout.Seconds()),
+		channel.ModerationTimeoutAction,
+		channel.WebhookURL,
+		channel.DigestGroupBy,
+		channel.ID,
+	)
+
+	if err := row.Err(); err != nil {
+		return 0, err
+	}
+
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// ChannelById selects channel by id
+func (s *ChannelPostgresStorage) ChannelById(ctx context.Context, id int64) (*model.Channel, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var channel dbChannel
+	if err := conn.GetContext(ctx, &channel, `SELECT `+channelColumns+` FROM channels WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+
+	result := channel.toModel()
+
+	return &result, nil
+}
+
+// Channels returns all channels
+func (s *ChannelPostgresStorage) Channels(ctx context.Context) ([]model.Channel, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var channels []dbChannel
+	if err := conn.SelectContext(ctx, &channels, `SELECT `+channelColumns+` FROM channels ORDER BY id`); err != nil {
+		return nil, err
+	}
+
+	return lo.Map(channels, func(channel dbChannel, _ int) model.Channel { return channel.toModel() }), nil
+}
+
+// Delete deletes channel by id together with its routes
+func (s *ChannelPostgresStorage) Delete(ctx context.Context, id int64) (int64, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `DELETE FROM channels WHERE id = $1`, id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// AddRoute routes articles of the source or with the tag to the channel
+func (s *ChannelPostgresStorage) AddRoute(ctx context.Context, route model.Route) error {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if route.SourceID == 0 {
+		_, err = conn.ExecContext(
+			ctx,
+			`INSERT INTO tag_channels (tag, channel_id) VALUES (lower($1), $2) ON CONFLICT DO NOTHING`,
+			route.Tag,
+			route.ChannelID,
+		)
+	} else {
+		_, err = conn.ExecContext(
+			ctx,
+			`INSERT INTO source_channels (source_id, channel_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+			route.SourceID,
+			route.ChannelID,
+		)
+	}
+
+	return err
+}
+
+// DeleteRoute stops routing articles of the source or with the tag to the channel
+func (s *ChannelPostgresStorage) DeleteRoute(ctx context.Context, route model.Route) error {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if route.SourceID == 0 {
+		_, err = conn.ExecContext(
+			ctx,
+			`DELETE FROM tag_channels WHERE tag = lower($1) AND channel_id = $2`,
+			route.Tag,
+			route.ChannelID,
+		)
+	} else {
+		_, err = conn.ExecContext(
+			ctx,
+			`DELETE FROM source_channels WHERE source_id = $1 AND channel_id = $2`,
+			route.SourceID,
+			route.ChannelID,
+		)
+	}
+
+	return err
+}
+
+// Routes returns all source and tag to channel routes, source routes first
+func (s *ChannelPostgresStorage) Routes(ctx context.Context) ([]model.Route, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var routes []dbRoute
+	if err := conn.SelectContext(
+		ctx,
+		&routes,
+		`SELECT source_id, '' AS tag, channel_id FROM source_channels
+         UNION ALL
+         SELECT 0, tag, channel_id FROM tag_channels
+         ORDER BY channel_id, source_id DESC, tag`,
+	); err != nil {
+		return nil, err
+	}
+
+	return lo.Map(routes, func(route dbRoute, _ int) model.Route { return model.Route(route) }), nil
+}
+
+const channelColumns = `id, name, chat_id, posting_interval_seconds, created_at, strategy, max_posts_per_source_per_hour,
+                schedule, timezone, mode, digest_times, digest_group_by, template, format, feedback_buttons,
+                moderation_chat_id, moderation_timeout_seconds, moderation_timeout_action, publisher, webhook_url, backlog`
+
+type dbChannel struct {
+	ID                     int64     `db:"id"`
+	Name                   string    `db:"name"`
+	ChatID                 int64     `db:"chat_id"`
+	PostingIntervalSeconds int64     `db:"posting_interval_seconds"`
+	CreatedAt              time.Time `db:"created_at"`
+	Strategy               string    `db:"strategy"`
+	MaxPostsPerSourceHour  int       `db:"max_posts_per_source_per_hour"`
+	Schedule               string    `db:"schedule"`
+	Timezone               string    `db:"timezone"`
+	Mode                   string    `db:"mode"`
+	DigestTimes            string    `db:"digest_times"`
+	DigestGroupBy          string    `db:"digest_group_by"`
+	Template               string    `db:"template"`
+	Format                 string    `db:"format"`
+	FeedbackButtons        bool      `db:"feedback_buttons"`
+
+	ModerationChatID         int64  `db:"moderation_chat_id"`
+	ModerationTimeoutSeconds int64  `db:"moderation_timeout_seconds"`
+	ModerationTimeoutAction  string `db:"moderation_timeout_action"`
+
+	Publisher  string `db:"publisher"`
+	WebhookURL string `db:"webhook_url"`
+	Backlog    bool   `db:"backlog"`
+}
+
+func (c dbChannel) toModel() model.Channel {
+	return model.Channel{
+		ID:              c.ID,
+		Name:            c.Name,
+		ChatID:          c.ChatID,
+		PostingInterval: time.Duration(c.PostingIntervalSeconds) * time.Second,
+		CreatedAt:       c.CreatedAt,
+
+		Strategy:                 c.Strategy,
+		MaxPostsPerSourcePerHour: c.MaxPostsPerSourceHour,
+		Schedule:                 c.Schedule,
+		Timezone:                 c.Timezone,
+		Mode:                     c.Mode,
+		DigestTimes:              c.DigestTimes,
+		DigestGroupBy:            c.DigestGroupBy,
+		Template:                 c.Template,
+		Format:                   c.Format,
+		FeedbackButtons:          c.FeedbackButtons,
+		ModerationChatID:         c.ModerationChatID,
+		ModerationTimeout:        time.Duration(c.ModerationTimeoutSeconds) * time.Second,
+		ModerationTimeoutAction:  c.ModerationTimeoutAction,
+		Publisher:                c.Publisher,
+		WebhookURL:               c.WebhookURL,
+		Backlog:                  c.Backlog,
+	}
+}
+
+type dbRoute struct {
+	SourceID  int64  `db:"source_id"`
+	Tag       string `db:"tag"`
+	ChannelID int64  `db:"channel_id"`
+}
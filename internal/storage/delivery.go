@@ -0,0 +1,543 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/samber/lo"
+	"time"
+)
+
+type DeliveryPostgresStorage struct {
+	db *sqlx.DB
+}
+
+func NewDeliveryStorage(db *sqlx.DB) *DeliveryPostgresStorage {
+	return &DeliveryPostgresStorage{db: db}
+}
+
+// Lease claims the delivery of the article to the channel until leasedUntil and returns the attempt number.
+// Only new, approved and failed deliveries due for a retry are claimed, the delivery of a crashed instance
+// is retried once its lease expires. false is returned if another instance holds the delivery or the delivery
+// is done: sent, dead, expired, rejected or waiting for review
+func (s *DeliveryPostgresStorage) Lease(ctx context.Context, articleID int64, channelID int64, leasedUntil time.Time) (int, bool, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO deliveries (article_id, channel_id, status, attempts, updated_at)
+			VALUES ($1, $2, 'pending', 0, $3)
+			ON CONFLICT (article_id, channel_id) DO NOTHING`,
+		articleID,
+		channelID,
+		now,
+	); err != nil {
+		return 0, false, err
+	}
+
+	var attempts int
+
+	// a delivery locked by another instance is being leased right now, it's skipped rather than waited for
+	if err := tx.GetContext(
+		ctx,
+		&attempts,
+		`UPDATE deliveries SET (status, attempts, leased_until, updated_at) = ('sending', attempts + 1, $1, $2)
+			WHERE id = (
+				SELECT id FROM deliveries
+				WHERE article_id = $3 AND channel_id = $4
+				  AND (
+				      (status IN ('pending', 'approved') AND (leased_until IS NULL OR leased_until < $2))
+				      OR (status = 'failed' AND (next_attempt_at IS NULL OR next_attempt_at <= $2))
+				      OR (status = 'sending' AND leased_until < $2)
+				  )
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING attempts`,
+		leasedUntil,
+		now,
+		articleID,
+		channelID,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, err
+	}
+
+	return attempts, true, nil
+}
+
+// MarkSent notes articles that were posted to the channel in the message
+func (s *DeliveryPostgresStorage) MarkSent(ctx context.Context, articles []model.Article, channelID int64, messageID int) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		now = time.Now().UTC()
+		ids = pq.Array(lo.Map(articles, func(article model.Article, _ int) int64 { return article.ID }))
+	)
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO deliveries (article_id, channel_id, status, attempts, message_id, posted_at, updated_at)
+			SELECT UNNEST($1::BIGINT[]), $2, 'sent', 1, $3, $4, $4
+			ON CONFLICT (article_id, channel_id) DO UPDATE
+			SET status = 'sent', message_id = EXCLUDED.message_id, last_error = '', next_attempt_at = NULL,
+			    leased_until = NULL, posted_at = EXCLUDED.posted_at, updated_at = EXCLUDED.updated_at`,
+		ids,
+		channelID,
+		messageID,
+		now,
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE articles SET (posted_at, settled_at) = (COALESCE(posted_at, $1), COALESCE(settled_at, $1))
+			WHERE id = ANY($2::BIGINT[]);`,
+		now,
+		ids,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MarkFailed notes a failed attempt, the delivery is retried after nextAttemptAt
+func (s *DeliveryPostgresStorage) MarkFailed(ctx context.Context, articleID int64, channelID int64, lastError string, nextAttemptAt time.Time) error {
+	return s.markUnsent(ctx, articleID, channelID, model.DeliveryStatusFailed, lastError, sql.NullTime{Time: nextAttemptAt, Valid: true})
+}
+
+// MarkDead notes a delivery that will not be retried until requeued
+func (s *DeliveryPostgresStorage) MarkDead(ctx context.Context, articleID int64, channelID int64, lastError string) error {
+	return s.markUnsent(ctx, articleID, channelID, model.DeliveryStatusDead, lastError, sql.NullTime{})
+}
+
+func (s *DeliveryPostgresStorage) markUnsent(
+	ctx context.Context,
+	articleID int64,
+	channelID int64,
+	status string,
+	lastError string,
+	nextAttemptAt sql.NullTime,
+) error {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(
+		ctx,
+		`WITH changed AS (
+             UPDATE deliveries SET (status, last_error, next_attempt_at, leased_until, updated_at) = ($1, $2, $3, NULL, $4)
+             WHERE article_id = $5 AND channel_id = $6
+             RETURNING article_id, status
+         )
+         `+settleArticles("$4"),
+		status,
+		lastError,
+		nextAttemptAt,
+		time.Now().UTC(),
+		articleID,
+		channelID,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// expireBatch is the maximum number of articles expired by a single statement
+const expireBatch = 500
+
+// settleArticles returns a statement noting the time the articles of deliveries in the changed CTE
+// left the queue of a channel for good, now is the placeholder of the current time
+func settleArticles(now string) string {
+	return `UPDATE articles SET settled_at = COALESCE(settled_at, ` + now + `::TIMESTAMP)
+             WHERE id IN (SELECT article_id FROM changed WHERE status IN ('sent', 'expired', 'rejected', 'dead'))`
+}
+
+// staleFilter keeps articles routed to the channel c and published before the freshness window of their source s,
+// or before the given window $3 if the source has none
+const staleFilter = `COALESCE(NULLIF(s.freshness_window_seconds, 0), $3) > 0
+               AND a.published_at < $2::TIMESTAMP - make_interval(secs => COALESCE(NULLIF(s.freshness_window_seconds, 0), $3))
+               AND ` + routeFilter
+
+// Expire moves articles older than the freshness window that are waiting for the channel, new or failed,
+// to the expired state. The window of the article source is used if set, otherwise the given one,
+// a zero window disables expiration. Articles are expired in batches, only articles the channel has no
+// delivery of or a pending or failed one are looked at. Returns the number of expired articles by source id
+func (s *DeliveryPostgresStorage) Expire(ctx context.Context, channelID int64, window time.Duration) (map[int64]int, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	expired := make(map[int64]int)
+
+	for {
+		var counts []dbSourceCount
+
+		// articles settled elsewhere before the longest window are out of every queue, they aren't looked at
+		if err := conn.SelectContext(
+			ctx,
+			&counts,
+			`WITH stale AS (
+                 SELECT id FROM (
+                     SELECT a.id FROM articles a
+                     JOIN channels c ON c.id = $1
+                     LEFT JOIN sources s ON s.id = a.source_id
+                     WHERE (a.settled_at IS NULL OR a.settled_at >= $2::TIMESTAMP - make_interval(
+                             secs => GREATEST($3, (SELECT COALESCE(MAX(freshness_window_seconds), 0) FROM sources))
+                         ))
+                       AND NOT EXISTS (SELECT 1 FROM deliveries d WHERE d.article_id = a.id AND d.channel_id = c.id)
+                       AND `+staleFilter+`
+                     UNION ALL
+                     SELECT a.id FROM deliveries d
+                     JOIN articles a ON a.id = d.article_id
+                     JOIN channels c ON c.id = d.channel_id
+                     LEFT JOIN sources s ON s.id = a.source_id
+                     WHERE d.channel_id = $1 AND d.status IN ('pending', 'failed')
+                       AND `+staleFilter+`
+                 ) stale
+                 LIMIT $4
+             ), changed AS (
+                 INSERT INTO deliveries (article_id, channel_id, status, attempts, updated_at)
+                 SELECT id, $1, 'expired', 0, $2::TIMESTAMP FROM stale
+                 ON CONFLICT (article_id, channel_id) DO UPDATE
+                 SET status = 'expired', next_attempt_at = NULL, updated_at = EXCLUDED.updated_at
+                 WHERE deliveries.status IN ('pending', 'failed')
+                 RETURNING article_id, status
+             ), settled AS (
+                 `+settleArticles("$2")+`
+             )
+             SELECT a.source_id, COUNT(*) AS count FROM changed e
+             JOIN articles a ON a.id = e.article_id
+             GROUP BY a.source_id`,
+			channelID,
+			time.Now().UTC(),
+			int64(window.Seconds()),
+			expireBatch,
+		); err != nil {
+			return nil, err
+		}
+
+		batch := 0
+		for _, c := range counts {
+			expired[c.SourceID] += c.Count
+			batch += c.Count
+		}
+
+		if batch < expireBatch {
+			return expired, nil
+		}
+	}
+}
+
+// ClaimForReview notes a post about to be sent to the moderation chat with the summary it is rendered with,
+// reports whether the post was claimed. A post already claimed by another instance or delivered is not claimed
+func (s *DeliveryPostgresStorage) ClaimForReview(ctx context.Context, articleID int64, channelID int64, summary string) (bool, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	res, err := conn.ExecContext(
+		ctx,
+		`INSERT INTO deliveries (article_id, channel_id, status, attempts, summary, updated_at)
+			VALUES ($1, $2, 'moderation', 0, $3, $4)
+			ON CONFLICT (article_id, channel_id) DO NOTHING`,
+		articleID,
+		channelID,
+		summary,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return inserted > 0, nil
+}
+
+// SetReviewMessage notes the message of the moderation chat a claimed post was sent as,
+// the moderation timeout counts from now on
+func (s *DeliveryPostgresStorage) SetReviewMessage(ctx context.Context, articleID int64, channelID int64, reviewMessageID int) error {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(
+		ctx,
+		`UPDATE deliveries SET (review_message_id, updated_at) = ($1, $2)
+			WHERE article_id = $3 AND channel_id = $4 AND status = 'moderation'`,
+		reviewMessageID,
+		time.Now().UTC(),
+		articleID,
+		channelID,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReleaseReview drops a claimed post that failed to reach the moderation chat, so it is claimed again later
+func (s *DeliveryPostgresStorage) ReleaseReview(ctx context.Context, articleID int64, channelID int64) error {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(
+		ctx,
+		`DELETE FROM deliveries
+			WHERE article_id = $1 AND channel_id = $2 AND status = 'moderation' AND review_message_id = 0`,
+		articleID,
+		channelID,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Review moves a post waiting for review to the status, reports whether the post was still waiting
+func (s *DeliveryPostgresStorage) Review(ctx context.Context, articleID int64, channelID int64, status string) (bool, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	var updated int64
+
+	if err := conn.GetContext(
+		ctx,
+		&updated,
+		`WITH changed AS (
+             UPDATE deliveries SET (status, updated_at) = ($1, $2)
+             WHERE article_id = $3 AND channel_id = $4 AND status = 'moderation'
+             RETURNING article_id, status
+         ), settled AS (
+             `+settleArticles("$2")+`
+         )
+         SELECT COUNT(*) FROM changed`,
+		status,
+		time.Now().UTC(),
+		articleID,
+		channelID,
+	); err != nil {
+		return false, err
+	}
+
+	return updated > 0, nil
+}
+
+// EditSummary replaces the summary of a post waiting for review, reports whether the post was still waiting
+func (s *DeliveryPostgresStorage) EditSummary(ctx context.Context, articleID int64, channelID int64, summary string) (bool, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	res, err := conn.ExecContext(
+		ctx,
+		`UPDATE deliveries SET summary = $1
+			WHERE article_id = $2 AND channel_id = $3 AND status = 'moderation'`,
+		summary,
+		articleID,
+		channelID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return updated > 0, nil
+}
+
+// ResolveReviews moves posts of the channel waiting for review since before to the status,
+// returns the number of resolved posts
+func (s *DeliveryPostgresStorage) ResolveReviews(ctx context.Context, channelID int64, before time.Time, status string) (int64, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var resolved int64
+
+	if err := conn.GetContext(
+		ctx,
+		&resolved,
+		`WITH changed AS (
+             UPDATE deliveries SET (status, updated_at) = ($1, $2)
+             WHERE channel_id = $3 AND status = 'moderation' AND updated_at < $4
+             RETURNING article_id, status
+         ), settled AS (
+             `+settleArticles("$2")+`
+         )
+         SELECT COUNT(*) FROM changed`,
+		status,
+		time.Now().UTC(),
+		channelID,
+		before,
+	); err != nil {
+		return 0, err
+	}
+
+	return resolved, nil
+}
+
+// Delivery selects the delivery of the article to the channel
+func (s *DeliveryPostgresStorage) Delivery(ctx context.Context, articleID int64, channelID int64) (*model.Delivery, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var delivery dbDelivery
+	if err := conn.GetContext(
+		ctx,
+		&delivery,
+		`SELECT d.id, d.article_id, d.channel_id, d.status, d.attempts, d.last_error, d.message_id,
+                d.next_attempt_at, d.posted_at, d.updated_at, d.summary, d.review_message_id,
+                COALESCE(a.title, '') AS article_title
+         FROM deliveries d
+         LEFT JOIN articles a ON a.id = d.article_id
+         WHERE d.article_id = $1 AND d.channel_id = $2`,
+		articleID,
+		channelID,
+	); err != nil {
+		return nil, err
+	}
+
+	result := delivery.toModel()
+
+	return &result, nil
+}
+
+// Dead returns dead deliveries with titles of their articles
+func (s *DeliveryPostgresStorage) Dead(ctx context.Context) ([]model.Delivery, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var deliveries []dbDelivery
+	if err := conn.SelectContext(
+		ctx,
+		&deliveries,
+		`SELECT d.id, d.article_id, d.channel_id, d.status, d.attempts, d.last_error, d.message_id,
+                d.next_attempt_at, d.posted_at, d.updated_at, COALESCE(a.title, '') AS article_title
+         FROM deliveries d
+         LEFT JOIN articles a ON a.id = d.article_id
+         WHERE d.status = 'dead'
+         ORDER BY d.updated_at DESC`,
+	); err != nil {
+		return nil, err
+	}
+
+	return lo.Map(deliveries, func(delivery dbDelivery, _ int) model.Delivery { return delivery.toModel() }), nil
+}
+
+// Requeue moves a dead delivery back to the queue with a fresh attempt counter,
+// reports whether the delivery was found
+func (s *DeliveryPostgresStorage) Requeue(ctx context.Context, id int64) (bool, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	now := time.Now().UTC()
+
+	res, err := conn.ExecContext(
+		ctx,
+		`UPDATE deliveries SET (status, attempts, next_attempt_at, updated_at) = ('failed', 0, $1, $1)
+			WHERE id = $2 AND status = 'dead'`,
+		now,
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return updated > 0, nil
+}
+
+type dbDelivery struct {
+	ID            int64        `db:"id"`
+	ArticleID     int64        `db:"article_id"`
+	ChannelID     int64        `db:"channel_id"`
+	Status        string       `db:"status"`
+	Attempts      int          `db:"attempts"`
+	LastError     string       `db:"last_error"`
+	MessageID     int          `db:"message_id"`
+	NextAttemptAt sql.NullTime `db:"next_attempt_at"`
+	PostedAt      sql.NullTime `db:"posted_at"`
+	UpdatedAt     time.Time    `db:"updated_at"`
+	Summary       string       `db:"summary"`
+	ReviewMessage int          `db:"review_message_id"`
+	ArticleTitle  string       `db:"article_title"`
+}
+
+func (d dbDelivery) toModel() model.Delivery {
+	return model.Delivery{
+		ID:            d.ID,
+		ArticleID:     d.ArticleID,
+		ChannelID:     d.ChannelID,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		LastError:     d.LastError,
+		MessageID:     d.MessageID,
+		NextAttemptAt: d.NextAttemptAt.Time,
+		PostedAt:      d.PostedAt.Time,
+		UpdatedAt:     d.UpdatedAt,
+		ArticleTitle:  d.ArticleTitle,
+
+		Summary:         d.Summary,
+		ReviewMessageID: d.ReviewMessage,
+	}
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/samber/lo"
+	"strings"
+	"time"
+)
+
+type ExtractionRulePostgresStorage struct {
+	db *sqlx.DB
+}
+
+func NewExtractionRuleStorage(db *sqlx.DB) *ExtractionRulePostgresStorage {
+	return &ExtractionRulePostgresStorage{db: db}
+}
+
+// Add saves the rule of the domain, an existing rule of the domain is replaced
+func (s *ExtractionRulePostgresStorage) Add(ctx context.Context, rule model.ExtractionRule) (int64, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var id int64
+
+	if err := conn.GetContext(
+		ctx,
+		&id,
+		`INSERT INTO extraction_rules (domain, content_selector, remove_selector, author_selector, date_selector)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (domain) DO UPDATE SET
+			    content_selector = EXCLUDED.content_selector,
+			    remove_selector = EXCLUDED.remove_selector,
+			    author_selector = EXCLUDED.author_selector,
+			    date_selector = EXCLUDED.date_selector,
+			    updated_at = $6
+			RETURNING id`,
+		rule.Domain,
+		rule.Content,
+		rule.Remove,
+		rule.Author,
+		rule.Date,
+		time.Now().UTC(),
+	); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Delete deletes the rule of the domain, reports whether there was one
+func (s *ExtractionRulePostgresStorage) Delete(ctx context.Context, domain string) (bool, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	res, err := conn.ExecContext(ctx, `DELETE FROM extraction_rules WHERE domain = $1`, domain)
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
+
+// Rules returns all rules
+func (s *ExtractionRulePostgresStorage) Rules(ctx context.Context) ([]model.ExtractionRule, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var rules []dbExtractionRule
+	if err := conn.SelectContext(ctx, &rules, `SELECT `+extractionRuleColumns+` FROM extraction_rules ORDER BY domain`); err != nil {
+		return nil, err
+	}
+
+	return lo.Map(rules, func(rule dbExtractionRule, _ int) model.ExtractionRule { return rule.toModel() }), nil
+}
+
+// RuleForHost returns the rule of the host or of the closest parent domain, nil if there is none
+func (s *ExtractionRulePostgresStorage) RuleForHost(ctx context.Context, host string) (*model.ExtractionRule, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// news.example.com is matched by rules of news.example.com, example.com and com
+	var domains []string
+	for labels := strings.Split(strings.ToLower(host), "."); len(labels) > 0; labels = labels[1:] {
+		domains = append(domains, strings.Join(labels, "."))
+	}
+
+	var rule dbExtractionRule
+
+	err = conn.GetContext(
+		ctx,
+		&rule,
+		`SELECT `+extractionRuleColumns+` FROM extraction_rules WHERE domain = ANY($1) ORDER BY LENGTH(domain) DESC LIMIT 1`,
+		pq.StringArray(domains),
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := rule.toModel()
+
+	return &result, nil
+}
+
+const extractionRuleColumns = `id, domain, content_selector, remove_selector, author_selector, date_selector,
+                created_at, updated_at`
+
+type dbExtractionRule struct {
+	ID        int64     `db:"id"`
+	Domain    string    `db:"domain"`
+	Content   string    `db:"content_selector"`
+	Remove    string    `db:"remove_selector"`
+	Author    string    `db:"author_selector"`
+	Date      string    `db:"date_selector"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (r dbExtractionRule) toModel() model.ExtractionRule {
+	return model.ExtractionRule{
+		ID:        r.ID,
+		Domain:    r.Domain,
+		Content:   r.Content,
+		Remove:    r.Remove,
+		Author:    r.Author,
+		Date:      r.Date,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+	}
+}
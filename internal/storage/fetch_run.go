@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"time"
+)
+
+type FetchRunPostgresStorage struct {
+	db *sqlx.DB
+}
+
+func NewFetchRunStorage(db *sqlx.DB) *FetchRunPostgresStorage {
+	return &FetchRunPostgresStorage{db: db}
+}
+
+// Store saves the result of a single source fetch
+func (s *FetchRunPostgresStorage) Store(ctx context.Context, run model.FetchRun) error {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(
+		ctx,
+		`INSERT INTO fetch_runs (source_id, started_at, duration_ms, http_status, items_seen,
+                        items_new, items_duplicate, items_filtered, errors, last_error)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		run.SourceID,
+		run.StartedAt,
+		run.Duration.Milliseconds(),
+		run.HTTPStatus,
+		run.ItemsSeen,
+		run.ItemsNew,
+		run.ItemsDuplicate,
+		run.ItemsFiltered,
+		run.Errors,
+		run.LastError,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Stats summarizes fetch runs and articles of the source since the given time
+func (s *FetchRunPostgresStorage) Stats(ctx context.Context, sourceID int64, since time.Time) (model.SourceStats, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return model.SourceStats{}, err
+	}
+	defer conn.Close()
+
+	var runs dbFetchRunStats
+	if err := conn.GetContext(
+		ctx,
+		&runs,
+		`SELECT COUNT(*) AS fetches,
+                COUNT(*) FILTER (WHERE errors = 0 AND http_status BETWEEN 200 AND 299) AS fetches_ok,
+                COALESCE(SUM(items_new), 0) AS items_new
+         FROM fetch_runs
+         WHERE source_id = $1 AND started_at >= $2`,
+		sourceID,
+		since,
+	); err != nil {
+		return model.SourceStats{}, err
+	}
+
+	var articles dbArticleStats
+	if err := conn.GetContext(
+		ctx,
+		&articles,
+		`SELECT COUNT(*) AS articles,
+                COUNT(posted_at) AS articles_posted,
+                COUNT(*) FILTER (WHERE EXISTS (
+                    SELECT 1 FROM deliveries d WHERE d.article_id = articles.id AND d.status = 'expired'
+                )) AS articles_expired,
+                PERCENTILE_CONT(0.5) WITHIN GROUP (
+                    ORDER BY EXTRACT(EPOCH FROM posted_at - published_at)
+                ) FILTER (WHERE posted_at IS NOT NULL) AS median_delay
+         FROM articles
+         WHERE source_id = $1 AND created_at >= $2`,
+		sourceID,
+		since,
+	); err != nil {
+		return model.SourceStats{}, err
+	}
+
+	return model.SourceStats{
+		SourceID:       sourceID,
+		Since:          since,
+		Fetches:        runs.Fetches,
+		FetchesOK:      runs.FetchesOK,
+		ItemsNew:       runs.ItemsNew,
+		Articles:       articles.Articles,
+		ArticlesPosted: articles.ArticlesPosted,
+
+		ArticlesExpired: articles.ArticlesExpired,
+		MedianDelay:     time.Duration(articles.MedianDelay.Float64 * float64(time.Second)),
+	}, nil
+}
+
+type dbFetchRunStats struct {
+	Fetches   int `db:"fetches"`
+	FetchesOK int `db:"fetches_ok"`
+	ItemsNew  int `db:"items_new"`
+}
+
+type dbArticleStats struct {
+	Articles        int             `db:"articles"`
+	ArticlesPosted  int             `db:"articles_posted"`
+	ArticlesExpired int             `db:"articles_expired"`
+	MedianDelay     sql.NullFloat64 `db:"median_delay"`
+}
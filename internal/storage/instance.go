@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/samber/lo"
+	"time"
+)
+
+type InstancePostgresStorage struct {
+	db *sqlx.DB
+}
+
+func NewInstanceStorage(db *sqlx.DB) *InstancePostgresStorage {
+	return &InstancePostgresStorage{db: db}
+}
+
+// Heartbeat notes the instance alive with its current role and components
+func (s *InstancePostgresStorage) Heartbeat(ctx context.Context, instance model.Instance) error {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(
+		ctx,
+		`INSERT INTO instances (id, leader, components, started_at, leader_since, heartbeat_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (id) DO UPDATE
+			SET leader = EXCLUDED.leader, components = EXCLUDED.components, started_at = EXCLUDED.started_at,
+			    leader_since = EXCLUDED.leader_since, heartbeat_at = EXCLUDED.heartbeat_at`,
+		instance.ID,
+		instance.Leader,
+		pq.StringArray(lo.Ternary(instance.Components != nil, instance.Components, []string{})),
+		instance.StartedAt,
+		sql.NullTime{Time: instance.LeaderSince, Valid: !instance.LeaderSince.IsZero()},
+		time.Now().UTC(),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Instances returns instances with a heartbeat since seenSince, the leader goes first
+func (s *InstancePostgresStorage) Instances(ctx context.Context, seenSince time.Time) ([]model.Instance, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var instances []dbInstance
+	if err := conn.SelectContext(
+		ctx,
+		&instances,
+		`SELECT id, leader, components, started_at, leader_since, heartbeat_at FROM instances
+			WHERE heartbeat_at >= $1
+			ORDER BY leader DESC, started_at`,
+		seenSince,
+	); err != nil {
+		return nil, err
+	}
+
+	return lo.Map(instances, func(instance dbInstance, _ int) model.Instance { return instance.toModel() }), nil
+}
+
+// LeaderLock is a session level advisory lock, it is held by a dedicated connection while the connection lives.
+// Postgres releases the lock when the session of a crashed instance ends
+type LeaderLock struct {
+	db   *sqlx.DB
+	key  int64
+	conn *sqlx.Conn
+}
+
+func NewLeaderLock(db *sqlx.DB, key int64) *LeaderLock {
+	return &LeaderLock{db: db, key: key}
+}
+
+// TryAcquire takes the lock if it is free, reports whether the lock was taken
+func (l *LeaderLock) TryAcquire(ctx context.Context) (bool, error) {
+	conn, err := l.db.Connx(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var acquired bool
+	if err := conn.GetContext(ctx, &acquired, `SELECT pg_try_advisory_lock($1)`, l.key); err != nil || !acquired {
+		conn.Close()
+		return false, err
+	}
+
+	l.conn = conn
+
+	return true, nil
+}
+
+// Check makes sure the session holding the lock is still alive, the lock is lost otherwise
+func (l *LeaderLock) Check(ctx context.Context) error {
+	if l.conn == nil {
+		return sql.ErrConnDone
+	}
+
+	// the lock lives as long as the session, a query that goes through proves both are there
+	if _, err := l.conn.ExecContext(ctx, `SELECT 1`); err != nil {
+		l.conn.Close()
+		l.conn = nil
+		return err
+	}
+
+	return nil
+}
+
+// Release unlocks the lock and closes the session holding it
+func (l *LeaderLock) Release(ctx context.Context) error {
+	if l.conn == nil {
+		return nil
+	}
+
+	defer func() {
+		l.conn.Close()
+		l.conn = nil
+	}()
+
+	_, err := l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, l.key)
+
+	return err
+}
+
+type dbInstance struct {
+	ID          string         `db:"id"`
+	Leader      bool           `db:"leader"`
+	Components  pq.StringArray `db:"components"`
+	StartedAt   time.Time      `db:"started_at"`
+	LeaderSince sql.NullTime   `db:"leader_since"`
+	HeartbeatAt time.Time      `db:"heartbeat_at"`
+}
+
+func (i dbInstance) toModel() model.Instance {
+	return model.Instance{
+		ID:          i.ID,
+		Leader:      i.Leader,
+		Components:  i.Components,
+		StartedAt:   i.StartedAt,
+		LeaderSince: i.LeaderSince.Time,
+		HeartbeatAt: i.HeartbeatAt,
+	}
+}
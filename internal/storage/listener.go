@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"github.com/lib/pq"
+	"github.com/lostmyescape/news-tg-bot/logger"
+	"time"
+)
+
+const (
+	listenerMinReconnect = time.Second
+	listenerMaxReconnect = time.Minute
+	// listenerPingInterval is how often an idle listener checks its connection,
+	// a dead connection is noticed and reestablished on the next ping
+	listenerPingInterval = 90 * time.Second
+)
+
+// ArticleListener listens to notifications of articles on a channel, ArticlesInserted or ArticlesEnriched,
+// on its own connection. The connection is reestablished if it drops
+type ArticleListener struct {
+	dsn     string
+	channel string
+}
+
+func NewArticleListener(dsn string, channel string) *ArticleListener {
+	return &ArticleListener{dsn: dsn, channel: channel}
+}
+
+// Notified returns a channel that receives a value when the listened channel is notified, notifications that come
+// while the previous one is not received yet are merged. A value is also sent after a reconnect,
+// as notifications sent meanwhile are lost. The channel is closed when the context is done
+func (l *ArticleListener) Notified(ctx context.Context) <-chan struct{} {
+	listener := pq.NewListener(l.dsn, listenerMinReconnect, listenerMaxReconnect, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			logger.Log.Warnw("storage: articles listener disconnected", "channel", l.channel, "err", err)
+		case pq.ListenerEventReconnected:
+			logger.Log.Infow("storage: articles listener reconnected", "channel", l.channel)
+		case pq.ListenerEventConnectionAttemptFailed:
+			logger.Log.Warnw("storage: articles listener failed to connect", "channel", l.channel, "err", err)
+		}
+	})
+
+	notified := make(chan struct{}, 1)
+
+	// listening waits for the first connection, notifications start coming once it's established
+	go func() {
+		if err := listener.Listen(l.channel); err != nil && ctx.Err() == nil {
+			logger.Log.Errorw("storage: failed to listen to articles", "channel", l.channel, "err", err)
+		}
+	}()
+
+	go func() {
+		defer close(notified)
+		defer listener.Close()
+
+		ticker := time.NewTicker(listenerPingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-listener.Notify:
+				// a nil notification after a reconnect is passed on too
+				select {
+				case notified <- struct{}{}:
+				default:
+				}
+			case <-ticker.C:
+				go func() {
+					_ = listener.Ping()
+				}()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return notified
+}
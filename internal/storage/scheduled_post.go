@@ -0,0 +1,324 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/lostmyescape/news-tg-bot/internal/botkit/markup"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/samber/lo"
+	"time"
+)
+
+type ScheduledPostPostgresStorage struct {
+	db *sqlx.DB
+}
+
+func NewScheduledPostStorage(db *sqlx.DB) *ScheduledPostPostgresStorage {
+	return &ScheduledPostPostgresStorage{db: db}
+}
+
+const selectScheduledPosts = `SELECT p.*, c.name AS channel_name, c.chat_id AS channel_chat_id, c.timezone AS channel_timezone
+         FROM scheduled_posts p
+         JOIN channels c ON c.id = p.channel_id`
+
+// Add stores a draft of the post and returns its id
+func (s *ScheduledPostPostgresStorage) Add(ctx context.Context, post model.ScheduledPost) (int64, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var id int64
+
+	row := conn.QueryRowxContext(
+		ctx,
+		`INSERT INTO scheduled_posts (channel_id, author_id, text, entities, media_type, media_file_id, publish_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id`,
+		post.ChannelID,
+		post.AuthorID,
+		post.Content.Text,
+		dbEntities(post.Content.Entities),
+		post.MediaType,
+		post.MediaFileID,
+		sql.NullTime{Time: post.PublishAt, Valid: !post.PublishAt.IsZero()},
+	)
+
+	if err := row.Err(); err != nil {
+		return 0, err
+	}
+
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// ScheduledPost selects the post by id
+func (s *ScheduledPostPostgresStorage) ScheduledPost(ctx context.Context, id int64) (*model.ScheduledPost, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var post dbScheduledPost
+	if err := conn.GetContext(ctx, &post, selectScheduledPosts+` WHERE p.id = $1`, id); err != nil {
+		return nil, err
+	}
+
+	result := post.toModel()
+
+	return &result, nil
+}
+
+// Schedule moves a draft to the queue to be published at publishAt, reports whether the post was a draft
+func (s *ScheduledPostPostgresStorage) Schedule(ctx context.Context, id int64, publishAt time.Time) (bool, error) {
+	return s.update(
+		ctx,
+		`UPDATE scheduled_posts SET (status, publish_at, updated_at) = ('scheduled', $1, $2)
+			WHERE id = $3 AND status = 'draft'`,
+		publishAt,
+		time.Now().UTC(),
+		id,
+	)
+}
+
+// Cancel cancels a post that is not published yet, reports whether the post was cancelled
+func (s *ScheduledPostPostgresStorage) Cancel(ctx context.Context, id int64) (bool, error) {
+	return s.update(
+		ctx,
+		`UPDATE scheduled_posts SET (status, updated_at) = ('cancelled', $1)
+			WHERE id = $2 AND status IN ('draft', 'scheduled', 'failed')`,
+		time.Now().UTC(),
+		id,
+	)
+}
+
+// Pending returns up to limit posts that are not published yet in the order of publishing
+func (s *ScheduledPostPostgresStorage) Pending(ctx context.Context, limit int) ([]model.ScheduledPost, error) {
+	return s.selectPosts(
+		ctx,
+		selectScheduledPosts+`
+         WHERE p.status IN ('draft', 'scheduled', 'sending', 'failed')
+         ORDER BY p.publish_at NULLS LAST, p.id
+         LIMIT $1`,
+		limit,
+	)
+}
+
+// Due returns posts to publish: scheduled ones whose time has come, failed ones due for a retry
+// and ones left in sending by an instance whose lease has expired
+func (s *ScheduledPostPostgresStorage) Due(ctx context.Context) ([]model.ScheduledPost, error) {
+	return s.selectPosts(
+		ctx,
+		selectScheduledPosts+`
+         WHERE (p.status = 'scheduled' AND p.publish_at <= $1)
+            OR (p.status = 'failed' AND p.next_attempt_at <= $1)
+            OR (p.status = 'sending' AND p.leased_until < $1)
+         ORDER BY p.publish_at`,
+		time.Now().UTC(),
+	)
+}
+
+// Lease claims the post for a publishing attempt until leasedUntil and returns the attempt number.
+// false is returned if another instance holds the post or it is not due anymore, e.g. cancelled meanwhile
+func (s *ScheduledPostPostgresStorage) Lease(ctx context.Context, id int64, leasedUntil time.Time) (int, bool, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer conn.Close()
+
+	var attempts int
+
+	if err := conn.GetContext(
+		ctx,
+		&attempts,
+		`UPDATE scheduled_posts SET (status, attempts, leased_until, updated_at) = ('sending', attempts + 1, $1, $2)
+			WHERE id = (
+				SELECT id FROM scheduled_posts
+				WHERE id = $3
+				  AND (status IN ('scheduled', 'failed') OR (status = 'sending' AND leased_until < $2))
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING attempts`,
+		leasedUntil,
+		time.Now().UTC(),
+		id,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	return attempts, true, nil
+}
+
+// MarkSent notes the post published in the message
+func (s *ScheduledPostPostgresStorage) MarkSent(ctx context.Context, id int64, messageID int) error {
+	now := time.Now().UTC()
+
+	_, err := s.update(
+		ctx,
+		`UPDATE scheduled_posts SET (status, message_id, last_error, next_attempt_at, leased_until, posted_at, updated_at) =
+			('sent', $1, '', NULL, NULL, $2, $2)
+			WHERE id = $3`,
+		messageID,
+		now,
+		id,
+	)
+
+	return err
+}
+
+// MarkFailed notes a failed attempt, the post is retried after nextAttemptAt
+func (s *ScheduledPostPostgresStorage) MarkFailed(ctx context.Context, id int64, lastError string, nextAttemptAt time.Time) error {
+	_, err := s.update(
+		ctx,
+		`UPDATE scheduled_posts SET (status, last_error, next_attempt_at, leased_until, updated_at) = ('failed', $1, $2, NULL, $3)
+			WHERE id = $4`,
+		lastError,
+		nextAttemptAt,
+		time.Now().UTC(),
+		id,
+	)
+
+	return err
+}
+
+// MarkDead notes a post that will not be retried
+func (s *ScheduledPostPostgresStorage) MarkDead(ctx context.Context, id int64, lastError string) error {
+	_, err := s.update(
+		ctx,
+		`UPDATE scheduled_posts SET (status, last_error, next_attempt_at, leased_until, updated_at) = ('dead', $1, NULL, NULL, $2)
+			WHERE id = $3`,
+		lastError,
+		time.Now().UTC(),
+		id,
+	)
+
+	return err
+}
+
+func (s *ScheduledPostPostgresStorage) update(ctx context.Context, query string, args ...any) (bool, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	res, err := conn.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return updated > 0, nil
+}
+
+func (s *ScheduledPostPostgresStorage) selectPosts(ctx context.Context, query string, args ...any) ([]model.ScheduledPost, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var posts []dbScheduledPost
+	if err := conn.SelectContext(ctx, &posts, query, args...); err != nil {
+		return nil, err
+	}
+
+	return lo.Map(posts, func(post dbScheduledPost, _ int) model.ScheduledPost { return post.toModel() }), nil
+}
+
+type dbScheduledPost struct {
+	ID            int64        `db:"id"`
+	ChannelID     int64        `db:"channel_id"`
+	AuthorID      int64        `db:"author_id"`
+	Text          string       `db:"text"`
+	Entities      dbEntities   `db:"entities"`
+	MediaType     string       `db:"media_type"`
+	MediaFileID   string       `db:"media_file_id"`
+	Status        string       `db:"status"`
+	Attempts      int          `db:"attempts"`
+	LastError     string       `db:"last_error"`
+	MessageID     int          `db:"message_id"`
+	PublishAt     sql.NullTime `db:"publish_at"`
+	NextAttemptAt sql.NullTime `db:"next_attempt_at"`
+	PostedAt      sql.NullTime `db:"posted_at"`
+	LeasedUntil   sql.NullTime `db:"leased_until"`
+	CreatedAt     time.Time    `db:"created_at"`
+	UpdatedAt     time.Time    `db:"updated_at"`
+	ChannelName   string       `db:"channel_name"`
+	ChannelChatID int64        `db:"channel_chat_id"`
+	Timezone      string       `db:"channel_timezone"`
+}
+
+func (p dbScheduledPost) toModel() model.ScheduledPost {
+	return model.ScheduledPost{
+		ID:          p.ID,
+		ChannelID:   p.ChannelID,
+		AuthorID:    p.AuthorID,
+		Content:     markup.Message{Text: p.Text, Entities: p.Entities},
+		MediaType:   p.MediaType,
+		MediaFileID: p.MediaFileID,
+		Status:      p.Status,
+		Attempts:    p.Attempts,
+		LastError:   p.LastError,
+		MessageID:   p.MessageID,
+		PublishAt:   p.PublishAt.Time,
+		PostedAt:    p.PostedAt.Time,
+		CreatedAt:   p.CreatedAt,
+
+		ChannelName: p.ChannelName,
+		ChatID:      p.ChannelChatID,
+		Timezone:    p.Timezone,
+	}
+}
+
+// dbEntities stores message entities as JSONB
+type dbEntities []tgbotapi.MessageEntity
+
+func (e dbEntities) Value() (driver.Value, error) {
+	if e == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(e)
+}
+
+func (e *dbEntities) Scan(src any) error {
+	var data []byte
+
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*e = nil
+		return nil
+	default:
+		return fmt.Errorf("unsupported entities type %T", src)
+	}
+
+	if err := json.Unmarshal(data, (*[]tgbotapi.MessageEntity)(e)); err != nil {
+		return fmt.Errorf("failed to decode entities: %w", err)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"database/sql"
 	"github.com/jmoiron/sqlx"
 	"github.com/lostmyescape/news-tg-bot/internal/model"
 	"github.com/samber/lo"
@@ -28,9 +29,13 @@ func (s *SourcePostgresStorage) Edit(ctx context.Context, source model.Source) (
 
 	row := conn.QueryRowContext(
 		ctx,
-		`UPDATE sources SET (name, feed_url) = ($1, $2) WHERE id = $3 RETURNING id`,
+		`UPDATE sources SET (name, feed_url, priority, template, freshness_window_seconds) = ($1, $2, $3, $4, $5)
+			WHERE id = $6 RETURNING id`,
 		source.Name,
 		source.FeedURL,
+		source.Priority,
+		source.Template,
+		int64(source.FreshnessWindow.Seconds()),
 		source.ID,
 	)
 
@@ -55,11 +60,63 @@ func (s *SourcePostgresStorage) Sources(ctx context.Context) ([]model.Source, er
 	defer conn.Close()
 
 	var sources []dbSource
-	if err := conn.SelectContext(ctx, &sources, `SELECT * FROM sources`); err != nil {
+	if err := conn.SelectContext(ctx, &sources, `SELECT `+sourceColumns+` FROM sources`); err != nil {
 		return nil, err
 	}
 
-	return lo.Map(sources, func(source dbSource, _ int) model.Source { return model.Source(source) }), nil
+	return lo.Map(sources, func(source dbSource, _ int) model.Source { return source.toModel() }), nil
+}
+
+// Lease claims sources not fetched since dueBefore until leasedUntil, sources held by other instances
+// are skipped. The lease of a crashed instance expires and the source is fetched by another one
+func (s *SourcePostgresStorage) Lease(ctx context.Context, dueBefore time.Time, leasedUntil time.Time) ([]model.Source, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var sources []dbSource
+
+	if err := conn.SelectContext(
+		ctx,
+		&sources,
+		`UPDATE sources SET leased_until = $1
+			WHERE id IN (
+				SELECT id FROM sources
+				WHERE (fetched_at IS NULL OR fetched_at <= $2)
+				  AND (leased_until IS NULL OR leased_until < $3)
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING `+sourceColumns,
+		leasedUntil,
+		dueBefore,
+		time.Now().UTC(),
+	); err != nil {
+		return nil, err
+	}
+
+	return lo.Map(sources, func(source dbSource, _ int) model.Source { return source.toModel() }), nil
+}
+
+// Release notes the source fetched at fetchedAt and lets other instances fetch it once it's due again
+func (s *SourcePostgresStorage) Release(ctx context.Context, id int64, fetchedAt time.Time) error {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(
+		ctx,
+		`UPDATE sources SET (fetched_at, leased_until) = ($1, NULL) WHERE id = $2`,
+		fetchedAt,
+		id,
+	); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 // SourceById selects source by id
@@ -71,11 +128,13 @@ func (s *SourcePostgresStorage) SourceById(ctx context.Context, id int64) (*mode
 	defer conn.Close()
 
 	var source dbSource
-	if err := conn.GetContext(ctx, &source, `SELECT * FROM sources WHERE id = $1`, id); err != nil {
+	if err := conn.GetContext(ctx, &source, `SELECT `+sourceColumns+` FROM sources WHERE id = $1`, id); err != nil {
 		return nil, err
 	}
 
-	return (*model.Source)(&source), nil
+	result := source.toModel()
+
+	return &result, nil
 
 }
 
@@ -92,10 +151,14 @@ func (s *SourcePostgresStorage) Add(ctx context.Context, source model.Source) (i
 
 	row := conn.QueryRowContext(
 		ctx,
-		`INSERT INTO sources (name, feed_url, created_at) VALUES ($1, $2, $3) RETURNING id`,
+		`INSERT INTO sources (name, feed_url, created_at, priority, template, freshness_window_seconds)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
 		source.Name,
 		source.FeedURL,
 		source.CreatedAt,
+		source.Priority,
+		source.Template,
+		int64(source.FreshnessWindow.Seconds()),
 	)
 
 	if err := row.Err(); err != nil {
@@ -125,10 +188,33 @@ func (s *SourcePostgresStorage) Delete(ctx context.Context, id int64) (int64, er
 	return id, nil
 }
 
+const sourceColumns = `id, name, feed_url, created_at, updated_at, priority, template, freshness_window_seconds,
+                fetched_at, leased_until`
+
 type dbSource struct {
 	ID        int64     `db:"id"`
 	Name      string    `db:"name"`
 	FeedURL   string    `db:"feed_url"`
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
+	Priority  int       `db:"priority"`
+	Template  string    `db:"template"`
+
+	FreshnessWindowSeconds int64 `db:"freshness_window_seconds"`
+
+	FetchedAt   sql.NullTime `db:"fetched_at"`
+	LeasedUntil sql.NullTime `db:"leased_until"`
+}
+
+func (s dbSource) toModel() model.Source {
+	return model.Source{
+		ID:              s.ID,
+		Name:            s.Name,
+		FeedURL:         s.FeedURL,
+		CreatedAt:       s.CreatedAt,
+		UpdatedAt:       s.UpdatedAt,
+		Priority:        s.Priority,
+		Template:        s.Template,
+		FreshnessWindow: time.Duration(s.FreshnessWindowSeconds) * time.Second,
+	}
 }
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"github.com/samber/lo"
+	"time"
+)
+
+type SubscriberPostgresStorage struct {
+	db *sqlx.DB
+}
+
+func NewSubscriberStorage(db *sqlx.DB) *SubscriberPostgresStorage {
+	return &SubscriberPostgresStorage{db: db}
+}
+
+// Add subscribes the email to digests, an existing subscriber gets the new frequency
+func (s *SubscriberPostgresStorage) Add(ctx context.Context, subscriber model.Subscriber) (int64, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var id int64
+
+	if err := conn.GetContext(
+		ctx,
+		&id,
+		`INSERT INTO subscribers (email, frequency) VALUES ($1, $2)
+			ON CONFLICT (email) DO UPDATE SET frequency = EXCLUDED.frequency
+			RETURNING id`,
+		subscriber.Email,
+		subscriber.Frequency,
+	); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Delete unsubscribes the email, reports whether it was subscribed
+func (s *SubscriberPostgresStorage) Delete(ctx context.Context, email string) (bool, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	res, err := conn.ExecContext(ctx, `DELETE FROM subscribers WHERE email = $1`, email)
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
+
+// Subscribers returns all subscribers
+func (s *SubscriberPostgresStorage) Subscribers(ctx context.Context) ([]model.Subscriber, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var subscribers []dbSubscriber
+	if err := conn.SelectContext(ctx, &subscribers, `SELECT `+subscriberColumns+` FROM subscribers ORDER BY email`); err != nil {
+		return nil, err
+	}
+
+	return lo.Map(subscribers, func(subscriber dbSubscriber, _ int) model.Subscriber { return subscriber.toModel() }), nil
+}
+
+// MarkSent notes the time digests were sent to the subscribers
+func (s *SubscriberPostgresStorage) MarkSent(ctx context.Context, ids []int64, sentAt time.Time) error {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(
+		ctx,
+		`UPDATE subscribers SET last_sent_at = $1 WHERE id = ANY($2::BIGINT[])`,
+		sentAt,
+		pq.Array(ids),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+const subscriberColumns = `id, email, frequency, last_sent_at, created_at`
+
+type dbSubscriber struct {
+	ID         int64        `db:"id"`
+	Email      string       `db:"email"`
+	Frequency  string       `db:"frequency"`
+	LastSentAt sql.NullTime `db:"last_sent_at"`
+	CreatedAt  time.Time    `db:"created_at"`
+}
+
+func (s dbSubscriber) toModel() model.Subscriber {
+	return model.Subscriber{
+		ID:         s.ID,
+		Email:      s.Email,
+		Frequency:  s.Frequency,
+		LastSentAt: s.LastSentAt.Time,
+		CreatedAt:  s.CreatedAt,
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/lostmyescape/news-tg-bot/internal/model"
+	"time"
+)
+
+type VotePostgresStorage struct {
+	db *sqlx.DB
+}
+
+func NewVoteStorage(db *sqlx.DB) *VotePostgresStorage {
+	return &VotePostgresStorage{db: db}
+}
+
+// Vote records the vote of the user for the article, value is 1 or -1. Repeating the same vote takes it back,
+// reports whether the vote is in place afterwards
+func (s *VotePostgresStorage) Vote(ctx context.Context, articleID int64, userID int64, value int) (bool, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM votes WHERE article_id = $1 AND user_id = $2 AND value = $3`,
+		articleID,
+		userID,
+		value,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if deleted == 0 {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO votes (article_id, user_id, value, created_at) VALUES ($1, $2, $3, $4)
+				ON CONFLICT (article_id, user_id) DO UPDATE SET value = EXCLUDED.value, created_at = EXCLUDED.created_at`,
+			articleID,
+			userID,
+			value,
+			time.Now().UTC(),
+		); err != nil {
+			return false, err
+		}
+	}
+
+	return deleted == 0, tx.Commit()
+}
+
+// Votes counts votes for the article
+func (s *VotePostgresStorage) Votes(ctx context.Context, articleID int64) (model.Votes, error) {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return model.Votes{}, err
+	}
+	defer conn.Close()
+
+	var votes dbVotes
+	if err := conn.GetContext(
+		ctx,
+		&votes,
+		`SELECT COUNT(*) FILTER (WHERE value > 0) AS up, COUNT(*) FILTER (WHERE value < 0) AS down
+         FROM votes WHERE article_id = $1`,
+		articleID,
+	); err != nil {
+		return model.Votes{}, err
+	}
+
+	return model.Votes(votes), nil
+}
+
+type dbVotes struct {
+	Up   int `db:"up"`
+	Down int `db:"down"`
+}
@@ -2,44 +2,81 @@ package summary
 
 import (
 	"context"
+	"errors"
 	"github.com/lostmyescape/news-tg-bot/logger"
 	"github.com/sashabaranov/go-openai"
+	"math"
 	"strings"
-	"sync"
+	"time"
 )
 
+// errNoSummary is returned when the model answers without a summary, the request is worth retrying
+var errNoSummary = errors.New("openai: response has no summary")
+
 type OpenAISummarizer struct {
-	client  *openai.Client
-	prompt  string
-	enabled bool
-	mu      sync.Mutex
+	client      *openai.Client
+	prompt      string
+	model       string
+	maxTokens   int
+	temperature float32
+	timeout     time.Duration
+	enabled     bool
 }
 
-func NewOpenAiSummarizer(apiKey string, prompt string) *OpenAISummarizer {
+// NewOpenAiSummarizer creates a summarizer of the OpenAI chat completions API or a compatible server,
+// such as Ollama or llama.cpp, if baseURL is set. A self-hosted server needs no api key
+func NewOpenAiSummarizer(
+	apiKey string,
+	baseURL string,
+	organization string,
+	model string,
+	prompt string,
+	maxTokens int,
+	temperature float64,
+	timeout time.Duration,
+) *OpenAISummarizer {
+	config := openai.DefaultConfig(apiKey)
+	config.OrgID = organization
+
+	if baseURL != "" {
+		config.BaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+
+	// the client omits a zero temperature and the server falls back to its default, usually 1,
+	// the smallest positive value is sent instead and works as zero
+	if temperature == 0 {
+		temperature = math.SmallestNonzeroFloat32
+	}
+
 	s := &OpenAISummarizer{
-		client:  openai.NewClient(apiKey),
-		prompt:  prompt,
-		enabled: apiKey != "",
+		client:      openai.NewClientWithConfig(config),
+		prompt:      prompt,
+		model:       model,
+		maxTokens:   maxTokens,
+		temperature: float32(temperature),
+		timeout:     timeout,
+		enabled:     apiKey != "" || baseURL != "",
 	}
 
-	logger.Log.Infow("openai summarizer created", "enabled", s.enabled)
+	logger.Log.Infow("openai summarizer created", "enabled", s.enabled, "base_url", config.BaseURL, "model", model)
 
 	return s
 }
 
-// Summarize sends text to openai and receives a summary of that text
+// Summarize sends text to openai and receives a summary of that text, it's safe for concurrent use
 func (s *OpenAISummarizer) Summarize(ctx context.Context, text string) (string, error) {
-	// race conditions
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	if !s.enabled {
 		return "", nil
 	}
 
-	//
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
 	request := openai.ChatCompletionRequest{
-		Model: "gpt-3.5-turbo",
+		Model: s.model,
 		Messages: []openai.ChatCompletionMessage{
 			{
 				Role:    openai.ChatMessageRoleSystem,
@@ -50,8 +87,8 @@ func (s *OpenAISummarizer) Summarize(ctx context.Context, text string) (string,
 				Content: text,
 			},
 		},
-		MaxTokens:   256,
-		Temperature: 0.7,
+		MaxTokens:   s.maxTokens,
+		Temperature: s.temperature,
 		TopP:        1,
 	}
 
@@ -60,7 +97,16 @@ func (s *OpenAISummarizer) Summarize(ctx context.Context, text string) (string,
 		return "", err
 	}
 
+	// servers answer with no choices on filtered content and some errors
+	if len(resp.Choices) == 0 {
+		return "", errNoSummary
+	}
+
 	rawSummary := strings.TrimSpace(resp.Choices[0].Message.Content)
+	if rawSummary == "" {
+		return "", errNoSummary
+	}
+
 	if !strings.HasSuffix(rawSummary, ".") {
 		rawSummary += "."
 	}
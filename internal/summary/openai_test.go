@@ -0,0 +1,121 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/lostmyescape/news-tg-bot/logger"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop().Sugar()
+	os.Exit(m.Run())
+}
+
+// completionStub answers chat completion requests with the choices after the delay and records the last request
+func completionStub(t *testing.T, choices []map[string]any, delay time.Duration) (*httptest.Server, *map[string]any) {
+	t.Helper()
+
+	var request map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("request to %s, want /v1/chat/completions", r.URL.Path)
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": "1", "object": "chat.completion", "choices": choices})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &request
+}
+
+func choice(content string) map[string]any {
+	return map[string]any{"index": 0, "message": map[string]any{"role": "assistant", "content": content}}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name    string
+		choices []map[string]any
+		delay   time.Duration
+		want    string
+		wantErr error
+	}{
+		{name: "summary", choices: []map[string]any{choice("  Вышел Go 1.25 ")}, want: "Вышел Go 1.25."},
+		{name: "summary with a period", choices: []map[string]any{choice("Вышел Go.")}, want: "Вышел Go."},
+		{name: "no choices", choices: []map[string]any{}, wantErr: errNoSummary},
+		{name: "empty content", choices: []map[string]any{choice(" \n")}, wantErr: errNoSummary},
+		{name: "timeout", choices: []map[string]any{choice("late")}, delay: 500 * time.Millisecond, wantErr: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, request := completionStub(t, tt.choices, tt.delay)
+
+			// a self-hosted server needs no key, the base url may end with a slash
+			s := NewOpenAiSummarizer("", server.URL+"/v1/", "", "llama3", "Перескажи", 100, 0.2, 100*time.Millisecond)
+
+			got, err := s.Summarize(context.Background(), "text")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Summarize() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Summarize() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Summarize() = %q, want %q", got, tt.want)
+			}
+
+			if (*request)["model"] != "llama3" || (*request)["max_tokens"] != 100.0 {
+				t.Errorf("request model %v, max tokens %v, want llama3, 100", (*request)["model"], (*request)["max_tokens"])
+			}
+		})
+	}
+}
+
+func TestSummarizeZeroTemperature(t *testing.T) {
+	server, request := completionStub(t, []map[string]any{choice("summary")}, 0)
+
+	s := NewOpenAiSummarizer("key", server.URL+"/v1", "", "gpt-4o-mini", "", 100, 0, time.Second)
+
+	if _, err := s.Summarize(context.Background(), "text"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a zero temperature dropped from the request means the default one of the server
+	temperature, ok := (*request)["temperature"].(float64)
+	if !ok || temperature <= 0 || temperature > 1e-6 {
+		t.Errorf("request temperature = %v, want a value near zero", (*request)["temperature"])
+	}
+}
+
+func TestSummarizeDisabled(t *testing.T) {
+	s := NewOpenAiSummarizer("", "", "", "gpt-4o-mini", "", 100, 0.7, time.Second)
+
+	if got, err := s.Summarize(context.Background(), "text"); got != "" || err != nil {
+		t.Errorf("Summarize() = %q, %v, want no summary without a key or a server", got, err)
+	}
+}
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE fetch_runs (
+                            id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+                            source_id INT NOT NULL,
+                            started_at TIMESTAMP NOT NULL,
+                            duration_ms BIGINT NOT NULL,
+                            http_status INT NOT NULL DEFAULT 0,
+                            items_seen INT NOT NULL DEFAULT 0,
+                            items_new INT NOT NULL DEFAULT 0,
+                            items_duplicate INT NOT NULL DEFAULT 0,
+                            items_filtered INT NOT NULL DEFAULT 0,
+                            errors INT NOT NULL DEFAULT 0,
+                            last_error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX fetch_runs_source_id_started_at_idx ON fetch_runs (source_id, started_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS fetch_runs;
@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE channels (
+                          id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+                          name TEXT NOT NULL,
+                          chat_id BIGINT NOT NULL UNIQUE,
+                          posting_interval_seconds BIGINT NOT NULL DEFAULT 60,
+                          created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE source_channels (
+                                 source_id BIGINT NOT NULL REFERENCES sources (id) ON DELETE CASCADE,
+                                 channel_id BIGINT NOT NULL REFERENCES channels (id) ON DELETE CASCADE,
+                                 PRIMARY KEY (source_id, channel_id)
+);
+
+CREATE TABLE deliveries (
+                            article_id BIGINT NOT NULL,
+                            channel_id BIGINT NOT NULL REFERENCES channels (id) ON DELETE CASCADE,
+                            posted_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                            PRIMARY KEY (article_id, channel_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS deliveries;
+DROP TABLE IF EXISTS source_channels;
+DROP TABLE IF EXISTS channels;
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE channels
+    ADD COLUMN strategy TEXT NOT NULL DEFAULT 'newest'
+        CHECK (strategy IN ('newest', 'oldest', 'round_robin', 'weighted')),
+    ADD COLUMN max_posts_per_source_per_hour INT NOT NULL DEFAULT 0;
+
+ALTER TABLE sources
+    ADD COLUMN priority INT NOT NULL DEFAULT 1 CHECK (priority >= 0);
+
+-- +goose Down
+ALTER TABLE sources DROP COLUMN IF EXISTS priority;
+
+ALTER TABLE channels
+    DROP COLUMN IF EXISTS max_posts_per_source_per_hour,
+    DROP COLUMN IF EXISTS strategy;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE channels
+    ADD COLUMN schedule TEXT NOT NULL DEFAULT '',
+    ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+
+-- +goose Down
+ALTER TABLE channels
+    DROP COLUMN IF EXISTS timezone,
+    DROP COLUMN IF EXISTS schedule;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE channels
+    ADD COLUMN mode TEXT NOT NULL DEFAULT 'stream' CHECK (mode IN ('stream', 'digest')),
+    ADD COLUMN digest_times TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE channels
+    DROP COLUMN IF EXISTS digest_times,
+    DROP COLUMN IF EXISTS mode;
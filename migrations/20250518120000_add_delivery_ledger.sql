@@ -0,0 +1,32 @@
+-- +goose Up
+ALTER TABLE deliveries
+    ADD COLUMN id BIGINT GENERATED ALWAYS AS IDENTITY UNIQUE,
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'sent'
+        CHECK (status IN ('pending', 'sending', 'sent', 'failed', 'dead')),
+    ADD COLUMN attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN last_error TEXT NOT NULL DEFAULT '',
+    ADD COLUMN message_id BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN next_attempt_at TIMESTAMP,
+    ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    ALTER COLUMN posted_at DROP NOT NULL,
+    ALTER COLUMN posted_at DROP DEFAULT;
+
+ALTER TABLE deliveries ALTER COLUMN status SET DEFAULT 'pending';
+
+CREATE INDEX deliveries_channel_id_status_idx ON deliveries (channel_id, status);
+
+-- +goose Down
+DROP INDEX IF EXISTS deliveries_channel_id_status_idx;
+
+DELETE FROM deliveries WHERE status <> 'sent';
+
+ALTER TABLE deliveries
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS next_attempt_at,
+    DROP COLUMN IF EXISTS message_id,
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS attempts,
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS id,
+    ALTER COLUMN posted_at SET DEFAULT NOW(),
+    ALTER COLUMN posted_at SET NOT NULL;
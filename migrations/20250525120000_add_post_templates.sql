@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE articles
+    ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN language TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE channels
+    ADD COLUMN template TEXT NOT NULL DEFAULT '',
+    ADD COLUMN format TEXT NOT NULL DEFAULT 'MarkdownV2' CHECK (format IN ('MarkdownV2', 'HTML'));
+
+ALTER TABLE sources
+    ADD COLUMN template TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE sources DROP COLUMN IF EXISTS template;
+
+ALTER TABLE channels
+    DROP COLUMN IF EXISTS format,
+    DROP COLUMN IF EXISTS template;
+
+ALTER TABLE articles
+    DROP COLUMN IF EXISTS language,
+    DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE articles ADD COLUMN comments_url TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE channels ADD COLUMN feedback_buttons BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE votes (
+                       article_id BIGINT NOT NULL REFERENCES articles (id) ON DELETE CASCADE,
+                       user_id BIGINT NOT NULL,
+                       value SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+                       created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                       PRIMARY KEY (article_id, user_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS votes;
+
+ALTER TABLE channels DROP COLUMN IF EXISTS feedback_buttons;
+
+ALTER TABLE articles DROP COLUMN IF EXISTS comments_url;
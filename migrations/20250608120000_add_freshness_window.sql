@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE sources ADD COLUMN freshness_window_seconds BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE deliveries
+    DROP CONSTRAINT deliveries_status_check,
+    ADD CONSTRAINT deliveries_status_check
+        CHECK (status IN ('pending', 'sending', 'sent', 'failed', 'dead', 'expired'));
+
+-- +goose Down
+DELETE FROM deliveries WHERE status = 'expired';
+
+ALTER TABLE deliveries
+    DROP CONSTRAINT deliveries_status_check,
+    ADD CONSTRAINT deliveries_status_check
+        CHECK (status IN ('pending', 'sending', 'sent', 'failed', 'dead'));
+
+ALTER TABLE sources DROP COLUMN IF EXISTS freshness_window_seconds;
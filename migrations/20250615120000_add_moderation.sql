@@ -0,0 +1,28 @@
+-- +goose Up
+ALTER TABLE channels
+    ADD COLUMN moderation_chat_id BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN moderation_timeout_seconds BIGINT NOT NULL DEFAULT 3600,
+    ADD COLUMN moderation_timeout_action TEXT NOT NULL DEFAULT 'expire'
+        CHECK (moderation_timeout_action IN ('approve', 'expire'));
+
+ALTER TABLE deliveries
+    ADD COLUMN summary TEXT NOT NULL DEFAULT '',
+    ADD COLUMN review_message_id BIGINT NOT NULL DEFAULT 0,
+    DROP CONSTRAINT deliveries_status_check,
+    ADD CONSTRAINT deliveries_status_check
+        CHECK (status IN ('pending', 'sending', 'sent', 'failed', 'dead', 'expired', 'moderation', 'approved', 'rejected'));
+
+-- +goose Down
+DELETE FROM deliveries WHERE status IN ('moderation', 'approved', 'rejected');
+
+ALTER TABLE deliveries
+    DROP CONSTRAINT deliveries_status_check,
+    ADD CONSTRAINT deliveries_status_check
+        CHECK (status IN ('pending', 'sending', 'sent', 'failed', 'dead', 'expired')),
+    DROP COLUMN IF EXISTS review_message_id,
+    DROP COLUMN IF EXISTS summary;
+
+ALTER TABLE channels
+    DROP COLUMN IF EXISTS moderation_timeout_action,
+    DROP COLUMN IF EXISTS moderation_timeout_seconds,
+    DROP COLUMN IF EXISTS moderation_chat_id;
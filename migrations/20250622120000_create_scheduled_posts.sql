@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE scheduled_posts (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    channel_id BIGINT NOT NULL REFERENCES channels (id) ON DELETE CASCADE,
+    author_id BIGINT NOT NULL,
+    text TEXT NOT NULL DEFAULT '',
+    entities JSONB NOT NULL DEFAULT '[]',
+    media_type TEXT NOT NULL DEFAULT ''
+        CHECK (media_type IN ('', 'photo', 'video', 'animation', 'document')),
+    media_file_id TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'scheduled', 'sending', 'sent', 'failed', 'dead', 'cancelled')),
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    message_id BIGINT NOT NULL DEFAULT 0,
+    publish_at TIMESTAMP,
+    next_attempt_at TIMESTAMP,
+    posted_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX scheduled_posts_status_publish_at_idx ON scheduled_posts (status, publish_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS scheduled_posts;
@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE channels
+    ADD COLUMN publisher TEXT NOT NULL DEFAULT 'telegram'
+        CHECK (publisher IN ('telegram', 'discord', 'slack', 'webhook')),
+    ADD COLUMN webhook_url TEXT NOT NULL DEFAULT '',
+    DROP CONSTRAINT channels_chat_id_key;
+
+-- webhook destinations have no telegram chat, chat ids are unique among telegram channels only
+CREATE UNIQUE INDEX channels_chat_id_key ON channels (chat_id) WHERE publisher = 'telegram';
+CREATE UNIQUE INDEX channels_webhook_url_key ON channels (webhook_url) WHERE publisher <> 'telegram';
+
+-- +goose Down
+DELETE FROM channels WHERE publisher <> 'telegram';
+
+DROP INDEX IF EXISTS channels_webhook_url_key;
+DROP INDEX IF EXISTS channels_chat_id_key;
+
+ALTER TABLE channels
+    DROP COLUMN IF EXISTS webhook_url,
+    DROP COLUMN IF EXISTS publisher,
+    ADD CONSTRAINT channels_chat_id_key UNIQUE (chat_id);
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE subscribers (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    frequency TEXT NOT NULL DEFAULT 'daily' CHECK (frequency IN ('daily', 'weekly')),
+    last_sent_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX articles_posted_at_idx ON articles (posted_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS articles_posted_at_idx;
+DROP TABLE IF EXISTS subscribers;
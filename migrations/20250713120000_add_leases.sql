@@ -0,0 +1,20 @@
+-- +goose Up
+-- an instance holds a delivery, a post or a source fetch until the lease expires,
+-- leases of a crashed instance expire and the work is picked up by another one
+ALTER TABLE deliveries ADD COLUMN leased_until TIMESTAMP;
+ALTER TABLE scheduled_posts ADD COLUMN leased_until TIMESTAMP;
+
+ALTER TABLE sources
+    ADD COLUMN fetched_at TIMESTAMP,
+    ADD COLUMN leased_until TIMESTAMP;
+
+UPDATE deliveries SET leased_until = updated_at + INTERVAL '10 minutes' WHERE status = 'sending';
+UPDATE scheduled_posts SET leased_until = updated_at + INTERVAL '10 minutes' WHERE status = 'sending';
+
+-- +goose Down
+ALTER TABLE sources
+    DROP COLUMN IF EXISTS leased_until,
+    DROP COLUMN IF EXISTS fetched_at;
+
+ALTER TABLE scheduled_posts DROP COLUMN IF EXISTS leased_until;
+ALTER TABLE deliveries DROP COLUMN IF EXISTS leased_until;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE instances (
+    id TEXT PRIMARY KEY,
+    leader BOOLEAN NOT NULL DEFAULT FALSE,
+    components TEXT[] NOT NULL DEFAULT '{}',
+    started_at TIMESTAMP NOT NULL,
+    leader_since TIMESTAMP,
+    heartbeat_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS instances;
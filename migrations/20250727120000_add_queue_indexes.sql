@@ -0,0 +1,11 @@
+-- +goose Up
+-- channel queues are read newest or oldest first and per source, mostly from articles not posted anywhere yet,
+-- articles posted to other channels are found by articles_posted_at_idx
+CREATE INDEX articles_unposted_published_at_idx ON articles (published_at) WHERE posted_at IS NULL;
+CREATE INDEX articles_unposted_source_id_published_at_idx ON articles (source_id, published_at) WHERE posted_at IS NULL;
+CREATE INDEX articles_source_id_posted_at_idx ON articles (source_id, posted_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS articles_source_id_posted_at_idx;
+DROP INDEX IF EXISTS articles_unposted_source_id_published_at_idx;
+DROP INDEX IF EXISTS articles_unposted_published_at_idx;
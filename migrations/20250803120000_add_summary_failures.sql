@@ -0,0 +1,12 @@
+-- +goose Up
+-- articles whose summary failed wait for summary_retry_at before they're picked again
+ALTER TABLE articles
+    ADD COLUMN summary_failures INT NOT NULL DEFAULT 0,
+    ADD COLUMN summary_error TEXT NOT NULL DEFAULT '',
+    ADD COLUMN summary_retry_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE articles
+    DROP COLUMN IF EXISTS summary_retry_at,
+    DROP COLUMN IF EXISTS summary_error,
+    DROP COLUMN IF EXISTS summary_failures;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- content is the extracted text of the article, saved so the page is downloaded once
+ALTER TABLE articles
+    ADD COLUMN content TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE articles
+    DROP COLUMN IF EXISTS content;
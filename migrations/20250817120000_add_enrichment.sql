@@ -0,0 +1,38 @@
+-- +goose Up
+-- articles are enriched with the text, image, language and summary before they're posted,
+-- the attempts of the summary at send time become the attempts of enrichment
+ALTER TABLE articles RENAME COLUMN summary_failures TO enrichment_attempts;
+ALTER TABLE articles RENAME COLUMN summary_error TO enrichment_error;
+ALTER TABLE articles RENAME COLUMN summary_retry_at TO enrichment_retry_at;
+
+ALTER TABLE articles
+    ADD COLUMN enrichment_status TEXT NOT NULL DEFAULT 'pending',
+    ADD COLUMN enrichment_leased_until TIMESTAMP,
+    ADD COLUMN enriched_at TIMESTAMP,
+    ADD COLUMN image_url TEXT NOT NULL DEFAULT '',
+    ADD COLUMN generated_summary TEXT NOT NULL DEFAULT '';
+
+-- articles out of the freshness window of their source won't be posted, they're not worth a summary.
+-- The global window is configured outside the database, a week covers it unless it's set longer
+UPDATE articles SET enrichment_status = 'enriched', enriched_at = NOW()
+    WHERE published_at < NOW() - GREATEST(
+        INTERVAL '7 days',
+        (SELECT freshness_window_seconds FROM sources WHERE sources.id = articles.source_id) * INTERVAL '1 second'
+    );
+
+CREATE INDEX articles_enrichment_queue_idx ON articles (created_at)
+    WHERE enrichment_status IN ('pending', 'enriching', 'failed');
+
+-- +goose Down
+DROP INDEX IF EXISTS articles_enrichment_queue_idx;
+
+ALTER TABLE articles
+    DROP COLUMN IF EXISTS generated_summary,
+    DROP COLUMN IF EXISTS image_url,
+    DROP COLUMN IF EXISTS enriched_at,
+    DROP COLUMN IF EXISTS enrichment_leased_until,
+    DROP COLUMN IF EXISTS enrichment_status;
+
+ALTER TABLE articles RENAME COLUMN enrichment_retry_at TO summary_retry_at;
+ALTER TABLE articles RENAME COLUMN enrichment_error TO summary_error;
+ALTER TABLE articles RENAME COLUMN enrichment_attempts TO summary_failures;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- extraction rules tune content extraction for sites readability does poorly on, selectors are CSS selectors
+CREATE TABLE extraction_rules (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    domain TEXT NOT NULL UNIQUE,
+    content_selector TEXT NOT NULL DEFAULT '',
+    remove_selector TEXT NOT NULL DEFAULT '',
+    author_selector TEXT NOT NULL DEFAULT '',
+    date_selector TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE articles
+    ADD COLUMN author TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE articles
+    DROP COLUMN IF EXISTS author;
+
+DROP TABLE IF EXISTS extraction_rules;
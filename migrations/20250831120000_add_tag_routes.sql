@@ -0,0 +1,18 @@
+-- +goose Up
+-- articles are routed to channels by source or by tag, tags are stored in lower case
+CREATE TABLE tag_channels (
+                              tag TEXT NOT NULL,
+                              channel_id BIGINT NOT NULL REFERENCES channels (id) ON DELETE CASCADE,
+                              PRIMARY KEY (channel_id, tag)
+);
+
+-- a channel with backlog also receives articles stored before it was created that aren't posted anywhere,
+-- so the default channel of a single channel setup keeps its queue
+ALTER TABLE channels ADD COLUMN backlog BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE channels SET backlog = TRUE WHERE name = 'default' AND publisher = 'telegram';
+
+-- +goose Down
+ALTER TABLE channels DROP COLUMN IF EXISTS backlog;
+
+DROP TABLE IF EXISTS tag_channels;
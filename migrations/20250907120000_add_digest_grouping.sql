@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE channels
+    ADD COLUMN digest_group_by TEXT NOT NULL DEFAULT 'source' CHECK (digest_group_by IN ('source', 'tag'));
+
+-- +goose Down
+ALTER TABLE channels DROP COLUMN IF EXISTS digest_group_by;
@@ -0,0 +1,35 @@
+-- +goose Up
+-- settled_at is when the article left the queue of a channel for good: posted, expired, rejected or dead.
+-- Channel queues read unsettled articles by the partial indexes, so finished articles don't pile up in them
+ALTER TABLE articles ADD COLUMN settled_at TIMESTAMP;
+
+UPDATE articles SET settled_at = posted_at WHERE posted_at IS NOT NULL;
+
+UPDATE articles a SET settled_at = d.settled_at
+FROM (
+    SELECT article_id, MIN(updated_at) AS settled_at FROM deliveries
+    WHERE status IN ('sent', 'expired', 'rejected', 'dead')
+    GROUP BY article_id
+) d
+WHERE a.id = d.article_id AND a.settled_at IS NULL;
+
+DROP INDEX IF EXISTS articles_source_id_posted_at_idx;
+DROP INDEX IF EXISTS articles_unposted_source_id_published_at_idx;
+DROP INDEX IF EXISTS articles_unposted_published_at_idx;
+
+CREATE INDEX articles_unsettled_published_at_idx ON articles (published_at) WHERE settled_at IS NULL;
+CREATE INDEX articles_unsettled_source_id_published_at_idx ON articles (source_id, published_at) WHERE settled_at IS NULL;
+CREATE INDEX articles_settled_at_idx ON articles (settled_at);
+CREATE INDEX articles_source_id_settled_at_idx ON articles (source_id, settled_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS articles_source_id_settled_at_idx;
+DROP INDEX IF EXISTS articles_settled_at_idx;
+DROP INDEX IF EXISTS articles_unsettled_source_id_published_at_idx;
+DROP INDEX IF EXISTS articles_unsettled_published_at_idx;
+
+CREATE INDEX articles_unposted_published_at_idx ON articles (published_at) WHERE posted_at IS NULL;
+CREATE INDEX articles_unposted_source_id_published_at_idx ON articles (source_id, published_at) WHERE posted_at IS NULL;
+CREATE INDEX articles_source_id_posted_at_idx ON articles (source_id, posted_at);
+
+ALTER TABLE articles DROP COLUMN IF EXISTS settled_at;